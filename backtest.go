@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/config"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
+)
+
+// Run "backtest": score the stored receipts under two rulesets and print how
+// the points would change
+func runBacktest(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	baselineFile := flags.String("baseline", cfg.RulesetFile, "ruleset file to compare against, the built in rules when empty")
+	candidateFile := flags.String("candidate", "", "ruleset file to try out")
+	// The filters are named like the query parameters of the backtest endpoint
+	filters := map[string]*string{
+		"retailer":  flags.String("retailer", "", "only receipts from this retailer"),
+		"date_from": flags.String("date_from", "", "only receipts purchased on or after this YYYY-MM-DD date"),
+		"date_to":   flags.String("date_to", "", "only receipts purchased on or before this YYYY-MM-DD date"),
+		"min_total": flags.String("min_total", "", "only receipts with a total of at least this amount"),
+		"max_total": flags.String("max_total", "", "only receipts with a total of at most this amount"),
+		"status":    flags.String("status", "", "only receipts with this scoring status: pending, scored or failed"),
+	}
+	top := flags.Int("top", scoring.DefaultBacktestTop, "how many retailers and receipts to list")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *candidateFile == "" {
+		return errors.New("backtest needs a -candidate ruleset file")
+	}
+	query, err := models.ParseReceiptFilters(func(name string) string { return *filters[name] })
+	if err != nil {
+		return err
+	}
+	if cfg.StoreBackend == config.StoreMemory {
+		return errors.New("backtest needs stored receipts, set RECEIPT_STORE=sqlite")
+	}
+
+	baseline := rules.DefaultRuleset()
+	if *baselineFile != "" {
+		if baseline, err = rules.LoadRulesetFile(*baselineFile); err != nil {
+			return fmt.Errorf("could not load the baseline ruleset:\n%w", err)
+		}
+	}
+	candidate, err := rules.LoadRulesetFile(*candidateFile)
+	if err != nil {
+		return fmt.Errorf("could not load the candidate ruleset:\n%w", err)
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return fmt.Errorf("could not open the %s receipt store: %w", cfg.StoreBackend, err)
+	}
+
+	report, err := scoring.Backtest(store, query, baseline, candidate, *top)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return printBacktest(out, report)
+}
+
+// Print the report as tables
+func printBacktest(out io.Writer, report scoring.BacktestReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Baseline\t%s\n", report.Baseline)
+	fmt.Fprintf(w, "Candidate\t%s\n", report.Candidate)
+	fmt.Fprintf(w, "Receipts\t%d (%d changed, %d unscoreable)\n", report.Receipts, report.Changed, report.Unscoreable)
+	fmt.Fprintf(w, "Total points\t%d -> %d (%+d)\n", report.BaselineTotal, report.CandidateTotal, report.TotalDelta)
+
+	fmt.Fprintln(w, "\nChange\tReceipts")
+	for _, bucket := range report.Distribution {
+		fmt.Fprintf(w, "%s\t%d\n", bucket.Label, bucket.Receipts)
+	}
+
+	fmt.Fprintln(w, "\nRetailer\tReceipts\tChanged\tDelta")
+	for _, retailer := range report.TopRetailers {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%+d\n", retailer.Retailer, retailer.Receipts, retailer.Changed, retailer.Delta)
+	}
+
+	fmt.Fprintln(w, "\nReceipt\tRetailer\tBefore\tAfter\tDelta")
+	for _, receipt := range report.TopReceipts {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%+d\n", receipt.ID, receipt.Retailer, receipt.Before, receipt.After, receipt.Delta)
+	}
+
+	return w.Flush()
+}
@@ -11,40 +11,158 @@ const docTemplate = `{
         "description": "{{escape .Description}}",
         "title": "{{.Title}}",
         "contact": {},
+        "license": {
+            "name": "MIT"
+        },
         "version": "{{.Version}}"
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/receipts": {
+        "/admin/receipts/rescore": {
+            "post": {
+                "description": "Score receipts again under a chosen ruleset version, replacing the points, breakdown and ruleset version stored with them. Each rescore is recorded in the receipt's history.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rescore Receipts",
+                "parameters": [
+                    {
+                        "description": "the ruleset version and receipts, the active ruleset and every receipt by default",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.RescoreRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer and the admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The receipts that were scored again",
+                        "schema": {
+                            "$ref": "#/definitions/api.RescoreResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The ruleset version is unknown",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "401": {
+                        "description": "The admin token is missing or wrong",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "404": {
+                        "description": "One of the receipts wasn't found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/receipts/{id}": {
+            "delete": {
+                "description": "Permanently remove a receipt, whether or not it was deleted, along with its history and the idempotency keys that point at it",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge Receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer and the admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "The receipt was purged"
+                    },
+                    "401": {
+                        "description": "The admin token is missing or wrong",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/rulesets": {
             "get": {
-                "description": "Get all of the receipts, no limit, no pagination",
+                "description": "List the ruleset versions that receipts can be rescored with: the built in rules and every ruleset loaded since the server started",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "reciepts"
+                    "admin"
+                ],
+                "summary": "List Ruleset Versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer and the admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
                 ],
-                "summary": "Get All Receipts",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "The known ruleset versions",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Receipt"
-                                }
-                            }
+                            "$ref": "#/definitions/api.RulesetVersionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "The admin token is missing or wrong",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
                         }
                     }
                 }
             }
         },
-        "/receipts/process": {
+        "/admin/rulesets/backtest": {
             "post": {
-                "description": "Create a receipt and add it to our memory array of receipts. Will not save the id if given one and will always make a new one.",
+                "description": "Score the stored receipts under two rulesets and report how the points would change: the total change, how many receipts changed by how much, and the retailers and receipts that changed the most. The candidate is either a known version or an inline ruleset, so rules can be tried out before they are loaded. Nothing is stored.",
                 "consumes": [
                     "application/json"
                 ],
@@ -52,29 +170,78 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "reciepts"
+                    "admin"
                 ],
-                "summary": "Process Receipt",
+                "summary": "Backtest Ruleset",
                 "parameters": [
                     {
-                        "description": "new receipt to create",
-                        "name": "receipt",
+                        "description": "the rulesets to compare",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Receipt"
+                            "$ref": "#/definitions/api.BacktestRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts from this retailer, ignoring letter case",
+                        "name": "retailer",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts with a total of at least this amount",
+                        "name": "min_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts with a total of at most this amount",
+                        "name": "max_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts with this scoring status: pending, scored or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer and the admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Returns the ID assigned to the receipt",
+                    "200": {
+                        "description": "How the points would change",
                         "schema": {
-                            "$ref": "#/definitions/api.CreatedReceiptResponse"
+                            "$ref": "#/definitions/scoring.BacktestReport"
                         }
                     },
                     "400": {
-                        "description": "The receipt is invalid",
+                        "description": "A filter, ruleset version or ruleset is invalid, or a paging parameter was given",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "401": {
+                        "description": "The admin token is missing or wrong",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorMessage"
                         }
@@ -82,34 +249,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/receipts/{id}": {
-            "get": {
-                "description": "Get the receipt by id",
+        "/points/simulate": {
+            "post": {
+                "description": "Score a receipt without storing anything. The body is either a receipt, or an object with the receipt and a ruleset version or an inline ruleset to score it with. The active ruleset is used when neither is given. Inline rulesets need the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "reciepts"
+                    "points"
                 ],
-                "summary": "Get A Receipt",
+                "summary": "Simulate Points",
                 "parameters": [
+                    {
+                        "description": "the receipt and the rules to score it with",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.SimulateRequest"
+                        }
+                    },
                     {
                         "type": "string",
-                        "description": "get receipt by id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Bearer and the admin token, needed for an inline ruleset",
+                        "name": "Authorization",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "success",
+                        "description": "The points the receipt would be awarded",
                         "schema": {
-                            "$ref": "#/definitions/models.Receipt"
+                            "$ref": "#/definitions/api.SimulateResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "The receipt or the ruleset is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "An inline ruleset was given without the admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "422": {
+                        "description": "The reconciliation policy doesn't allow the receipt to be scored",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorMessage"
                         }
@@ -117,95 +307,1097 @@ const docTemplate = `{
                 }
             }
         },
-        "/receipts/{id}/points": {
+        "/receipts": {
             "get": {
-                "description": "Returns the points awarded for the receipt",
+                "description": "Get a page of the receipts, optionally filtered and sorted. Pass the next_cursor of a page as the cursor to get the page after it.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "reciepts"
                 ],
-                "summary": "Receipt Points",
+                "summary": "Get All Receipts",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The most receipts to return, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "The ID of the receipt",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "The next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts from this retailer, ignoring letter case",
+                        "name": "retailer",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or after this date, YYYY-MM-DD",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or before this date, YYYY-MM-DD",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts with at least this total, e.g. 10.00",
+                        "name": "min_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts with at most this total, e.g. 50.00",
+                        "name": "max_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "scored",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Only receipts with this scoring status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created",
+                            "date",
+                            "total",
+                            "points"
+                        ],
+                        "type": "string",
+                        "description": "The order of the receipts",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "The direction of the sort",
+                        "name": "order",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "The number of points awarded",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ReceiptPointsResponse"
+                            "$ref": "#/definitions/api.ReceiptListResponse"
                         }
                     },
-                    "404": {
-                        "description": "No receipt found for that id",
+                    "400": {
+                        "description": "A query parameter is invalid",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorMessage"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.CreatedReceiptResponse": {
-            "description": "Receipt processed response with id",
-            "type": "object",
-            "required": [
-                "id"
-            ],
-            "properties": {
-                "id": {
-                    "description": "The new receipt id",
-                    "type": "string"
-                }
-            }
-        },
-        "api.ErrorMessage": {
-            "description": "Error Message Information",
-            "type": "object",
-            "properties": {
-                "message": {
-                    "description": "The message",
-                    "type": "string"
-                }
-            }
-        },
-        "api.ReceiptPointsResponse": {
-            "description": "Receipt points awarded response with points",
-            "type": "object",
-            "required": [
-                "points"
-            ],
-            "properties": {
-                "points": {
-                    "description": "The points awarded for the receipt",
-                    "type": "integer"
-                }
-            }
         },
-        "models.Item": {
-            "type": "object",
-            "required": [
-                "price",
-                "shortDescription"
-            ],
-            "properties": {
-                "price": {
-                    "description": "The total price payed for this item.",
-                    "type": "string"
-                },
-                "shortDescription": {
-                    "description": "The Short Product Description for the item.",
-                    "type": "string"
-                }
-            }
+        "/receipts/process": {
+            "post": {
+                "description": "Create a receipt and add it to the receipt store. Will not save the id if given one and will always make a new one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Process Receipt",
+                "parameters": [
+                    {
+                        "description": "new receipt to create",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "A unique key for the request, retries with the same key and body get the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The receipt was already processed, returns the ID of the original",
+                        "schema": {
+                            "$ref": "#/definitions/api.CreatedReceiptResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Returns the ID assigned to the receipt",
+                        "schema": {
+                            "$ref": "#/definitions/api.CreatedReceiptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The receipt is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The receipt was already processed",
+                        "schema": {
+                            "$ref": "#/definitions/api.DuplicateReceiptResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "The receipt created with the Idempotency-Key has since been deleted",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "422": {
+                        "description": "The Idempotency-Key was already used with a different body",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/process/batch": {
+            "post": {
+                "description": "Process many receipts in one request. The body is either a JSON array of receipts, or one receipt per line when sent as application/x-ndjson. Each receipt is validated on its own and gets its own result. With atomic=true nothing is stored unless every receipt is accepted.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Process Receipt Batch",
+                "parameters": [
+                    {
+                        "description": "the receipts to create",
+                        "name": "receipts",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Receipt"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Store nothing if any receipt fails",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The result of every receipt",
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The batch is malformed or has too many receipts",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "413": {
+                        "description": "The batch body is too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "422": {
+                        "description": "The batch was atomic and at least one receipt failed, nothing was stored",
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}": {
+            "get": {
+                "description": "Get the receipt by id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Get A Receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "get receipt by id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the contents of a receipt. The receipt is validated the same way as a new one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Update Receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "the new contents of the receipt",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated receipt",
+                        "schema": {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    },
+                    "400": {
+                        "description": "The receipt is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "409": {
+                        "description": "The change would make the receipt a duplicate of another",
+                        "schema": {
+                            "$ref": "#/definitions/api.DuplicateReceiptResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a receipt. It is no longer returned by the API but is kept until it is purged.",
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Delete Receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "The receipt was deleted"
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change some of the fields of a receipt with a JSON Merge Patch (RFC 7396). Fields set to null are removed, and items can only be replaced as a whole list. Only the retailer, purchase date, purchase time, time zone, total and items can be patched. The patched receipt is validated the same way as a new one.",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Patch Receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "the fields to change",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, recorded in the history as given without being checked",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated receipt",
+                        "schema": {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    },
+                    "400": {
+                        "description": "The patch changes a read only field or the patched receipt is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "409": {
+                        "description": "The change would make the receipt a duplicate of another",
+                        "schema": {
+                            "$ref": "#/definitions/api.DuplicateReceiptResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/history": {
+            "get": {
+                "description": "Returns every change made to a receipt, oldest first. Deleted receipts keep their history until they are purged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Receipt History",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The revisions of the receipt",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Revision"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "No history found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/history/{revision}": {
+            "get": {
+                "description": "Returns a single revision of a receipt, including the receipt as it was after the change",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Receipt Revision",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The number of the revision, counting up from 1",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The revision",
+                        "schema": {
+                            "$ref": "#/definitions/models.Revision"
+                        }
+                    },
+                    "404": {
+                        "description": "No revision found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/points": {
+            "get": {
+                "description": "Returns the points awarded for the receipt. Receipts are scored in the background, until then the scoring status is returned with a 202.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Calculate Receipt Points",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The number of points awarded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReceiptPointsResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "The receipt hasn't been scored yet",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReceiptStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The receipt could not be scored",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "422": {
+                        "description": "The receipt total doesn't match its items",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/points/breakdown": {
+            "get": {
+                "description": "Returns the points awarded for the receipt, and how each rule contributed to them when it was scored",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Explain Receipt Points",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The points awarded by each rule",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReceiptPointsBreakdownResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "The receipt hasn't been scored yet",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReceiptStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The receipt could not be scored",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    },
+                    "422": {
+                        "description": "The receipt total doesn't match its items",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/status": {
+            "get": {
+                "description": "Returns whether the receipt is still waiting to be scored, has been scored, or couldn't be scored",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reciepts"
+                ],
+                "summary": "Receipt Scoring Status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The ID of the receipt",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The scoring status of the receipt",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReceiptStatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No receipt found for that id",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorMessage"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BacktestRequest": {
+            "description": "The rulesets to compare",
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "description": "The version of the ruleset to compare against, the active ruleset when empty",
+                    "type": "string",
+                    "example": "default"
+                },
+                "candidate": {
+                    "description": "The version of the ruleset to try out",
+                    "type": "string",
+                    "example": "2024-06"
+                },
+                "candidateRuleset": {
+                    "description": "A ruleset to try out instead of a known version, laid out like a ruleset file",
+                    "type": "object"
+                },
+                "top": {
+                    "description": "How many retailers and receipts to list, 10 when 0",
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "api.BatchEntryResult": {
+            "description": "What happened to one receipt of a batch",
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "description": "The problem with each bad field of an invalid receipt",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "id": {
+                    "description": "The id of the stored receipt, or of the original for duplicates",
+                    "type": "string",
+                    "example": "adb6b560-0eef-42bc-9d16-df48f30e89b2"
+                },
+                "index": {
+                    "description": "Position of the receipt in the batch, starting from 0",
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "description": "One of \"created\", \"existing\", \"invalid\", \"duplicate\" or \"skipped\"",
+                    "type": "string",
+                    "example": "created"
+                }
+            }
+        },
+        "api.BatchResponse": {
+            "description": "The result of every receipt in a batch",
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "How many receipts were stored",
+                    "type": "integer",
+                    "example": 2
+                },
+                "failed": {
+                    "description": "How many receipts were invalid or rejected as duplicates",
+                    "type": "integer",
+                    "example": 1
+                },
+                "results": {
+                    "description": "One result per receipt, in the order they were sent",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BatchEntryResult"
+                    }
+                }
+            }
+        },
+        "api.CreatedReceiptResponse": {
+            "description": "Receipt processed response with id",
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "description": "The new receipt id",
+                    "type": "string"
+                }
+            }
+        },
+        "api.DuplicateReceiptResponse": {
+            "description": "The receipt was already processed",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "The id of the original receipt",
+                    "type": "string",
+                    "example": "adb6b560-0eef-42bc-9d16-df48f30e89b2"
+                },
+                "message": {
+                    "description": "The message",
+                    "type": "string",
+                    "example": "The receipt has already been processed"
+                }
+            }
+        },
+        "api.ErrorMessage": {
+            "description": "Error Message Information",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "The message",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReceiptListResponse": {
+            "description": "A page of receipts",
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "Pass as the cursor to get the next page, missing on the last page",
+                    "type": "string"
+                },
+                "receipts": {
+                    "description": "The receipts on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Receipt"
+                    }
+                }
+            }
+        },
+        "api.ReceiptPointsBreakdownResponse": {
+            "description": "Receipt points breakdown response, with the points and reason of every rule",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "The receipt id",
+                    "type": "string"
+                },
+                "points": {
+                    "description": "The total points awarded for the receipt",
+                    "type": "integer"
+                },
+                "rules": {
+                    "description": "Every rule that was evaluated, in order, including those that awarded no points",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rules.RuleResult"
+                    }
+                },
+                "rulesetVersion": {
+                    "description": "The version of the ruleset that awarded the points",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.ReceiptPointsResponse": {
+            "description": "Receipt points awarded response with points",
+            "type": "object",
+            "required": [
+                "points"
+            ],
+            "properties": {
+                "points": {
+                    "description": "The points awarded for the receipt",
+                    "type": "integer"
+                },
+                "rulesetVersion": {
+                    "description": "The version of the ruleset that awarded the points",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.ReceiptStatusResponse": {
+            "description": "Where a receipt is in scoring",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the receipt couldn't be scored, when scoring failed",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "The receipt id",
+                    "type": "string",
+                    "example": "adb6b560-0eef-42bc-9d16-df48f30e89b2"
+                },
+                "points": {
+                    "description": "The points awarded, once the receipt is scored",
+                    "type": "integer",
+                    "example": 28
+                },
+                "status": {
+                    "description": "One of \"pending\", \"scored\" or \"failed\"",
+                    "type": "string",
+                    "example": "scored"
+                }
+            }
+        },
+        "api.RescoreRequest": {
+            "description": "Which receipts to score again, and with which ruleset",
+            "type": "object",
+            "properties": {
+                "receiptIds": {
+                    "description": "The receipts to score again, every receipt when empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "version": {
+                    "description": "The version of the ruleset to score with, the active ruleset when empty",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.RescoreResponse": {
+            "description": "The outcome of scoring receipts again",
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "description": "The receipts whose points or status changed",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RescoredReceipt"
+                    }
+                },
+                "rescored": {
+                    "description": "How many receipts were scored again",
+                    "type": "integer",
+                    "example": 12
+                },
+                "version": {
+                    "description": "The version of the ruleset the receipts were scored with",
+                    "type": "string",
+                    "example": "2024-06"
+                }
+            }
+        },
+        "api.RescoredReceipt": {
+            "description": "A receipt whose score changed when it was scored again",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "The receipt id",
+                    "type": "string",
+                    "example": "adb6b560-0eef-42bc-9d16-df48f30e89b2"
+                },
+                "pointsAfter": {
+                    "description": "The points after, missing if it couldn't be scored",
+                    "type": "integer",
+                    "example": 30
+                },
+                "pointsBefore": {
+                    "description": "The points before, missing if it wasn't scored",
+                    "type": "integer",
+                    "example": 20
+                },
+                "status": {
+                    "description": "The scoring status after it was scored again",
+                    "type": "string",
+                    "example": "scored"
+                },
+                "versionBefore": {
+                    "description": "The version of the ruleset it was scored with before",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.RulesetVersionsResponse": {
+            "description": "The ruleset versions receipts can be scored with",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "The version new receipts are scored with",
+                    "type": "string",
+                    "example": "2024-06"
+                },
+                "versions": {
+                    "description": "Every version that is known, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.SimulateRequest": {
+            "description": "A receipt to score, and optionally the rules to score it with",
+            "type": "object",
+            "properties": {
+                "receipt": {
+                    "description": "The receipt, validated the same way as a new one",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    ]
+                },
+                "ruleset": {
+                    "description": "A ruleset laid out like a ruleset file, e.g. {\"rules\": [{\"type\": \"item_pairs\"}]}, only with the admin token",
+                    "type": "object"
+                },
+                "version": {
+                    "description": "The version of a known ruleset to score with",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.SimulateResponse": {
+            "description": "The points a receipt would be awarded",
+            "type": "object",
+            "properties": {
+                "points": {
+                    "description": "The total points the receipt would be awarded",
+                    "type": "integer",
+                    "example": 28
+                },
+                "rules": {
+                    "description": "Every rule that was evaluated, in order, including those that awarded no points",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rules.RuleResult"
+                    }
+                },
+                "rulesetVersion": {
+                    "description": "The version of the ruleset that scored it",
+                    "type": "string",
+                    "example": "default"
+                }
+            }
+        },
+        "api.ValidationErrorResponse": {
+            "description": "Every field of the receipt that is invalid",
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "description": "The problem with each bad field",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "message": {
+                    "description": "The message",
+                    "type": "string",
+                    "example": "The receipt is invalid"
+                }
+            }
+        },
+        "models.Discrepancy": {
+            "type": "object",
+            "properties": {
+                "difference": {
+                    "description": "The receipt total minus the sum of the item prices",
+                    "type": "string",
+                    "example": "2.00"
+                },
+                "itemsTotal": {
+                    "description": "The sum of the item prices",
+                    "type": "string",
+                    "example": "33.35"
+                }
+            }
+        },
+        "models.FieldChange": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "description": "The value after the change, missing if the field was removed",
+                    "type": "string",
+                    "example": "20.74"
+                },
+                "before": {
+                    "description": "The value before the change, missing if the field was added",
+                    "type": "string",
+                    "example": "18.74"
+                },
+                "field": {
+                    "description": "Path of the field, e.g. \"total\" or \"items[1].price\"",
+                    "type": "string",
+                    "example": "total"
+                }
+            }
+        },
+        "models.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "A machine readable reason, e.g. \"required\" or \"pattern\"",
+                    "type": "string",
+                    "example": "pattern"
+                },
+                "field": {
+                    "description": "Path of the field, e.g. \"total\" or \"items[1].price\"",
+                    "type": "string",
+                    "example": "items[1].price"
+                },
+                "message": {
+                    "description": "A human readable explanation",
+                    "type": "string",
+                    "example": "must be a dollar amount with two decimal places, e.g. 6.49"
+                }
+            }
+        },
+        "models.Item": {
+            "type": "object",
+            "required": [
+                "price",
+                "shortDescription"
+            ],
+            "properties": {
+                "price": {
+                    "description": "The total price payed for this item.",
+                    "type": "string",
+                    "example": "6.49"
+                },
+                "shortDescription": {
+                    "description": "The Short Product Description for the item.",
+                    "type": "string"
+                }
+            }
         },
         "models.Receipt": {
             "type": "object",
@@ -217,34 +1409,383 @@ const docTemplate = `{
                 "total"
             ],
             "properties": {
+                "discrepancy": {
+                    "description": "Set when the items don't add up to the total but the receipt was\naccepted anyway",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Discrepancy"
+                        }
+                    ]
+                },
+                "duplicateOf": {
+                    "description": "The ID of the receipt this one duplicates, when duplicates are kept",
+                    "type": "string"
+                },
+                "fingerprint": {
+                    "description": "A hash of the receipt's contents, identical receipts share it",
+                    "type": "string"
+                },
                 "id": {
                     "description": "The ID of the receipt",
                     "type": "string"
                 },
                 "items": {
+                    "description": "The list of items in this receipt",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.Item"
                     }
                 },
+                "points": {
+                    "description": "The points the receipt was awarded, once it is scored",
+                    "type": "integer",
+                    "example": 28
+                },
                 "purchaseDate": {
-                    "description": "The date of the purchase printed on the receipt.",
+                    "description": "The date of the purchase printed on the receipt. Sent in any of the\naccepted layouts and stored as YYYY-MM-DD.",
                     "type": "string"
                 },
                 "purchaseTime": {
-                    "description": "The time of the purchase printed on the receipt. 24-hour time expected.",
+                    "description": "The time of the purchase printed on the receipt. Sent in any of the\naccepted layouts and stored as 24 hour HH:MM.",
                     "type": "string"
                 },
+                "rawPurchaseDate": {
+                    "description": "The purchase date and time as they were sent, when they weren't\nalready in their stored form",
+                    "type": "string",
+                    "example": "06/15/2023"
+                },
+                "rawPurchaseTime": {
+                    "type": "string",
+                    "example": "3:40 PM"
+                },
                 "retailer": {
                     "description": "The name of the retailer or store the receipt is from.",
                     "type": "string"
                 },
+                "rulesetVersion": {
+                    "description": "The version of the ruleset the receipt was scored with",
+                    "type": "string",
+                    "example": "default"
+                },
+                "scoreError": {
+                    "description": "Why the receipt couldn't be scored, when scoring failed",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Where the receipt is in scoring: \"pending\", \"scored\" or \"failed\"",
+                    "type": "string",
+                    "example": "scored"
+                },
+                "timeZone": {
+                    "description": "The time zone the purchase date and time are in, an IANA name or a UTC\noffset. The ruleset decides the zone when it is left out.",
+                    "type": "string",
+                    "example": "America/Chicago"
+                },
                 "total": {
                     "description": "The total amount paid on the receipt.",
+                    "type": "string",
+                    "example": "35.35"
+                }
+            }
+        },
+        "models.Revision": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "One of \"create\", \"update\", \"delete\" or \"rescore\"",
+                    "type": "string",
+                    "example": "update"
+                },
+                "actor": {
+                    "description": "Who made the change",
+                    "type": "string",
+                    "example": "support@example.com"
+                },
+                "changes": {
+                    "description": "Every field that changed",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "createdAt": {
+                    "description": "When the change was made",
+                    "type": "string"
+                },
+                "pointsAfter": {
+                    "description": "The points the receipt was worth after the change, missing when it\nwas deleted or hadn't been scored yet",
+                    "type": "integer",
+                    "example": 30
+                },
+                "pointsBefore": {
+                    "description": "The points the receipt was worth before the change, missing when it\ndidn't exist or hadn't been scored",
+                    "type": "integer",
+                    "example": 20
+                },
+                "receipt": {
+                    "description": "The receipt as it was after the change, or as it was when deleted",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Receipt"
+                        }
+                    ]
+                },
+                "receiptId": {
+                    "description": "The receipt that was changed",
+                    "type": "string"
+                },
+                "revision": {
+                    "description": "Counts up from 1 for each receipt",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "rules.PointAdjustment": {
+            "type": "object",
+            "properties": {
+                "before": {
+                    "description": "The points before they were adjusted",
+                    "type": "integer"
+                },
+                "change": {
+                    "description": "The points after less the points before, negative for caps",
+                    "type": "integer"
+                },
+                "kind": {
+                    "description": "\"cap\" or \"minimum\"",
+                    "type": "string"
+                },
+                "limit": {
+                    "description": "The maximum or minimum that was applied",
+                    "type": "integer"
+                }
+            }
+        },
+        "rules.PointRuleItem": {
+            "type": "object",
+            "properties": {
+                "adjustment": {
+                    "description": "How a cap changed the points of the item, missing if none did",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/rules.PointAdjustment"
+                        }
+                    ]
+                },
+                "description": {
+                    "description": "The item's description as printed on the receipt",
+                    "type": "string"
+                },
+                "descriptionLength": {
+                    "description": "The trimmed length of the description",
+                    "type": "integer"
+                },
+                "points": {
+                    "description": "The points the item contributed",
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "The item's price",
+                    "type": "string",
+                    "example": "12.25"
+                },
+                "reason": {
+                    "description": "A human readable explanation of the points",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "The fractional points before rounding",
+                    "type": "number"
+                }
+            }
+        },
+        "rules.RuleResult": {
+            "type": "object",
+            "properties": {
+                "adjustment": {
+                    "description": "How a cap or minimum changed the points, missing if none did",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/rules.PointAdjustment"
+                        }
+                    ]
+                },
+                "items": {
+                    "description": "The contribution of each item, for rules that score items",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rules.PointRuleItem"
+                    }
+                },
+                "name": {
+                    "description": "The name of the rule that produced this result",
+                    "type": "string"
+                },
+                "points": {
+                    "description": "The points awarded by the rule",
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "A human readable explanation of the points",
                     "type": "string"
                 }
             }
+        },
+        "scoring.BacktestReport": {
+            "description": "How the points of stored receipts change between two rulesets",
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "description": "The version of the ruleset the receipts are compared against",
+                    "type": "string",
+                    "example": "default"
+                },
+                "baselineTotal": {
+                    "description": "The points of every receipt under the baseline",
+                    "type": "integer",
+                    "example": 8410
+                },
+                "candidate": {
+                    "description": "The version of the ruleset being tried out",
+                    "type": "string",
+                    "example": "2024-06"
+                },
+                "candidateTotal": {
+                    "description": "The points of every receipt under the candidate",
+                    "type": "integer",
+                    "example": 8992
+                },
+                "changed": {
+                    "description": "How many receipts are worth a different number of points",
+                    "type": "integer",
+                    "example": 37
+                },
+                "distribution": {
+                    "description": "How many receipts changed by how much",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scoring.DeltaBucket"
+                    }
+                },
+                "receipts": {
+                    "description": "How many receipts were scored under both rulesets",
+                    "type": "integer",
+                    "example": 120
+                },
+                "topReceipts": {
+                    "description": "The receipts that changed the most",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scoring.ReceiptDelta"
+                    }
+                },
+                "topRetailers": {
+                    "description": "The retailers whose receipts changed the most overall",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scoring.RetailerDelta"
+                    }
+                },
+                "totalDelta": {
+                    "description": "The candidate total less the baseline total",
+                    "type": "integer",
+                    "example": 582
+                },
+                "unscoreable": {
+                    "description": "How many receipts couldn't be scored under one of the rulesets, they\naren't counted anywhere else",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "scoring.DeltaBucket": {
+            "description": "How many receipts changed by an amount in a range",
+            "type": "object",
+            "properties": {
+                "label": {
+                    "description": "The range, e.g. \"1 to 9\"",
+                    "type": "string",
+                    "example": "1 to 9"
+                },
+                "max": {
+                    "description": "The largest change in the range, missing if it has no upper bound",
+                    "type": "integer",
+                    "example": 9
+                },
+                "min": {
+                    "description": "The smallest change in the range, missing if it has no lower bound",
+                    "type": "integer",
+                    "example": 1
+                },
+                "receipts": {
+                    "description": "How many receipts changed by an amount in the range",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "scoring.ReceiptDelta": {
+            "description": "How the points of one receipt changed",
+            "type": "object",
+            "properties": {
+                "after": {
+                    "description": "The points under the candidate",
+                    "type": "integer",
+                    "example": 78
+                },
+                "before": {
+                    "description": "The points under the baseline",
+                    "type": "integer",
+                    "example": 28
+                },
+                "delta": {
+                    "description": "After less before",
+                    "type": "integer",
+                    "example": 50
+                },
+                "id": {
+                    "description": "The receipt id",
+                    "type": "string",
+                    "example": "adb6b560-0eef-42bc-9d16-df48f30e89b2"
+                },
+                "retailer": {
+                    "description": "The retailer name",
+                    "type": "string",
+                    "example": "Target"
+                }
+            }
+        },
+        "scoring.RetailerDelta": {
+            "description": "How the receipts of one retailer changed",
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "description": "How many of its receipts changed",
+                    "type": "integer",
+                    "example": 6
+                },
+                "delta": {
+                    "description": "The change in the points of all of its receipts",
+                    "type": "integer",
+                    "example": -40
+                },
+                "receipts": {
+                    "description": "How many of its receipts were scored",
+                    "type": "integer",
+                    "example": 14
+                },
+                "retailer": {
+                    "description": "The retailer name",
+                    "type": "string",
+                    "example": "Target"
+                }
+            }
         }
+    },
+    "externalDocs": {
+        "description": "OpenAPI",
+        "url": "https://swagger.io/resources/open-api/"
     }
 }`
 
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Only lets through requests with an "Authorization: Bearer <token>" header
+// for the given token
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasAdminToken(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized,
+				ErrorMessage{Message: "A valid admin token is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Whether the request has an "Authorization: Bearer <token>" header for the
+// given token. Nothing has an empty token.
+func hasAdminToken(c *gin.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	given, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// PurgeReceipt	godoc
+// @Description 	Permanently remove a receipt, whether or not it was deleted, along with its history and the idempotency keys that point at it
+// @Summary				Purge Receipt
+// @Param					id path string true "The ID of the receipt"
+// @Param					Authorization header string true "Bearer and the admin token"
+// @Tags					admin
+// @Success				204 "The receipt was purged"
+// @Failure				401 {object} ErrorMessage "The admin token is missing or wrong"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Router				/admin/receipts/{id} [delete]
+func (h *Handler) PurgeReceipt(c *gin.Context) {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	err := h.Store.PurgeReceipt(c.Param("id"))
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Rescore Request Info
+// @Description Which receipts to score again, and with which ruleset
+type RescoreRequest struct {
+	// The version of the ruleset to score with, the active ruleset when empty
+	Version string `json:"version" example:"default"`
+	// The receipts to score again, every receipt when empty
+	ReceiptIDs []string `json:"receiptIds"`
+}
+
+// Rescored Receipt Info
+// @Description A receipt whose score changed when it was scored again
+type RescoredReceipt struct {
+	// The receipt id
+	ID string `json:"id" example:"adb6b560-0eef-42bc-9d16-df48f30e89b2"`
+	// The scoring status after it was scored again
+	Status string `json:"status" example:"scored"`
+	// The points before, missing if it wasn't scored
+	PointsBefore *int `json:"pointsBefore,omitempty" example:"20"`
+	// The points after, missing if it couldn't be scored
+	PointsAfter *int `json:"pointsAfter,omitempty" example:"30"`
+	// The version of the ruleset it was scored with before
+	VersionBefore string `json:"versionBefore,omitempty" example:"default"`
+}
+
+// Rescore Info
+// @Description The outcome of scoring receipts again
+type RescoreResponse struct {
+	// The version of the ruleset the receipts were scored with
+	Version string `json:"version" example:"2024-06"`
+	// How many receipts were scored again
+	Rescored int `json:"rescored" example:"12"`
+	// The receipts whose points or status changed
+	Changed []RescoredReceipt `json:"changed"`
+}
+
+// Ruleset Versions Info
+// @Description The ruleset versions receipts can be scored with
+type RulesetVersionsResponse struct {
+	// The version new receipts are scored with
+	Active string `json:"active" example:"2024-06"`
+	// Every version that is known, sorted
+	Versions []string `json:"versions"`
+}
+
+// GetRulesetVersions	godoc
+// @Description 	List the ruleset versions that receipts can be rescored with: the built in rules and every ruleset loaded since the server started
+// @Summary				List Ruleset Versions
+// @Param					Authorization header string true "Bearer and the admin token"
+// @Produce				application/json
+// @Tags					admin
+// @Success				200 {object} RulesetVersionsResponse "The known ruleset versions"
+// @Failure				401 {object} ErrorMessage "The admin token is missing or wrong"
+// @Router				/admin/rulesets [get]
+func (h *Handler) GetRulesetVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, RulesetVersionsResponse{
+		Active:   rules.ActiveRuleset().Version(),
+		Versions: rules.RulesetVersions(),
+	})
+}
+
+// RescoreReceipts	godoc
+// @Description 	Score receipts again under a chosen ruleset version, replacing the points, breakdown and ruleset version stored with them. Each rescore is recorded in the receipt's history.
+// @Summary				Rescore Receipts
+// @Param					request body RescoreRequest false "the ruleset version and receipts, the active ruleset and every receipt by default"
+// @Param					Authorization header string true "Bearer and the admin token"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
+// @Accept				application/json
+// @Produce				application/json
+// @Tags					admin
+// @Success				200 {object} RescoreResponse "The receipts that were scored again"
+// @Failure				400 {object} ErrorMessage "The ruleset version is unknown"
+// @Failure				401 {object} ErrorMessage "The admin token is missing or wrong"
+// @Failure				404 {object} ErrorMessage "One of the receipts wasn't found"
+// @Router				/admin/receipts/rescore [post]
+func (h *Handler) RescoreReceipts(c *gin.Context) {
+	var request RescoreRequest
+	body, err := c.GetRawData()
+	if err == nil && len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The body must be a JSON object: " + err.Error()})
+		return
+	}
+
+	ruleset, err := requestedRuleset(request.Version, nil)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	ids := request.ReceiptIDs
+	if len(ids) == 0 {
+		if ids, err = h.allReceiptIDs(); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+	} else {
+		for _, id := range ids {
+			if _, err := h.Store.GetReceipt(id); err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound,
+					ErrorMessage{Message: fmt.Sprintf("%s: %s", id, err.Error())})
+				return
+			}
+		}
+	}
+
+	response := RescoreResponse{Version: ruleset.Version(), Changed: []RescoredReceipt{}}
+	for _, id := range ids {
+		before, after, err := h.rescoreReceipt(c, id, ruleset)
+		if errors.Is(err, models.ErrReceiptNotFound) {
+			// Deleted since the list was made
+			continue
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+
+		response.Rescored++
+		if before.Status != after.Status || !samePoints(before.Points, after.Points) {
+			response.Changed = append(response.Changed, RescoredReceipt{
+				ID:            id,
+				Status:        after.Status,
+				PointsBefore:  before.Points,
+				PointsAfter:   after.Points,
+				VersionBefore: before.RulesetVersion,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Backtest Request Info
+// @Description The rulesets to compare
+type BacktestRequest struct {
+	// The version of the ruleset to compare against, the active ruleset when empty
+	Baseline string `json:"baseline" example:"default"`
+	// The version of the ruleset to try out
+	Candidate string `json:"candidate" example:"2024-06"`
+	// A ruleset to try out instead of a known version, laid out like a ruleset file
+	CandidateRuleset json.RawMessage `json:"candidateRuleset,omitempty" swaggertype:"object"`
+	// How many retailers and receipts to list, 10 when 0
+	Top int `json:"top" example:"10"`
+}
+
+// BacktestRulesets	godoc
+// @Description 	Score the stored receipts under two rulesets and report how the points would change: the total change, how many receipts changed by how much, and the retailers and receipts that changed the most. The candidate is either a known version or an inline ruleset, so rules can be tried out before they are loaded. Nothing is stored.
+// @Summary				Backtest Ruleset
+// @Param					request body BacktestRequest true "the rulesets to compare"
+// @Param					retailer query string false "Only receipts from this retailer, ignoring letter case"
+// @Param					date_from query string false "Only receipts purchased on or after this date (YYYY-MM-DD)"
+// @Param					date_to query string false "Only receipts purchased on or before this date (YYYY-MM-DD)"
+// @Param					min_total query string false "Only receipts with a total of at least this amount"
+// @Param					max_total query string false "Only receipts with a total of at most this amount"
+// @Param					status query string false "Only receipts with this scoring status: pending, scored or failed"
+// @Param					Authorization header string true "Bearer and the admin token"
+// @Accept				application/json
+// @Produce				application/json
+// @Tags					admin
+// @Success				200 {object} scoring.BacktestReport "How the points would change"
+// @Failure				400 {object} ErrorMessage "A filter, ruleset version or ruleset is invalid, or a paging parameter was given"
+// @Failure				401 {object} ErrorMessage "The admin token is missing or wrong"
+// @Router				/admin/rulesets/backtest [post]
+func (h *Handler) BacktestRulesets(c *gin.Context) {
+	query, err := parseReceiptFilters(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	var request BacktestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The body must be a JSON object: " + err.Error()})
+		return
+	}
+	if request.Top < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "top must not be negative"})
+		return
+	}
+	if request.Candidate == "" && len(request.CandidateRuleset) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "A candidate ruleset version or ruleset is required"})
+		return
+	}
+
+	baseline, err := requestedRuleset(request.Baseline, nil)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{Message: err.Error()})
+		return
+	}
+	candidate, err := requestedRuleset(request.Candidate, request.CandidateRuleset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	report, err := scoring.Backtest(h.Store, query, baseline, candidate, request.Top)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Score one receipt again and record it in the history. The write lock is
+// only held for the one receipt, so other requests aren't held up by a
+// large rescore.
+func (h *Handler) rescoreReceipt(c *gin.Context, id string, ruleset *rules.Ruleset) (*models.Receipt, *models.Receipt, error) {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	before, after, err := scoring.RescoreReceipt(h.Store, id, ruleset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.recordRevision(c, models.RevisionRescore, before, after); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// The ids of every stored receipt, in the order they were added
+func (h *Handler) allReceiptIDs() ([]string, error) {
+	var ids []string
+	query := models.ReceiptQuery{Limit: models.DefaultPageLimit}
+	for {
+		page, err := h.Store.QueryReceipts(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, receipt := range page.Receipts {
+			ids = append(ids, receipt.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// Whether two optional point values are the same
+func samePoints(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Send a request with the admin token
+func doAdminRequest(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRescoreReceipts(t *testing.T) {
+	previous := rules.ActiveRuleset()
+	defer rules.SetRuleset(previous)
+
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	// Changing the rules doesn't change the points already awarded
+	pairs, err := rules.DefaultRegistry.ParseRuleset([]byte("version: big-pairs\nrules:\n  - type: item_pairs\n    params:\n      pointsPerGroup: 100\n"), "test")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+	rules.SetRuleset(pairs)
+	rules.SetRuleset(previous)
+
+	var points ReceiptPointsResponse
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/points", "")
+	json.Unmarshal(w.Body.Bytes(), &points)
+	if points.Points != 20 || points.RulesetVersion != rules.DefaultRulesetVersion {
+		t.Errorf("GET /receipts/{id}/points = got %+v, wanted 20 points from the %q ruleset", points, rules.DefaultRulesetVersion)
+	}
+
+	testTable := []struct {
+		body           string
+		expectedStatus int
+	}{
+		{`{"version": "missing"}`, http.StatusBadRequest},
+		{`{"receiptIds": ["missing"]}`, http.StatusNotFound},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, test := range testTable {
+		if w = doAdminRequest(router, http.MethodPost, "/admin/receipts/rescore", test.body); w.Code != test.expectedStatus {
+			t.Errorf("POST /admin/receipts/rescore with %s = got status %d, wanted %d", test.body, w.Code, test.expectedStatus)
+		}
+	}
+
+	w = doAdminRequest(router, http.MethodPost, "/admin/receipts/rescore", `{"version": "big-pairs"}`)
+	var response RescoreResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Rescored != 1 || len(response.Changed) != 1 ||
+		*response.Changed[0].PointsBefore != 20 || *response.Changed[0].PointsAfter != 100 {
+		t.Fatalf("POST /admin/receipts/rescore = got %d %s, wanted one receipt changed from 20 to 100 points", w.Code, w.Body.String())
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/points/breakdown", "")
+	var breakdown ReceiptPointsBreakdownResponse
+	json.Unmarshal(w.Body.Bytes(), &breakdown)
+	if breakdown.Points != 100 || breakdown.RulesetVersion != "big-pairs" || len(breakdown.Rules) != 1 {
+		t.Errorf("GET /receipts/{id}/points/breakdown after a rescore = got %s, wanted 100 points from one rule", w.Body.String())
+	}
+
+	// The rescore is part of the receipt's history
+	revisions, _ := store.ListRevisions(created.ID)
+	last := revisions[len(revisions)-1]
+	if last.Action != models.RevisionRescore || *last.PointsBefore != 20 || *last.PointsAfter != 100 {
+		t.Errorf("ListRevisions after a rescore = got %+v, wanted a rescore from 20 to 100 points", last)
+	}
+
+	w = doAdminRequest(router, http.MethodGet, "/admin/rulesets", "")
+	var versions RulesetVersionsResponse
+	json.Unmarshal(w.Body.Bytes(), &versions)
+	if versions.Active != rules.DefaultRulesetVersion || len(versions.Versions) < 2 {
+		t.Errorf("GET /admin/rulesets = got %s, wanted the default ruleset active and big-pairs known", w.Body.String())
+	}
+}
+
+func TestBacktestRulesets(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	doRequest(router, http.MethodPost, "/receipts/process", strings.Replace(testReceiptJSON, "Target", "Walmart", 1))
+
+	candidate := `"candidateRuleset": {"version": "try-pairs", "rules": [{"type": "item_pairs", "params": {"pointsPerGroup": 100}}]}`
+
+	testTable := []struct {
+		path           string
+		body           string
+		expectedStatus int
+		expectedDelta  int
+		expectedCount  int
+	}{
+		// The receipts go from 20 and 21 points to 100 each
+		{"/admin/rulesets/backtest", `{` + candidate + `}`, http.StatusOK, 159, 2},
+		{"/admin/rulesets/backtest?retailer=walmart", `{` + candidate + `}`, http.StatusOK, 79, 1},
+		{"/admin/rulesets/backtest", `{"baseline": "default", "candidate": "default"}`, http.StatusOK, 0, 2},
+		{"/admin/rulesets/backtest", `{}`, http.StatusBadRequest, 0, 0},
+		{"/admin/rulesets/backtest", `{"candidate": "no-such-version"}`, http.StatusBadRequest, 0, 0},
+		{"/admin/rulesets/backtest", `{"candidateRuleset": {"rules": [{"type": "no_such_rule"}]}}`, http.StatusBadRequest, 0, 0},
+		{"/admin/rulesets/backtest?status=sideways", `{` + candidate + `}`, http.StatusBadRequest, 0, 0},
+		// A backtest covers every matching receipt, so paging is refused
+		{"/admin/rulesets/backtest?limit=10", `{` + candidate + `}`, http.StatusBadRequest, 0, 0},
+		{"/admin/rulesets/backtest?sort=points", `{` + candidate + `}`, http.StatusBadRequest, 0, 0},
+		{"/admin/rulesets/backtest?date_to=June", `{` + candidate + `}`, http.StatusBadRequest, 0, 0},
+	}
+
+	for _, test := range testTable {
+		w := doAdminRequest(router, http.MethodPost, test.path, test.body)
+		if w.Code != test.expectedStatus {
+			t.Errorf("POST %s with %s = got status %d, wanted %d: %s", test.path, test.body, w.Code, test.expectedStatus, w.Body.String())
+			continue
+		}
+		if test.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		var report scoring.BacktestReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		if report.TotalDelta != test.expectedDelta || report.Receipts != test.expectedCount {
+			t.Errorf("POST %s with %s = got a delta of %d over %d receipts, wanted %d over %d",
+				test.path, test.body, report.TotalDelta, report.Receipts, test.expectedDelta, test.expectedCount)
+		}
+	}
+
+	// Trying out a ruleset doesn't make it available or change any receipt
+	if _, ok := rules.RulesetByVersion("try-pairs"); ok {
+		t.Errorf("backtesting an inline ruleset registered its version")
+	}
+	receipts := listAllReceipts(t, router, "/receipts")
+	for _, receipt := range receipts {
+		if receipt.RulesetVersion != rules.DefaultRulesetVersion {
+			t.Errorf("receipt %s = got ruleset version %q after a backtest, wanted %q", receipt.ID, receipt.RulesetVersion, rules.DefaultRulesetVersion)
+		}
+	}
+}
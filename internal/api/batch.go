@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The most receipts a batch can hold when no limit is set
+const DefaultBatchLimit = 100
+
+// The room a batch body has for each receipt it can hold, a body bigger than
+// that many bytes per receipt is refused before it is read
+const BatchBytesPerReceipt = 64 << 10
+
+// What happened to one receipt of a batch
+const (
+	// The receipt was stored under a new id
+	BatchCreated = "created"
+	// The receipt was already processed, the id is the original's
+	BatchExisting = "existing"
+	// The receipt failed validation
+	BatchInvalid = "invalid"
+	// The receipt was already processed and duplicates are rejected
+	BatchDuplicate = "duplicate"
+	// The receipt was fine but wasn't stored because another one in an
+	// atomic batch failed
+	BatchSkipped = "skipped"
+)
+
+// Batch Entry Result Info
+// @Description What happened to one receipt of a batch
+type BatchEntryResult struct {
+	// Position of the receipt in the batch, starting from 0
+	Index int `json:"index" example:"0"`
+	// One of "created", "existing", "invalid", "duplicate" or "skipped"
+	Status string `json:"status" example:"created"`
+	// The id of the stored receipt, or of the original for duplicates
+	ID string `json:"id,omitempty" example:"adb6b560-0eef-42bc-9d16-df48f30e89b2"`
+	// The problem with each bad field of an invalid receipt
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// Batch Response Info
+// @Description The result of every receipt in a batch
+type BatchResponse struct {
+	// How many receipts were stored
+	Created int `json:"created" example:"2"`
+	// How many receipts were invalid or rejected as duplicates
+	Failed int `json:"failed" example:"1"`
+	// One result per receipt, in the order they were sent
+	Results []BatchEntryResult `json:"results"`
+}
+
+// CreateReceiptBatch	godoc
+// @Description 	Process many receipts in one request. The body is either a JSON array of receipts, or one receipt per line when sent as application/x-ndjson. Each receipt is validated on its own and gets its own result. With atomic=true nothing is stored unless every receipt is accepted.
+// @Summary				Process Receipt Batch
+// @Param					receipts body []models.Receipt true "the receipts to create"
+// @Param					atomic query bool false "Store nothing if any receipt fails"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
+// @Accept				application/json
+// @Accept				application/x-ndjson
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} BatchResponse "The result of every receipt"
+// @Failure				400 {object} ErrorMessage "The batch is malformed or has too many receipts"
+// @Failure				413 {object} ErrorMessage "The batch body is too large"
+// @Failure				422 {object} BatchResponse "The batch was atomic and at least one receipt failed, nothing was stored"
+// @Router				/receipts/process/batch [post]
+func (h *Handler) CreateReceiptBatch(c *gin.Context) {
+	atomic := false
+	if value := c.Query("atomic"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				ErrorMessage{Message: "atomic must be true or false"})
+			return
+		}
+		atomic = parsed
+	}
+
+	limit := h.BatchLimit
+	if limit <= 0 {
+		limit = DefaultBatchLimit
+	}
+
+	maxBytes := int64(limit) * BatchBytesPerReceipt
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	body, err := c.GetRawData()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+				ErrorMessage{Message: fmt.Sprintf("The batch is larger than the most allowed, %d bytes", maxBytes)})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	entries, err := splitBatch(c.ContentType(), body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	if len(entries) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The batch has no receipts"})
+		return
+	}
+	if len(entries) > limit {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: fmt.Sprintf("The batch has %d receipts, the most allowed is %d", len(entries), limit)})
+		return
+	}
+
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	response := BatchResponse{Results: make([]BatchEntryResult, len(entries))}
+	var receipts []models.Receipt
+	// Which result each receipt to store belongs to
+	var positions []int
+	// Receipts earlier in the batch, so that a receipt sent twice in one
+	// batch is a duplicate of the first
+	seen := map[string]string{}
+
+	for i, entry := range entries {
+		result := &response.Results[i]
+		result.Index = i
+
+		newReceipt, err := models.ParseReceipt(entry)
+		if err != nil {
+			var validationError *models.ValidationError
+			if !errors.As(err, &validationError) {
+				validationError = &models.ValidationError{Errors: []models.FieldError{
+					{Code: models.CodeInvalid, Message: err.Error()},
+				}}
+			}
+			result.Status, result.Errors = BatchInvalid, validationError.Errors
+			continue
+		}
+
+		newReceipt.Fingerprint = models.Fingerprint(newReceipt)
+
+		originalId, found := seen[newReceipt.Fingerprint]
+		if !found {
+			original, err := h.findOriginal(newReceipt.Fingerprint, "")
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError,
+					ErrorMessage{Message: err.Error()})
+				return
+			}
+			if original != nil {
+				originalId, found = original.ID, true
+			}
+		}
+
+		if found {
+			switch h.DuplicatePolicy {
+			case DuplicateReject:
+				result.Status, result.ID = BatchDuplicate, originalId
+				result.Errors = []models.FieldError{
+					{Code: models.CodeDuplicate, Message: "The receipt has already been processed"},
+				}
+				continue
+			case DuplicateExisting:
+				result.Status, result.ID = BatchExisting, originalId
+				continue
+			default:
+				newReceipt.DuplicateOf = originalId
+			}
+		}
+
+		// Ids are given out up front so later receipts in the batch can
+		// point at earlier ones
+		newReceipt.ID = models.NewReceiptID()
+		if !found {
+			seen[newReceipt.Fingerprint] = newReceipt.ID
+		}
+		result.Status, result.ID = BatchCreated, newReceipt.ID
+		receipts = append(receipts, newReceipt)
+		positions = append(positions, i)
+	}
+
+	for _, result := range response.Results {
+		if result.Status == BatchInvalid || result.Status == BatchDuplicate {
+			response.Failed++
+		}
+	}
+
+	if atomic && response.Failed > 0 {
+		for _, i := range positions {
+			response.Results[i].Status, response.Results[i].ID = BatchSkipped, ""
+		}
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	if len(receipts) > 0 {
+		if _, err := h.Store.AddReceipts(receipts); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+	}
+
+	// The receipts are stored by now, so a failure to record their history
+	// is only logged. Answering with an error would have the client send
+	// them again as duplicates.
+	for i := range receipts {
+		if err := h.recordRevision(c, models.RevisionCreate, nil, &receipts[i]); err != nil {
+			log.Printf("Could not record the history of receipt %s: %v", receipts[i].ID, err)
+		}
+		h.scoreReceipt(receipts[i].ID)
+	}
+	response.Created = len(receipts)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Split a batch body into its receipts. NDJSON bodies hold one receipt per
+// line, anything else has to be a JSON array.
+func splitBatch(contentType string, body []byte) ([]json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if mediaType == "application/x-ndjson" || mediaType == "application/ndjson" {
+		var entries []json.RawMessage
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			entries = append(entries, json.RawMessage(bytes.Clone(line)))
+		}
+		return entries, scanner.Err()
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, errors.New("the body must be a JSON array of receipts: " + err.Error())
+	}
+	return entries, nil
+}
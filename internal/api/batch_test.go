@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const invalidReceiptJSON = `{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01", "items": [], "total": "1.00"}`
+
+// Post a batch with the given content type and return the decoded response
+func doBatchRequest(router *gin.Engine, path string, contentType string, body string) (int, BatchResponse) {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response BatchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestCreateReceiptBatch(t *testing.T) {
+	other := strings.Replace(testReceiptJSON, "13:01", "14:01", 1)
+
+	testTable := []struct {
+		name             string
+		path             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedStatuses []string
+		expectedStored   int
+	}{
+		{"array", "/receipts/process/batch", "application/json",
+			"[" + testReceiptJSON + "," + other + "]",
+			http.StatusOK, []string{BatchCreated, BatchCreated}, 2},
+		{"ndjson", "/receipts/process/batch", "application/x-ndjson",
+			strings.ReplaceAll(testReceiptJSON, "\n", "") + "\n\n" + strings.ReplaceAll(other, "\n", "") + "\n",
+			http.StatusOK, []string{BatchCreated, BatchCreated}, 2},
+		{"mixed", "/receipts/process/batch", "application/json",
+			"[" + testReceiptJSON + "," + invalidReceiptJSON + "," + testReceiptJSON + "]",
+			http.StatusOK, []string{BatchCreated, BatchInvalid, BatchCreated}, 2},
+		{"atomic", "/receipts/process/batch?atomic=true", "application/json",
+			"[" + testReceiptJSON + "," + invalidReceiptJSON + "]",
+			http.StatusUnprocessableEntity, []string{BatchSkipped, BatchInvalid}, 0},
+	}
+
+	for _, test := range testTable {
+		store := models.NewMemoryStore()
+		router := newTestRouter(store)
+
+		status, response := doBatchRequest(router, test.path, test.contentType, test.body)
+		if status != test.expectedStatus {
+			t.Errorf("%s: POST %s = got status %d, wanted %d", test.name, test.path, status, test.expectedStatus)
+		}
+
+		if len(response.Results) != len(test.expectedStatuses) {
+			t.Errorf("%s: got %d results, wanted %d", test.name, len(response.Results), len(test.expectedStatuses))
+			continue
+		}
+		for i, result := range response.Results {
+			if result.Index != i || result.Status != test.expectedStatuses[i] {
+				t.Errorf("%s: result %d = got %+v, wanted status %q", test.name, i, result, test.expectedStatuses[i])
+			}
+			if result.Status == BatchInvalid && len(result.Errors) == 0 {
+				t.Errorf("%s: result %d is invalid but has no errors", test.name, i)
+			}
+		}
+
+		if receipts, _ := store.ListReceipts(); len(receipts) != test.expectedStored {
+			t.Errorf("%s: got %d stored receipts, wanted %d", test.name, len(receipts), test.expectedStored)
+		}
+		if response.Created != test.expectedStored {
+			t.Errorf("%s: got created %d, wanted %d", test.name, response.Created, test.expectedStored)
+		}
+	}
+}
+
+func TestCreateReceiptBatchDuplicates(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	// The second copy in the batch is marked as a duplicate of the first
+	_, response := doBatchRequest(router, "/receipts/process/batch", "application/json",
+		"["+testReceiptJSON+","+testReceiptJSON+"]")
+	if len(response.Results) != 2 {
+		t.Fatalf("got %d results, wanted 2", len(response.Results))
+	}
+	originalId := response.Results[0].ID
+	duplicate, _ := store.GetReceipt(response.Results[1].ID)
+	if duplicate == nil || duplicate.DuplicateOf != originalId {
+		t.Errorf("GetReceipt = got %+v, wanted a duplicate of %q", duplicate, originalId)
+	}
+
+	// Under the reject policy a receipt already in the store fails
+	handler := NewHandler(store)
+	handler.SetDuplicatePolicy(DuplicateReject)
+	router = gin.New()
+	router.POST("/receipts/process/batch", handler.CreateReceiptBatch)
+
+	_, response = doBatchRequest(router, "/receipts/process/batch", "application/json", "["+testReceiptJSON+"]")
+	if response.Failed != 1 || response.Results[0].Status != BatchDuplicate || response.Results[0].ID != originalId {
+		t.Errorf("got %+v, wanted one duplicate of %q", response, originalId)
+	}
+}
+
+func TestCreateReceiptBatchLimit(t *testing.T) {
+	store := models.NewMemoryStore()
+	handler := NewHandler(store)
+	handler.BatchLimit = 2
+	router := gin.New()
+	router.POST("/receipts/process/batch", handler.CreateReceiptBatch)
+
+	testTable := []struct {
+		body           string
+		expectedStatus int
+	}{
+		{"[" + strings.Repeat(testReceiptJSON+",", 2) + testReceiptJSON + "]", http.StatusBadRequest},
+		{"[]", http.StatusBadRequest},
+		{`{"retailer": "Target"}`, http.StatusBadRequest},
+		{"[" + testReceiptJSON + "]", http.StatusOK},
+		// Bodies bigger than two receipts' worth are refused before they are read
+		{"[" + testReceiptJSON + strings.Repeat(" ", 2*BatchBytesPerReceipt) + "]", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range testTable {
+		status, _ := doBatchRequest(router, "/receipts/process/batch", "application/json", test.body)
+		if status != test.expectedStatus {
+			t.Errorf("POST /receipts/process/batch with %.40q = got status %d, wanted %d", test.body, status, test.expectedStatus)
+		}
+	}
+}
+
+// A store that can't record history
+type noHistoryStore struct {
+	*models.MemoryStore
+}
+
+func (s *noHistoryStore) AddRevision(revision models.Revision) (models.Revision, error) {
+	return models.Revision{}, errors.New("the history is unavailable")
+}
+
+func TestCreateReceiptBatchWithoutHistory(t *testing.T) {
+	store := &noHistoryStore{MemoryStore: models.NewMemoryStore()}
+	handler := NewHandler(store)
+	router := gin.New()
+	router.POST("/receipts/process/batch", handler.CreateReceiptBatch)
+
+	// The receipts are stored, so the client mustn't be told to send them again
+	status, response := doBatchRequest(router, "/receipts/process/batch", "application/json", "["+testReceiptJSON+"]")
+	if status != http.StatusOK || response.Created != 1 {
+		t.Fatalf("POST /receipts/process/batch without history = got status %d and %d created, wanted %d and 1", status, response.Created, http.StatusOK)
+	}
+	if _, err := store.GetReceipt(response.Results[0].ID); err != nil {
+		t.Errorf("GetReceipt of the batch receipt got an error: %q", err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The header that names who is making a change, recorded in the history. It
+// is whatever the caller says, nothing checks it, so it is a note for people
+// reading the history and not proof of who made the change.
+const ActorHeader = "X-Actor"
+
+// Who changes are recorded as when no actor header is sent
+const anonymousActor = "anonymous"
+
+// The longest actor name that is recorded, in bytes
+const maxActorLength = 255
+
+// GetReceiptHistory	godoc
+// @Description 	Returns every change made to a receipt, oldest first. Deleted receipts keep their history until they are purged.
+// @Summary				Receipt History
+// @Param					id path string true "The ID of the receipt"
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {array} models.Revision "The revisions of the receipt"
+// @Failure				404 {object} ErrorMessage "No history found for that id"
+// @Router				/receipts/{id}/history [get]
+func (h *Handler) GetReceiptHistory(c *gin.Context) {
+	revisions, err := h.Store.ListRevisions(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	if len(revisions) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound,
+			ErrorMessage{Message: "No history found for that receipt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetReceiptRevision	godoc
+// @Description 	Returns a single revision of a receipt, including the receipt as it was after the change
+// @Summary				Receipt Revision
+// @Param					id path string true "The ID of the receipt"
+// @Param					revision path int true "The number of the revision, counting up from 1"
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} models.Revision "The revision"
+// @Failure				404 {object} ErrorMessage "No revision found"
+// @Router				/receipts/{id}/history/{revision} [get]
+func (h *Handler) GetReceiptRevision(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound,
+			ErrorMessage{Message: models.ErrRevisionNotFound.Error()})
+		return
+	}
+
+	revision, err := h.Store.GetRevision(c.Param("id"), number)
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// Record a change to a receipt in its history. before is nil for a new
+// receipt and after is nil for a deleted one. An update that didn't change
+// any field isn't recorded.
+func (h *Handler) recordRevision(c *gin.Context, action string, before *models.Receipt, after *models.Receipt) error {
+	actor := c.GetHeader(ActorHeader)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	actor = truncateActor(actor)
+
+	changes := models.DiffReceipts(before, after)
+	if action == models.RevisionRescore && before.RulesetVersion != after.RulesetVersion {
+		changes = append(changes, models.FieldChange{Field: "rulesetVersion", Before: before.RulesetVersion, After: after.RulesetVersion})
+	}
+	if action == models.RevisionUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	revision := models.Revision{
+		Action:       action,
+		Actor:        actor,
+		CreatedAt:    time.Now(),
+		Changes:      changes,
+		PointsBefore: storedPoints(before),
+		PointsAfter:  storedPoints(after),
+	}
+
+	if after != nil {
+		revision.ReceiptID, revision.Receipt = after.ID, *after
+	} else {
+		revision.ReceiptID, revision.Receipt = before.ID, *before
+	}
+
+	_, err := h.Store.AddRevision(revision)
+	return err
+}
+
+// The points stored for a receipt. nil if there is no receipt or it hasn't
+// been scored, scoring it on the spot could disagree with the stored score
+// if the rules change in between.
+func storedPoints(receipt *models.Receipt) *int {
+	if receipt == nil || receipt.Status != models.StatusScored {
+		return nil
+	}
+	return receipt.Points
+}
+
+// Cut an actor name down to maxActorLength bytes without splitting a
+// character, invalid UTF-8 is replaced
+func truncateActor(actor string) string {
+	actor = strings.ToValidUTF8(actor, "\uFFFD")
+	if len(actor) <= maxActorLength {
+		return actor
+	}
+
+	end := maxActorLength
+	for end > 0 && !utf8.RuneStart(actor[end]) {
+		end--
+	}
+	return actor[:end]
+}
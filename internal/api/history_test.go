@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+func TestReceiptHistory(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	// Support moves the purchase time, as themselves
+	req := httptest.NewRequest(http.MethodPatch, "/receipts/"+created.ID, bytes.NewBufferString(`{"purchaseTime": "14:01"}`))
+	req.Header.Set(ActorHeader, "support@example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH /receipts/{id} = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+
+	// Sending the same date another way changes no field, so isn't recorded
+	if w = doRequest(router, http.MethodPatch, "/receipts/"+created.ID, `{"purchaseDate": "01/01/2022"}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH /receipts/{id} = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+
+	doRequest(router, http.MethodDelete, "/receipts/"+created.ID, "")
+
+	// The history outlives the delete
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/history", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /receipts/{id}/history = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+
+	var revisions []models.Revision
+	json.Unmarshal(w.Body.Bytes(), &revisions)
+	if len(revisions) != 3 {
+		t.Fatalf("GET /receipts/{id}/history = got %d revisions, wanted 3", len(revisions))
+	}
+
+	expected := []struct {
+		action       string
+		actor        string
+		pointsBefore int
+		pointsAfter  int
+	}{
+		// The receipt isn't scored yet when it is created
+		{models.RevisionCreate, "anonymous", -1, -1},
+		{models.RevisionUpdate, "support@example.com", 20, 30},
+		{models.RevisionDelete, "anonymous", 30, -1},
+	}
+	orMissing := func(points *int) int {
+		if points == nil {
+			return -1
+		}
+		return *points
+	}
+
+	for i, revision := range revisions {
+		want := expected[i]
+		if revision.Number != i+1 || revision.Action != want.action || revision.Actor != want.actor {
+			t.Errorf("revision %d = got #%d %s by %s, wanted #%d %s by %s", i, revision.Number, revision.Action, revision.Actor, i+1, want.action, want.actor)
+		}
+		if orMissing(revision.PointsBefore) != want.pointsBefore || orMissing(revision.PointsAfter) != want.pointsAfter {
+			t.Errorf("revision %d = got points %d to %d, wanted %d to %d", i, orMissing(revision.PointsBefore), orMissing(revision.PointsAfter), want.pointsBefore, want.pointsAfter)
+		}
+	}
+
+	update := revisions[1]
+	if len(update.Changes) != 1 || update.Changes[0] != (models.FieldChange{Field: "purchaseTime", Before: "13:01", After: "14:01"}) {
+		t.Errorf("update revision changes = got %+v, wanted only the purchase time", update.Changes)
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/history/2", "")
+	var revision models.Revision
+	json.Unmarshal(w.Body.Bytes(), &revision)
+	if w.Code != http.StatusOK || revision.Receipt.PurchaseTime != "14:01" || revision.Receipt.ID != created.ID {
+		t.Errorf("GET /receipts/{id}/history/2 = got %d %+v, wanted the receipt after the update", w.Code, revision.Receipt)
+	}
+
+	for _, path := range []string{"/history/4", "/history/0", "/history/latest"} {
+		if w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET /receipts/{id}%s = got status %d, wanted %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+	if w = doRequest(router, http.MethodGet, "/receipts/does-not-exist/history", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /receipts/{id}/history for a missing id = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestTruncateActor(t *testing.T) {
+	testTable := []struct {
+		actor    string
+		expected string
+	}{
+		{"support@example.com", "support@example.com"},
+		{strings.Repeat("a", 300), strings.Repeat("a", maxActorLength)},
+		// 127 two byte characters fit, the 128th would end past the limit
+		{"a" + strings.Repeat("é", 200), "a" + strings.Repeat("é", 127)},
+		{"bad \xff byte", "bad \uFFFD byte"},
+	}
+
+	for _, test := range testTable {
+		got := truncateActor(test.actor)
+		if got != test.expected || !utf8.ValidString(got) {
+			t.Errorf("truncateActor(%.20q) = got %.20q (%d bytes), wanted %.20q (%d bytes)", test.actor, got, len(got), test.expected, len(test.expected))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The header clients send to make retrying a request safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// How long an Idempotency-Key is remembered unless configured otherwise
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// The longest Idempotency-Key accepted
+const maxIdempotencyKeyLength = 255
+
+// Responds for a request that reuses an idempotency key: with the original
+// response if the body is the same and its receipt is still there, or an
+// error if it isn't. Returns false when the key hasn't been seen and the
+// request should be handled normally.
+func (h *Handler) replayIdempotentRequest(c *gin.Context, key string, body []byte) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The Idempotency-Key header can't be longer than 255 characters"})
+		return true
+	}
+
+	record, err := h.Store.GetIdempotencyKey(key)
+	if errors.Is(err, models.ErrIdempotencyKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return true
+	}
+
+	if h.idempotencyExpired(*record) {
+		return false
+	}
+
+	if record.RequestHash != hashRequestBody(body) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			ErrorMessage{Message: "The Idempotency-Key has already been used with a different request body"})
+		return true
+	}
+
+	// The id would only lead to a 404 once the receipt is deleted
+	_, err = h.Store.GetReceipt(record.ReceiptID)
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		c.AbortWithStatusJSON(http.StatusGone,
+			ErrorMessage{Message: "The receipt created with this Idempotency-Key has since been deleted"})
+		return true
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return true
+	}
+
+	c.JSON(record.StatusCode, CreatedReceiptResponse{ID: record.ReceiptID})
+	return true
+}
+
+// Remember the response to a request sent with an idempotency key
+func (h *Handler) saveIdempotentResponse(key string, body []byte, status int, receiptId string) error {
+	return h.Store.SaveIdempotencyKey(models.IdempotencyRecord{
+		Key:         key,
+		RequestHash: hashRequestBody(body),
+		StatusCode:  status,
+		ReceiptID:   receiptId,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// Whether the record is older than the idempotency window
+func (h *Handler) idempotencyExpired(record models.IdempotencyRecord) bool {
+	return h.IdempotencyWindow > 0 && time.Since(record.CreatedAt) > h.IdempotencyWindow
+}
+
+// Remove expired idempotency keys from the store every interval until the
+// context is cancelled
+func (h *Handler) PurgeIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if h.IdempotencyWindow <= 0 {
+				continue
+			}
+			purged, err := h.Store.PurgeIdempotencyKeys(time.Now().Add(-h.IdempotencyWindow))
+			if err != nil {
+				log.Printf("Could not purge expired idempotency keys: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d expired idempotency keys", purged)
+			}
+		}
+	}
+}
+
+func hashRequestBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
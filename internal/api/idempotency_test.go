@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Post a receipt with the given idempotency key
+func doIdempotentRequest(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	w := doIdempotentRequest(router, "retry-1", testReceiptJSON)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /receipts/process = got status %d, wanted %d", w.Code, http.StatusCreated)
+	}
+	original := w.Body.String()
+
+	// A retry gets the original response and stores nothing new
+	w = doIdempotentRequest(router, "retry-1", testReceiptJSON)
+	if w.Code != http.StatusCreated || w.Body.String() != original {
+		t.Errorf("POST /receipts/process retry = got %d %s, wanted %d %s", w.Code, w.Body.String(), http.StatusCreated, original)
+	}
+	if receipts, _ := store.ListReceipts(); len(receipts) != 1 {
+		t.Errorf("got %d stored receipts after a retry, wanted 1", len(receipts))
+	}
+
+	// Reusing the key for a different receipt is an error
+	other := strings.Replace(testReceiptJSON, "13:01", "13:02", 1)
+	w = doIdempotentRequest(router, "retry-1", other)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /receipts/process with a reused key = got status %d, wanted %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	// A new key is a new request
+	w = doIdempotentRequest(router, "retry-2", other)
+	if w.Code != http.StatusCreated {
+		t.Errorf("POST /receipts/process with a new key = got status %d, wanted %d", w.Code, http.StatusCreated)
+	}
+
+	w = doIdempotentRequest(router, strings.Repeat("k", 256), other)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /receipts/process with a long key = got status %d, wanted %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Once the receipt is deleted a retry doesn't get its id back
+	var created CreatedReceiptResponse
+	json.Unmarshal([]byte(original), &created)
+	doRequest(router, http.MethodDelete, "/receipts/"+created.ID, "")
+	w = doIdempotentRequest(router, "retry-1", testReceiptJSON)
+	if w.Code != http.StatusGone {
+		t.Errorf("POST /receipts/process retry after a delete = got status %d, wanted %d", w.Code, http.StatusGone)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	store.SaveIdempotencyKey(models.IdempotencyRecord{
+		Key:         "old",
+		RequestHash: hashRequestBody([]byte("something else")),
+		StatusCode:  http.StatusCreated,
+		ReceiptID:   "gone",
+		CreatedAt:   time.Now().Add(-2 * DefaultIdempotencyWindow),
+	})
+
+	w := doIdempotentRequest(router, "old", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || created.ID == "gone" {
+		t.Errorf("POST /receipts/process with an expired key = got %d %s, wanted a new receipt", w.Code, w.Body.String())
+	}
+}
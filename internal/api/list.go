@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The most receipts a single page can hold
+const maxPageLimit = 1000
+
+// Build a receipt query from the query parameters of the request
+func parseReceiptQuery(c *gin.Context) (models.ReceiptQuery, error) {
+	query, err := models.ParseReceiptFilters(c.Query)
+	if err != nil {
+		return query, err
+	}
+	query.Sort, query.Cursor, query.Limit = c.Query("sort"), c.Query("cursor"), models.DefaultPageLimit
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return query, fmt.Errorf("limit must be a number from 1 to %d", maxPageLimit)
+		}
+		query.Limit = limit
+	}
+
+	switch query.Sort {
+	case "", models.SortCreated, models.SortDate, models.SortTotal, models.SortPoints:
+	default:
+		return query, fmt.Errorf("sort must be one of created, date, total or points")
+	}
+
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	return query, nil
+}
+
+// The parameters of a listing that pick a page rather than filter receipts
+var pagingParameters = []string{"sort", "order", "cursor", "limit"}
+
+// Build the filters of a receipt query from the query parameters of the
+// request, for endpoints that go over every matching receipt. Paging
+// parameters are refused rather than ignored.
+func parseReceiptFilters(c *gin.Context) (models.ReceiptQuery, error) {
+	for _, name := range pagingParameters {
+		if _, ok := c.GetQuery(name); ok {
+			return models.ReceiptQuery{}, fmt.Errorf("%s can't be used here, every matching receipt is included", name)
+		}
+	}
+	return models.ParseReceiptFilters(c.Query)
+}
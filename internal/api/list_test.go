@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// A receipt from the retailer, its points come from the retailer name and
+// the round dollar total
+func listTestReceipt(retailer string, date string, total string) string {
+	return fmt.Sprintf(`{
+		"retailer": %q,
+		"purchaseDate": %q,
+		"purchaseTime": "13:01",
+		"items": [{"shortDescription": "Gift Card", "price": %q}],
+		"total": %q
+	}`, retailer, date, total, total)
+}
+
+func TestGetReceiptsFilteredAndSorted(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+	for _, body := range []string{
+		listTestReceipt("Target", "2022-01-02", "10.00"),
+		listTestReceipt("Walgreens", "2022-01-01", "1.00"),
+		listTestReceipt("CVS", "2022-01-04", "20.00"),
+		listTestReceipt("Target", "2022-01-03", "5.25"),
+	} {
+		if w := doRequest(router, http.MethodPost, "/receipts/process", body); w.Code != http.StatusCreated {
+			t.Fatalf("POST /receipts/process = got status %d, wanted %d", w.Code, http.StatusCreated)
+		}
+	}
+
+	testTable := []struct {
+		path     string
+		expected []string
+	}{
+		{"/receipts?limit=1", []string{"Target 2022-01-02", "Walgreens 2022-01-01", "CVS 2022-01-04", "Target 2022-01-03"}},
+		{"/receipts?sort=date&order=desc&limit=3", []string{"CVS 2022-01-04", "Target 2022-01-03", "Target 2022-01-02", "Walgreens 2022-01-01"}},
+		{"/receipts?retailer=target&sort=total", []string{"Target 2022-01-03", "Target 2022-01-02"}},
+		{"/receipts?date_from=2022-01-02&date_to=2022-01-03", []string{"Target 2022-01-02", "Target 2022-01-03"}},
+		{"/receipts?min_total=5.00&max_total=10.00&limit=1", []string{"Target 2022-01-02", "Target 2022-01-03"}},
+		// Walgreens 9 + 75 + 25 + 6, Target 6 + 75 + 25 + 6, CVS 3 + 75 + 25, Target 6 + 25
+		{"/receipts?sort=points&order=desc&limit=1", []string{"Walgreens 2022-01-01", "Target 2022-01-02", "CVS 2022-01-04", "Target 2022-01-03"}},
+		{"/receipts?sort=points&retailer=Target&limit=1", []string{"Target 2022-01-03", "Target 2022-01-02"}},
+	}
+
+	for _, test := range testTable {
+		var got []string
+		for _, receipt := range listAllReceipts(t, router, test.path) {
+			got = append(got, receipt.Retailer+" "+receipt.PurchaseDate)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("GET %s = got %v, wanted %v", test.path, got, test.expected)
+		}
+	}
+
+	for _, path := range []string{
+		"/receipts?limit=0",
+		"/receipts?limit=1001",
+		"/receipts?sort=retailer",
+		"/receipts?order=up",
+		"/receipts?date_from=01/02/2022",
+		"/receipts?min_total=ten",
+		"/receipts?cursor=abc",
+		"/receipts?sort=points&cursor=abc",
+	} {
+		if w := doRequest(router, http.MethodGet, path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = got status %d, wanted %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Apply a JSON Merge Patch (RFC 7396) to a JSON document
+func applyMergePatch(document []byte, patch []byte) ([]byte, error) {
+	var target, changes any
+	if err := decodeJSON(document, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(patch, &changes); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(target, changes))
+}
+
+// Numbers are kept as they were written rather than turned into floats
+func decodeJSON(data []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// Objects in the patch are merged into the target, null removes a member,
+// and anything else replaces the target outright
+func mergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
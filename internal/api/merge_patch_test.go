@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// The examples from RFC 7396
+func TestApplyMergePatch(t *testing.T) {
+	testTable := []struct {
+		document string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Numbers are not rounded through a float
+		{`{}`, `{"total":12345678901234567890.01}`, `{"total":12345678901234567890.01}`},
+	}
+
+	for _, test := range testTable {
+		got, err := applyMergePatch([]byte(test.document), []byte(test.patch))
+		if err != nil {
+			t.Errorf("applyMergePatch(%s, %s) got an error: %q", test.document, test.patch, err.Error())
+			continue
+		}
+
+		var gotValue, expectedValue any
+		json.Unmarshal(got, &gotValue)
+		json.Unmarshal([]byte(test.expected), &expectedValue)
+		if !reflect.DeepEqual(gotValue, expectedValue) || (test.expected != "null" && gotValue == nil) {
+			t.Errorf("applyMergePatch(%s, %s) = got %s, wanted %s", test.document, test.patch, got, test.expected)
+		}
+	}
+
+	if _, err := applyMergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Errorf("applyMergePatch with a malformed patch should have returned an error")
+	}
+}
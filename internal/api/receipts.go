@@ -1,14 +1,69 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
 	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
 
 	"github.com/gin-gonic/gin"
 )
 
+// The ways a receipt that has already been processed can be handled
+const (
+	// Respond with a 409 and the id of the original receipt
+	DuplicateReject = "reject"
+	// Respond with the id of the original receipt without storing anything
+	DuplicateExisting = "existing"
+	// Store the receipt, marked as a duplicate of the original
+	DuplicateMark = "mark"
+)
+
+// Handles the receipt endpoints using the given receipt store
+type Handler struct {
+	Store models.ReceiptStore
+	// What to do with duplicate receipts, DuplicateMark when empty
+	DuplicatePolicy string
+	// How long an Idempotency-Key is remembered
+	IdempotencyWindow time.Duration
+	// The most receipts a batch can hold, DefaultBatchLimit when 0
+	BatchLimit int
+	// Scores new and changed receipts in the background. When nil they are
+	// scored before the request finishes.
+	Queue *scoring.Queue
+	// The admin token, needed to simulate points with an inline ruleset.
+	// Inline rulesets are turned off when it is empty.
+	AdminToken string
+
+	// Makes looking for a duplicate and writing the receipt one step
+	writeMu sync.Mutex
+}
+
+// Create a handler that reads and writes receipts to store
+func NewHandler(store models.ReceiptStore) *Handler {
+	return &Handler{Store: store, IdempotencyWindow: DefaultIdempotencyWindow, BatchLimit: DefaultBatchLimit}
+}
+
+// Change how duplicate receipts are handled
+func (h *Handler) SetDuplicatePolicy(policy string) error {
+	switch policy {
+	case DuplicateReject, DuplicateExisting, DuplicateMark:
+		h.DuplicatePolicy = policy
+		return nil
+	}
+
+	return fmt.Errorf("unknown duplicate policy %q, expected %s, %s or %s",
+		policy, DuplicateReject, DuplicateExisting, DuplicateMark)
+}
+
 // Error Message Info
 // @Description Error Message Information
 type ErrorMessage struct {
@@ -16,10 +71,50 @@ type ErrorMessage struct {
 	Message string `json:"message"`
 }
 
+// Validation Error Info
+// @Description Every field of the receipt that is invalid
+type ValidationErrorResponse struct {
+	// The message
+	Message string `json:"message" example:"The receipt is invalid"`
+	// The problem with each bad field
+	Errors []models.FieldError `json:"errors"`
+}
+
+// Duplicate Receipt Info
+// @Description The receipt was already processed
+type DuplicateReceiptResponse struct {
+	// The message
+	Message string `json:"message" example:"The receipt has already been processed"`
+	// The id of the original receipt
+	ID string `json:"id" example:"adb6b560-0eef-42bc-9d16-df48f30e89b2"`
+}
+
+// @Description A page of receipts
+type ReceiptListResponse struct {
+	// The receipts on this page
+	Receipts []models.Receipt `json:"receipts"`
+	// Pass as the cursor to get the next page, missing on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 // @Description Receipt points awarded response with points
 type ReceiptPointsResponse struct {
 	// The points awarded for the receipt
 	Points int `json:"points" binding:"required"`
+	// The version of the ruleset that awarded the points
+	RulesetVersion string `json:"rulesetVersion,omitempty" example:"default"`
+}
+
+// @Description Receipt points breakdown response, with the points and reason of every rule
+type ReceiptPointsBreakdownResponse struct {
+	// The receipt id
+	ID string `json:"id"`
+	// The total points awarded for the receipt
+	Points int `json:"points"`
+	// The version of the ruleset that awarded the points
+	RulesetVersion string `json:"rulesetVersion,omitempty" example:"default"`
+	// Every rule that was evaluated, in order, including those that awarded no points
+	Rules []rules.RuleResult `json:"rules"`
 }
 
 // @Description Receipt processed response with id
@@ -29,14 +124,44 @@ type CreatedReceiptResponse struct {
 }
 
 // GetReceipts		godoc
-// @Description 	Get all of the receipts, no limit, no pagination
+// @Description 	Get a page of the receipts, optionally filtered and sorted. Pass the next_cursor of a page as the cursor to get the page after it.
 // @Summary				Get All Receipts
+// @Param					limit query int false "The most receipts to return, 100 by default and at most 1000"
+// @Param					cursor query string false "The next_cursor of the previous page"
+// @Param					retailer query string false "Only receipts from this retailer, ignoring letter case"
+// @Param					date_from query string false "Only receipts purchased on or after this date, YYYY-MM-DD"
+// @Param					date_to query string false "Only receipts purchased on or before this date, YYYY-MM-DD"
+// @Param					min_total query string false "Only receipts with at least this total, e.g. 10.00"
+// @Param					max_total query string false "Only receipts with at most this total, e.g. 50.00"
+// @Param					status query string false "Only receipts with this scoring status" Enums(pending, scored, failed)
+// @Param					sort query string false "The order of the receipts" Enums(created, date, total, points)
+// @Param					order query string false "The direction of the sort" Enums(asc, desc)
 // @Produce				application/json
 // @Tags					reciepts
-// @Success				200 {array} []models.Receipt{}
+// @Success				200 {object} ReceiptListResponse
+// @Failure				400 {object} ErrorMessage "A query parameter is invalid"
 // @Router				/receipts [get]
-func GetReceipts(c *gin.Context) {
-	c.IndentedJSON(http.StatusOK, models.GetReceipts())
+func (h *Handler) GetReceipts(c *gin.Context) {
+	query, err := parseReceiptQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	page, err := h.Store.QueryReceipts(query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The cursor is invalid or was made for a different sort"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, ReceiptListResponse{Receipts: page.Receipts, NextCursor: page.NextCursor})
 }
 
 // GetReceipt			godoc
@@ -48,11 +173,9 @@ func GetReceipts(c *gin.Context) {
 // @Success				200 {object} models.Receipt{} "success"
 // @Failure				404 {object} ErrorMessage
 // @Router				/receipts/{id} [get]
-func GetReceipt(c *gin.Context) {
-	receipt, error := models.GetReceiptById(c.Param("id"))
-
-	if error != nil {
-		c.IndentedJSON(http.StatusNotFound, ErrorMessage{Message: error.Error()})
+func (h *Handler) GetReceipt(c *gin.Context) {
+	receipt, ok := h.findReceipt(c)
+	if !ok {
 		return
 	}
 
@@ -60,60 +183,491 @@ func GetReceipt(c *gin.Context) {
 }
 
 // GetReceipt			godoc
-// @Description 	Returns the points awarded for the receipt
+// @Description 	Returns the points awarded for the receipt. Receipts are scored in the background, until then the scoring status is returned with a 202.
 // @Summary				Calculate Receipt Points
 // @Param					id path string true "The ID of the receipt"
 // @Produce				application/json
 // @Tags					reciepts
-// @Success				201 {object} ReceiptPointsResponse "The number of points awarded"
+// @Success				200 {object} ReceiptPointsResponse "The number of points awarded"
+// @Success				202 {object} ReceiptStatusResponse "The receipt hasn't been scored yet"
+// @Failure				400 {object} ErrorMessage "The receipt could not be scored"
 // @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Failure				422 {object} ErrorMessage "The receipt total doesn't match its items"
 // @Router				/receipts/{id}/points [get]
-func GetReceiptPoints(c *gin.Context) {
-	receipt, error := models.GetReceiptById(c.Param("id"))
-	if error != nil {
-		c.IndentedJSON(http.StatusNotFound, ErrorMessage{Message: error.Error()})
+func (h *Handler) GetReceiptPoints(c *gin.Context) {
+	receipt, ok := h.findScoreableReceipt(c)
+	if !ok {
 		return
 	}
 
-	points, err := rules.CalculatePoints(*receipt)
-
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest,
-			ErrorMessage{Message: err.Error()})
+	if !requireScored(c, receipt) {
 		return
 	}
 
 	c.JSON(http.StatusOK,
-		ReceiptPointsResponse{Points: points})
+		ReceiptPointsResponse{Points: *receipt.Points, RulesetVersion: receipt.RulesetVersion})
+}
+
+// GetReceiptPointsBreakdown	godoc
+// @Description 	Returns the points awarded for the receipt, and how each rule contributed to them when it was scored
+// @Summary				Explain Receipt Points
+// @Param					id path string true "The ID of the receipt"
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} ReceiptPointsBreakdownResponse "The points awarded by each rule"
+// @Success				202 {object} ReceiptStatusResponse "The receipt hasn't been scored yet"
+// @Failure				400 {object} ErrorMessage "The receipt could not be scored"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Failure				422 {object} ErrorMessage "The receipt total doesn't match its items"
+// @Router				/receipts/{id}/points/breakdown [get]
+func (h *Handler) GetReceiptPointsBreakdown(c *gin.Context) {
+	receipt, ok := h.findScoreableReceipt(c)
+	if !ok {
+		return
+	}
+
+	if !requireScored(c, receipt) {
+		return
+	}
+
+	response := ReceiptPointsBreakdownResponse{
+		ID:             receipt.ID,
+		Points:         *receipt.Points,
+		RulesetVersion: receipt.RulesetVersion,
+		Rules:          []rules.RuleResult{},
+	}
+	// Receipts scored under the legacy version have no breakdown
+	if len(receipt.Breakdown) == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err := json.Unmarshal(receipt.Breakdown, &response.Rules); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: "The stored breakdown could not be read: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // CreateReceipt	godoc
-// @Description 	Create a receipt and add it to our memory array of receipts. Will not save the id if given one and will always make a new one.
+// @Description 	Create a receipt and add it to the receipt store. Will not save the id if given one and will always make a new one.
 // @Summary				Process Receipt
 // @Param					receipt body models.Receipt true "new receipt to create"
+// @Param					Idempotency-Key header string false "A unique key for the request, retries with the same key and body get the original response"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
 // @Accept				application/json
 // @Produce				application/json
 // @Tags					reciepts
 // @Success				201 {object} CreatedReceiptResponse "Returns the ID assigned to the receipt"
-// @Failure				400 {object} ErrorMessage "The receipt is invalid"
+// @Success				200 {object} CreatedReceiptResponse "The receipt was already processed, returns the ID of the original"
+// @Failure				400 {object} ValidationErrorResponse "The receipt is invalid"
+// @Failure				409 {object} DuplicateReceiptResponse "The receipt was already processed"
+// @Failure				410 {object} ErrorMessage "The receipt created with the Idempotency-Key has since been deleted"
+// @Failure				422 {object} ErrorMessage "The Idempotency-Key was already used with a different body"
 // @Router				/receipts/process [post]
-func CreateReceipt(c *gin.Context) {
-	var newReceipt models.Receipt
+func (h *Handler) CreateReceipt(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key != "" && h.replayIdempotentRequest(c, key, body) {
+		return
+	}
+
+	newReceipt, err := models.ParseReceipt(body)
+	if err != nil {
+		abortWithValidationError(c, err)
+		return
+	}
+
+	newReceipt.Fingerprint = models.Fingerprint(newReceipt)
+
+	original, err := h.findOriginal(newReceipt.Fingerprint, "")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	status := http.StatusCreated
+	var id string
+
+	if original != nil {
+		switch h.DuplicatePolicy {
+		case DuplicateReject:
+			c.AbortWithStatusJSON(http.StatusConflict, DuplicateReceiptResponse{
+				Message: "The receipt has already been processed",
+				ID:      original.ID,
+			})
+			return
+		case DuplicateExisting:
+			status, id = http.StatusOK, original.ID
+		default:
+			newReceipt.DuplicateOf = original.ID
+		}
+	}
+
+	if id == "" {
+		// Add created receipt to the store
+		id, err = h.Store.AddReceipt(newReceipt)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+
+		newReceipt.ID = id
+		if err := h.recordRevision(c, models.RevisionCreate, nil, &newReceipt); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+		h.scoreReceipt(id)
+	}
+
+	if key != "" {
+		if err := h.saveIdempotentResponse(key, body, status, id); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+	}
 
-	if err := c.BindJSON(&newReceipt); err != nil {
+	c.JSON(status, CreatedReceiptResponse{ID: id})
+}
+
+// UpdateReceipt	godoc
+// @Description 	Replace the contents of a receipt. The receipt is validated the same way as a new one.
+// @Summary				Update Receipt
+// @Param					id path string true "The ID of the receipt"
+// @Param					receipt body models.Receipt true "the new contents of the receipt"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
+// @Accept				application/json
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} models.Receipt "The updated receipt"
+// @Failure				400 {object} ValidationErrorResponse "The receipt is invalid"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Failure				409 {object} DuplicateReceiptResponse "The change would make the receipt a duplicate of another"
+// @Router				/receipts/{id} [put]
+func (h *Handler) UpdateReceipt(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	existing, ok := h.findReceipt(c)
+	if !ok {
+		return
+	}
+
+	h.replaceReceipt(c, existing, body)
+}
+
+// PatchReceipt	godoc
+// @Description 	Change some of the fields of a receipt with a JSON Merge Patch (RFC 7396). Fields set to null are removed, and items can only be replaced as a whole list. Only the retailer, purchase date, purchase time, time zone, total and items can be patched. The patched receipt is validated the same way as a new one.
+// @Summary				Patch Receipt
+// @Param					id path string true "The ID of the receipt"
+// @Param					patch body object true "the fields to change"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
+// @Accept				application/merge-patch+json
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} models.Receipt "The updated receipt"
+// @Failure				400 {object} ValidationErrorResponse "The patch changes a read only field or the patched receipt is invalid"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Failure				409 {object} DuplicateReceiptResponse "The change would make the receipt a duplicate of another"
+// @Router				/receipts/{id} [patch]
+func (h *Handler) PatchReceipt(c *gin.Context) {
+	patch, err := c.GetRawData()
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest,
 			ErrorMessage{Message: err.Error()})
 		return
 	}
 
-	if _, err := models.CheckReceipt(newReceipt); err != nil {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	existing, ok := h.findReceipt(c)
+	if !ok {
+		return
+	}
+
+	if err := checkPatchFields(patch); err != nil {
+		abortWithValidationError(c, err)
+		return
+	}
+
+	original, err := json.Marshal(existing)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	body, err := applyMergePatch(original, patch)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: "The patch must be valid JSON: " + err.Error()})
+		return
+	}
+
+	h.replaceReceipt(c, existing, body)
+}
+
+// DeleteReceipt	godoc
+// @Description 	Delete a receipt. It is no longer returned by the API but is kept until it is purged.
+// @Summary				Delete Receipt
+// @Param					id path string true "The ID of the receipt"
+// @Param					X-Actor header string false "Who is making the change, recorded in the history as given without being checked"
+// @Tags					reciepts
+// @Success				204 "The receipt was deleted"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Router				/receipts/{id} [delete]
+func (h *Handler) DeleteReceipt(c *gin.Context) {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	existing, ok := h.findReceipt(c)
+	if !ok {
+		return
+	}
+
+	err := h.Store.DeleteReceipt(existing.ID)
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
 			ErrorMessage{Message: err.Error()})
 		return
 	}
 
-	// Add created receipt to list of receipts
-	newId := models.AddToReceipts(newReceipt)
+	if err := h.recordRevision(c, models.RevisionDelete, existing, nil); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Validate the new contents of a receipt and store them, responding with the
+// updated receipt. Must be called with writeMu held.
+func (h *Handler) replaceReceipt(c *gin.Context, existing *models.Receipt, body []byte) {
+	id := existing.ID
+
+	receipt, err := models.ParseReceipt(body)
+	if err != nil {
+		abortWithValidationError(c, err)
+		return
+	}
+
+	// The fingerprint, discrepancy and duplicate marker all depend on the
+	// contents, so they are worked out again
+	receipt.Fingerprint = models.Fingerprint(receipt)
+
+	// A date or time that didn't change keeps what was first sent for it
+	if receipt.PurchaseDate == existing.PurchaseDate && receipt.RawPurchaseDate == "" {
+		receipt.RawPurchaseDate = existing.RawPurchaseDate
+	}
+	if receipt.PurchaseTime == existing.PurchaseTime && receipt.RawPurchaseTime == "" {
+		receipt.RawPurchaseTime = existing.RawPurchaseTime
+	}
+
+	// Nothing to change, the receipt keeps its score and history as they are
+	if sameContents(existing, &receipt) {
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+
+	original, err := h.findOriginal(receipt.Fingerprint, id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	if original != nil {
+		if h.DuplicatePolicy == DuplicateReject || h.DuplicatePolicy == DuplicateExisting {
+			c.AbortWithStatusJSON(http.StatusConflict, DuplicateReceiptResponse{
+				Message: "The receipt would be a duplicate of another receipt",
+				ID:      original.ID,
+			})
+			return
+		}
+		receipt.DuplicateOf = original.ID
+	}
+
+	err = h.Store.UpdateReceipt(id, receipt)
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	h.scoreReceipt(id)
+
+	updated, err := h.Store.GetReceipt(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	if err := h.recordRevision(c, models.RevisionUpdate, existing, updated); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Whether a receipt's new contents are the same as what is stored, down to
+// the letter case of the text and the order of the items
+func sameContents(existing *models.Receipt, receipt *models.Receipt) bool {
+	return existing.Fingerprint == receipt.Fingerprint &&
+		existing.Retailer == receipt.Retailer &&
+		existing.RawPurchaseDate == receipt.RawPurchaseDate &&
+		existing.RawPurchaseTime == receipt.RawPurchaseTime &&
+		reflect.DeepEqual(existing.Items, receipt.Items)
+}
+
+// The fields of a receipt a patch can change, the rest are worked out from them
+var patchableFields = map[string]bool{
+	"retailer":     true,
+	"purchaseDate": true,
+	"purchaseTime": true,
+	"timeZone":     true,
+	"total":        true,
+	"items":        true,
+}
+
+// The fields of a receipt that are only ever worked out, never sent
+var readOnlyFields = map[string]bool{
+	"id":              true,
+	"rawPurchaseDate": true,
+	"rawPurchaseTime": true,
+	"discrepancy":     true,
+	"fingerprint":     true,
+	"duplicateOf":     true,
+	"status":          true,
+	"points":          true,
+	"scoreError":      true,
+	"rulesetVersion":  true,
+}
+
+// Check that a merge patch only changes fields that can be patched. Patches
+// that aren't objects are left for the merge to report.
+func checkPatchFields(patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil
+	}
+
+	var errs []models.FieldError
+	for field := range fields {
+		switch {
+		case patchableFields[field]:
+		case readOnlyFields[field]:
+			errs = append(errs, models.FieldError{Field: field, Code: models.CodeInvalid, Message: "is read only and can't be patched"})
+		default:
+			errs = append(errs, models.FieldError{Field: field, Code: models.CodeInvalid, Message: "is not a field of a receipt"})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// Report the fields in a fixed order
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return &models.ValidationError{Errors: errs}
+}
+
+// Returns the earliest receipt with the fingerprint other than the receipt
+// with the id skip, or nil if there isn't one
+func (h *Handler) findOriginal(fingerprint string, skip string) (*models.Receipt, error) {
+	original, err := h.Store.FindByFingerprint(fingerprint)
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The receipt being changed is itself the earliest, anything else with
+	// the fingerprint is a duplicate of it rather than the other way around
+	if original.ID == skip {
+		return nil, nil
+	}
+	return original, nil
+}
+
+// Looks up the receipt named by the id path parameter. If it can't be found
+// the error response is written and false is returned.
+func (h *Handler) findReceipt(c *gin.Context) (*models.Receipt, bool) {
+	receipt, err := h.Store.GetReceipt(c.Param("id"))
+
+	if errors.Is(err, models.ErrReceiptNotFound) {
+		c.IndentedJSON(http.StatusNotFound, ErrorMessage{Message: err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			ErrorMessage{Message: err.Error()})
+		return nil, false
+	}
+
+	return receipt, true
+}
+
+// Looks up the receipt like findReceipt, but also responds with a 422 if
+// the reconciliation policy doesn't allow it to be scored
+func (h *Handler) findScoreableReceipt(c *gin.Context) (*models.Receipt, bool) {
+	receipt, ok := h.findReceipt(c)
+	if !ok {
+		return nil, false
+	}
+
+	if models.ExcludedFromScoring(*receipt) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			ErrorMessage{Message: "The receipt total doesn't match the sum of its item prices"})
+		return nil, false
+	}
+
+	return receipt, true
+}
+
+// Responds with the list of bad fields if err is a validation error, or
+// with just the message otherwise
+func abortWithValidationError(c *gin.Context, err error) {
+	var validationError *models.ValidationError
+	if errors.As(err, &validationError) {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ValidationErrorResponse{Message: "The receipt is invalid", Errors: validationError.Errors})
+		return
+	}
 
-	c.JSON(http.StatusCreated, CreatedReceiptResponse{ID: newId})
+	c.AbortWithStatusJSON(http.StatusBadRequest,
+		ErrorMessage{Message: err.Error()})
 }
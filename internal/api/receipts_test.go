@@ -0,0 +1,512 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testReceiptJSON = `{
+	"retailer": "Target",
+	"purchaseDate": "2022-01-01",
+	"purchaseTime": "13:01",
+	"items": [
+		{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+		{"shortDescription": "Emils Cheese Pizza", "price": "12.25"}
+	],
+	"total": "18.74"
+}`
+
+const testAdminToken = "let-me-in"
+
+// Build a router with the receipt routes backed by store
+func newTestRouter(store models.ReceiptStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(store)
+	handler.AdminToken = testAdminToken
+	router := gin.New()
+	router.GET("/receipts", handler.GetReceipts)
+	router.GET("/receipts/:id", handler.GetReceipt)
+	router.GET("/receipts/:id/points", handler.GetReceiptPoints)
+	router.GET("/receipts/:id/points/breakdown", handler.GetReceiptPointsBreakdown)
+	router.GET("/receipts/:id/status", handler.GetReceiptStatus)
+	router.POST("/receipts/process", handler.CreateReceipt)
+	router.POST("/receipts/process/batch", handler.CreateReceiptBatch)
+	router.PUT("/receipts/:id", handler.UpdateReceipt)
+	router.PATCH("/receipts/:id", handler.PatchReceipt)
+	router.DELETE("/receipts/:id", handler.DeleteReceipt)
+	router.GET("/receipts/:id/history", handler.GetReceiptHistory)
+	router.GET("/receipts/:id/history/:revision", handler.GetReceiptRevision)
+	router.POST("/points/simulate", handler.SimulatePoints)
+
+	admin := router.Group("/admin", RequireAdminToken(testAdminToken))
+	admin.DELETE("/receipts/:id", handler.PurgeReceipt)
+	admin.POST("/receipts/rescore", handler.RescoreReceipts)
+	admin.GET("/rulesets", handler.GetRulesetVersions)
+	admin.POST("/rulesets/backtest", handler.BacktestRulesets)
+	return router
+}
+
+// Send a request to the router and return the recorded response
+func doRequest(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Follow the next_cursor of every page, returning all of the receipts
+func listAllReceipts(t *testing.T, router *gin.Engine, path string) []models.Receipt {
+	receipts := []models.Receipt{}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	next := path
+	for next != "" {
+		w := doRequest(router, http.MethodGet, next, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = got status %d, wanted %d", next, w.Code, http.StatusOK)
+		}
+
+		var page ReceiptListResponse
+		json.Unmarshal(w.Body.Bytes(), &page)
+		receipts = append(receipts, page.Receipts...)
+
+		next = ""
+		if page.NextCursor != "" {
+			next = path + separator + "cursor=" + page.NextCursor
+		}
+	}
+	return receipts
+}
+
+func TestCreateAndGetReceipt(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /receipts/process = got status %d, wanted %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/points", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /receipts/{id}/points = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+
+	var points ReceiptPointsResponse
+	json.Unmarshal(w.Body.Bytes(), &points)
+	if points.Points != 20 {
+		t.Errorf("GET /receipts/{id}/points = got %d points, wanted %d", points.Points, 20)
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/does-not-exist", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /receipts/{id} for a missing id = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// Run with `go test -race` to catch unsynchronized access
+func TestConcurrentProcessAndRead(t *testing.T) {
+	const clients = 20
+	const receiptsPerClient = 100
+
+	router := newTestRouter(models.NewMemoryStore())
+
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < receiptsPerClient; n++ {
+				w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+				if w.Code != http.StatusCreated {
+					t.Errorf("POST /receipts/process = got status %d", w.Code)
+					return
+				}
+
+				var created CreatedReceiptResponse
+				json.Unmarshal(w.Body.Bytes(), &created)
+
+				w = doRequest(router, http.MethodGet, "/receipts/"+created.ID, "")
+				if w.Code != http.StatusOK {
+					t.Errorf("GET /receipts/%s = got status %d", created.ID, w.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	receipts := listAllReceipts(t, router, "/receipts?limit=1000")
+	if len(receipts) != clients*receiptsPerClient {
+		t.Errorf("GET /receipts = got %d receipts, wanted %d", len(receipts), clients*receiptsPerClient)
+	}
+}
+
+func TestGetReceiptPointsBreakdown(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/points/breakdown", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /receipts/{id}/points/breakdown = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+
+	var breakdown ReceiptPointsBreakdownResponse
+	json.Unmarshal(w.Body.Bytes(), &breakdown)
+
+	if breakdown.ID != created.ID || breakdown.Points != 20 {
+		t.Errorf("GET /receipts/{id}/points/breakdown = got id %q with %d points, wanted %q with %d", breakdown.ID, breakdown.Points, created.ID, 20)
+	}
+
+	// The rule points have to add up to the total
+	sum := 0
+	byName := map[string]rules.RuleResult{}
+	for _, rule := range breakdown.Rules {
+		sum += rule.Points
+		byName[rule.Name] = rule
+		if rule.Reason == "" {
+			t.Errorf("rule %q has no reason", rule.Name)
+		}
+	}
+	if sum != breakdown.Points {
+		t.Errorf("the rule points add up to %d, wanted the total of %d", sum, breakdown.Points)
+	}
+
+	descriptions := byName[rules.ItemDescriptionRuleType]
+	if len(descriptions.Items) != 1 || descriptions.Items[0].Description != "Emils Cheese Pizza" || descriptions.Items[0].Points != 3 {
+		t.Errorf("item description rule = got items %+v, wanted the pizza worth 3 points", descriptions.Items)
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/does-not-exist/points/breakdown", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /receipts/{id}/points/breakdown for a missing id = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestCreateReceiptFieldErrors(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+
+	for _, total := range []string{`"18.745"`, `18.74`, `"$18.74"`} {
+		body := strings.Replace(testReceiptJSON, `"18.74"`, total, 1)
+
+		w := doRequest(router, http.MethodPost, "/receipts/process", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST /receipts/process with a total of %s = got status %d, wanted %d", total, w.Code, http.StatusBadRequest)
+			continue
+		}
+
+		var response ValidationErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if len(response.Errors) != 1 || response.Errors[0].Field != "total" {
+			t.Errorf("POST /receipts/process with a total of %s = got errors %+v, wanted one for the total", total, response.Errors)
+		}
+	}
+
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01pm", "items": [], "total": "1.00"}`
+	w := doRequest(router, http.MethodPost, "/receipts/process", body)
+
+	var response ValidationErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	expected := []models.FieldError{
+		{Field: "purchaseTime", Code: models.CodePattern, Message: "must be a time such as 15:40, 15:40:00, 3:40 PM or 2023-06-15T15:40:00Z"},
+		{Field: "items", Code: models.CodeEmpty, Message: "must have at least one item"},
+	}
+	if w.Code != http.StatusBadRequest || !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("POST /receipts/process = got status %d with errors %+v, wanted %d with %+v", w.Code, response.Errors, http.StatusBadRequest, expected)
+	}
+
+	// Nothing invalid should have been stored
+	if receipts := listAllReceipts(t, router, "/receipts"); len(receipts) != 0 {
+		t.Errorf("GET /receipts = got %d receipts, wanted none", len(receipts))
+	}
+}
+
+func TestReceiptTotalReconciliation(t *testing.T) {
+	previous := models.CurrentReconciliationPolicy()
+	t.Cleanup(func() { models.SetReconciliationPolicy(previous) })
+
+	router := newTestRouter(models.NewMemoryStore())
+	mismatched := strings.Replace(testReceiptJSON, `"total": "18.74"`, `"total": "20.74"`, 1)
+
+	// The flag policy accepts the receipt but shows the discrepancy
+	models.SetReconciliationPolicy(models.ReconciliationPolicy{Mode: models.ReconcileFlag})
+	w := doRequest(router, http.MethodPost, "/receipts/process", mismatched)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /receipts/process with the flag policy = got status %d, wanted %d", w.Code, http.StatusCreated)
+	}
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID, "")
+	var receipt models.Receipt
+	json.Unmarshal(w.Body.Bytes(), &receipt)
+	expected := models.Discrepancy{ItemsTotal: models.MustParseMoney("18.74"), Difference: models.MustParseMoney("2.00")}
+	if receipt.Discrepancy == nil || *receipt.Discrepancy != expected {
+		t.Errorf("GET /receipts/{id} discrepancy = got %+v, wanted %+v", receipt.Discrepancy, expected)
+	}
+
+	// The strict policy rejects new mismatched receipts and won't score old ones
+	models.SetReconciliationPolicy(models.ReconciliationPolicy{Mode: models.ReconcileStrict})
+	w = doRequest(router, http.MethodPost, "/receipts/process", mismatched)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":"mismatch"`) {
+		t.Errorf("POST /receipts/process with the strict policy = got status %d %s, wanted %d with a mismatch error", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	for _, path := range []string{"/points", "/points/breakdown"} {
+		w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+path, "")
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("GET /receipts/{id}%s with the strict policy = got status %d, wanted %d", path, w.Code, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestCreateDuplicateReceipt(t *testing.T) {
+	// The same receipt with different spacing and letter case
+	duplicate := strings.Replace(testReceiptJSON, `"Target"`, `"  TARGET "`, 1)
+
+	testTable := []struct {
+		policy         string
+		expectedStatus int
+		expectedStored int
+	}{
+		{DuplicateReject, http.StatusConflict, 1},
+		{DuplicateExisting, http.StatusOK, 1},
+		{DuplicateMark, http.StatusCreated, 2},
+	}
+
+	for _, test := range testTable {
+		store := models.NewMemoryStore()
+		handler := NewHandler(store)
+		if err := handler.SetDuplicatePolicy(test.policy); err != nil {
+			t.Fatalf("SetDuplicatePolicy(%q) got an error: %q", test.policy, err.Error())
+		}
+		router := gin.New()
+		router.POST("/receipts/process", handler.CreateReceipt)
+
+		w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+		var original CreatedReceiptResponse
+		json.Unmarshal(w.Body.Bytes(), &original)
+
+		w = doRequest(router, http.MethodPost, "/receipts/process", duplicate)
+		if w.Code != test.expectedStatus {
+			t.Errorf("%s policy: POST /receipts/process again = got status %d, wanted %d", test.policy, w.Code, test.expectedStatus)
+		}
+
+		var response DuplicateReceiptResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		receipts, _ := store.ListReceipts()
+		if len(receipts) != test.expectedStored {
+			t.Errorf("%s policy: got %d stored receipts, wanted %d", test.policy, len(receipts), test.expectedStored)
+		}
+
+		if test.policy == DuplicateMark {
+			if response.ID == original.ID || receipts[1].DuplicateOf != original.ID {
+				t.Errorf("%s policy: got new id %q duplicating %q, wanted a new id duplicating %q", test.policy, response.ID, receipts[1].DuplicateOf, original.ID)
+			}
+		} else if response.ID != original.ID {
+			t.Errorf("%s policy: got id %q, wanted the original %q", test.policy, response.ID, original.ID)
+		}
+	}
+
+	// Under the reject policy an update can't turn a receipt into a duplicate
+	handler := NewHandler(models.NewMemoryStore())
+	handler.SetDuplicatePolicy(DuplicateReject)
+	router := gin.New()
+	router.POST("/receipts/process", handler.CreateReceipt)
+	router.PUT("/receipts/:id", handler.UpdateReceipt)
+
+	doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	w := doRequest(router, http.MethodPost, "/receipts/process", strings.Replace(testReceiptJSON, "13:01", "14:01", 1))
+	var other CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &other)
+	if w = doRequest(router, http.MethodPut, "/receipts/"+other.ID, duplicate); w.Code != http.StatusConflict {
+		t.Errorf("PUT /receipts/{id} making a duplicate = got status %d, wanted %d", w.Code, http.StatusConflict)
+	}
+
+	if err := NewHandler(models.NewMemoryStore()).SetDuplicatePolicy("ignore"); err == nil {
+		t.Errorf("SetDuplicatePolicy(%q) should have returned an error", "ignore")
+	}
+}
+
+func TestUpdateReceipt(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	before, _ := store.GetReceipt(created.ID)
+
+	// Correct the time, which moves the receipt into the 2pm to 4pm window
+	updated := strings.Replace(testReceiptJSON, "13:01", "14:01", 1)
+	w = doRequest(router, http.MethodPut, "/receipts/"+created.ID, updated)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /receipts/{id} = got status %d, wanted %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var receipt models.Receipt
+	json.Unmarshal(w.Body.Bytes(), &receipt)
+	if receipt.ID != created.ID || receipt.PurchaseTime != "14:01" {
+		t.Errorf("PUT /receipts/{id} = got %s at %s, wanted %s at %s", receipt.ID, receipt.PurchaseTime, created.ID, "14:01")
+	}
+	if receipt.Fingerprint == "" || receipt.Fingerprint == before.Fingerprint {
+		t.Errorf("PUT /receipts/{id} should have worked out a new fingerprint")
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/points", "")
+	var points ReceiptPointsResponse
+	json.Unmarshal(w.Body.Bytes(), &points)
+	if points.Points != 30 {
+		t.Errorf("GET /receipts/{id}/points after an update = got %d points, wanted %d", points.Points, 30)
+	}
+
+	// The update is validated like a new receipt
+	w = doRequest(router, http.MethodPut, "/receipts/"+created.ID, strings.Replace(testReceiptJSON, "13:01", "1pm", 1))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"purchaseTime"`) {
+		t.Errorf("PUT /receipts/{id} with a bad time = got %d %s, wanted %d with a purchaseTime error", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	w = doRequest(router, http.MethodPut, "/receipts/does-not-exist", testReceiptJSON)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("PUT /receipts/{id} for a missing id = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+
+	// Changing a receipt into a copy of another marks it as a duplicate
+	w = doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var other CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &other)
+	w = doRequest(router, http.MethodPut, "/receipts/"+other.ID, updated)
+	json.Unmarshal(w.Body.Bytes(), &receipt)
+	if w.Code != http.StatusOK || receipt.DuplicateOf != created.ID {
+		t.Errorf("PUT /receipts/{id} making a duplicate = got %d duplicating %q, wanted %d duplicating %q", w.Code, receipt.DuplicateOf, http.StatusOK, created.ID)
+	}
+}
+
+func TestPatchReceipt(t *testing.T) {
+	router := newTestRouter(models.NewMemoryStore())
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doRequest(router, http.MethodPatch, "/receipts/"+created.ID, `{"retailer": "Walmart", "purchaseDate": "2022-01-02", "purchaseTime": "1:01 PM"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH /receipts/{id} = got status %d, wanted %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var receipt models.Receipt
+	json.Unmarshal(w.Body.Bytes(), &receipt)
+	if receipt.Retailer != "Walmart" || receipt.PurchaseDate != "2022-01-02" || receipt.PurchaseTime != "13:01" || len(receipt.Items) != 2 {
+		t.Errorf("PATCH /receipts/{id} = got %+v, wanted only the retailer and date changed", receipt)
+	}
+	if receipt.RawPurchaseTime != "1:01 PM" {
+		t.Errorf("PATCH /receipts/{id} = got a raw purchase time of %q, wanted %q", receipt.RawPurchaseTime, "1:01 PM")
+	}
+
+	testTable := []struct {
+		patch    string
+		expected int
+	}{
+		// Removing a required field fails validation
+		{`{"total": null}`, http.StatusBadRequest},
+		{`{"items": []}`, http.StatusBadRequest},
+		{`{"retailer": `, http.StatusBadRequest},
+		// Fields that are worked out can't be patched, nor can made up ones
+		{`{"points": 9999, "status": "scored"}`, http.StatusBadRequest},
+		{`{"retailer": "Target", "fingerprint": "x"}`, http.StatusBadRequest},
+		{`{"color": "red"}`, http.StatusBadRequest},
+		{`{}`, http.StatusOK},
+	}
+
+	for _, test := range testTable {
+		w = doRequest(router, http.MethodPatch, "/receipts/"+created.ID, test.patch)
+		if w.Code != test.expected {
+			t.Errorf("PATCH /receipts/{id} with %s = got status %d, wanted %d", test.patch, w.Code, test.expected)
+		}
+	}
+
+	// A patch that leaves the time alone keeps what was sent for it
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID, "")
+	json.Unmarshal(w.Body.Bytes(), &receipt)
+	if receipt.RawPurchaseTime != "1:01 PM" {
+		t.Errorf("GET /receipts/{id} after more patches = got a raw purchase time of %q, wanted %q", receipt.RawPurchaseTime, "1:01 PM")
+	}
+
+	// A patch that changes nothing leaves the score and the history alone
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/history", "")
+	var before []models.Revision
+	json.Unmarshal(w.Body.Bytes(), &before)
+	if w = doRequest(router, http.MethodPatch, "/receipts/"+created.ID, `{"retailer": "Walmart"}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH /receipts/{id} with the same retailer = got status %d, wanted %d", w.Code, http.StatusOK)
+	}
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/history", "")
+	var after []models.Revision
+	json.Unmarshal(w.Body.Bytes(), &after)
+	if len(after) != len(before) {
+		t.Errorf("GET /receipts/{id}/history after a patch that changed nothing = got %d revisions, wanted %d", len(after), len(before))
+	}
+}
+
+func TestDeleteAndPurgeReceipt(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	if w = doRequest(router, http.MethodDelete, "/receipts/"+created.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /receipts/{id} = got status %d, wanted %d", w.Code, http.StatusNoContent)
+	}
+	for _, path := range []string{"", "/points"} {
+		if w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET /receipts/{id}%s after a delete = got status %d, wanted %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+	if w = doRequest(router, http.MethodDelete, "/receipts/"+created.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE /receipts/{id} twice = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+
+	// A deleted receipt doesn't count as the original of a new one
+	if w = doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON); w.Code != http.StatusCreated {
+		t.Errorf("POST /receipts/process after a delete = got status %d, wanted %d", w.Code, http.StatusCreated)
+	}
+
+	// Purging needs the admin token, and works on deleted receipts
+	purge := httptest.NewRequest(http.MethodDelete, "/admin/receipts/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, purge)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE /admin/receipts/{id} without a token = got status %d, wanted %d", w.Code, http.StatusUnauthorized)
+	}
+
+	for _, expected := range []int{http.StatusNoContent, http.StatusNotFound} {
+		purge.Header.Set("Authorization", "Bearer "+testAdminToken)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, purge)
+		if w.Code != expected {
+			t.Errorf("DELETE /admin/receipts/{id} = got status %d, wanted %d", w.Code, expected)
+		}
+	}
+}
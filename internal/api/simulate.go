@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Simulate Request Info
+// @Description A receipt to score, and optionally the rules to score it with
+type SimulateRequest struct {
+	// The receipt, validated the same way as a new one
+	Receipt models.Receipt `json:"receipt"`
+	// The version of a known ruleset to score with
+	Version string `json:"version,omitempty" example:"default"`
+	// A ruleset laid out like a ruleset file, e.g. {"rules": [{"type": "item_pairs"}]}, only with the admin token
+	Ruleset map[string]any `json:"ruleset,omitempty" swaggertype:"object"`
+}
+
+// Simulate Response Info
+// @Description The points a receipt would be awarded
+type SimulateResponse struct {
+	// The total points the receipt would be awarded
+	Points int `json:"points" example:"28"`
+	// The version of the ruleset that scored it
+	RulesetVersion string `json:"rulesetVersion,omitempty" example:"default"`
+	// Every rule that was evaluated, in order, including those that awarded no points
+	Rules []rules.RuleResult `json:"rules"`
+}
+
+// SimulatePoints	godoc
+// @Description 	Score a receipt without storing anything. The body is either a receipt, or an object with the receipt and a ruleset version or an inline ruleset to score it with. The active ruleset is used when neither is given. Inline rulesets need the admin token.
+// @Summary				Simulate Points
+// @Param					request body SimulateRequest true "the receipt and the rules to score it with"
+// @Param					Authorization header string false "Bearer and the admin token, needed for an inline ruleset"
+// @Accept				application/json
+// @Produce				application/json
+// @Tags					points
+// @Success				200 {object} SimulateResponse "The points the receipt would be awarded"
+// @Failure				400 {object} ValidationErrorResponse "The receipt or the ruleset is invalid"
+// @Failure				401 {object} ErrorMessage "An inline ruleset was given without the admin token"
+// @Failure				422 {object} ErrorMessage "The reconciliation policy doesn't allow the receipt to be scored"
+// @Router				/points/simulate [post]
+func (h *Handler) SimulatePoints(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	// A receipt never has a "receipt" field, so a body with one is a request
+	// wrapping the receipt. Bodies that aren't objects are left for the
+	// receipt validation to report.
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+
+	receiptData := body
+	ruleset := rules.ActiveRuleset()
+	if wrapped, ok := fields["receipt"]; ok {
+		receiptData = wrapped
+
+		var request struct {
+			Version string          `json:"version"`
+			Ruleset json.RawMessage `json:"ruleset"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				ErrorMessage{Message: "The body must be a JSON object: " + err.Error()})
+			return
+		}
+
+		// Inline rulesets can hold any number of expressions, so only admins
+		// can run them
+		if givesRuleset(request.Ruleset) && !hasAdminToken(c, h.AdminToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized,
+				ErrorMessage{Message: "A valid admin token is required to simulate an inline ruleset"})
+			return
+		}
+
+		if ruleset, err = requestedRuleset(request.Version, request.Ruleset); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				ErrorMessage{Message: err.Error()})
+			return
+		}
+	}
+
+	receipt, err := models.ParseReceipt(receiptData)
+	if err != nil {
+		abortWithValidationError(c, err)
+		return
+	}
+
+	if models.ExcludedFromScoring(receipt) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			ErrorMessage{Message: "The receipt total doesn't match the sum of its item prices"})
+		return
+	}
+
+	score, err := ruleset.Calculate(receipt)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SimulateResponse{
+		Points:         score.Total,
+		RulesetVersion: ruleset.Version(),
+		Rules:          append([]rules.RuleResult{}, score.Results...),
+	})
+}
+
+// The ruleset a request asks for: a known version, an inline ruleset laid
+// out like a ruleset file, or the active ruleset when it asks for neither
+func requestedRuleset(version string, inline json.RawMessage) (*rules.Ruleset, error) {
+	if givesRuleset(inline) {
+		if version != "" {
+			return nil, errors.New("Give either a ruleset version or a ruleset, not both")
+		}
+		// JSON is also YAML, so inline rulesets are read like ruleset files
+		return rules.DefaultRegistry.ParseRuleset(inline, "ruleset")
+	}
+
+	if version != "" {
+		ruleset, ok := rules.RulesetByVersion(version)
+		if !ok {
+			return nil, fmt.Errorf("Unknown ruleset version %q", version)
+		}
+		return ruleset, nil
+	}
+
+	return rules.ActiveRuleset(), nil
+}
+
+// Whether a request gave an inline ruleset
+func givesRuleset(inline json.RawMessage) bool {
+	return len(inline) > 0 && string(inline) != "null"
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+)
+
+func TestSimulatePoints(t *testing.T) {
+	store := models.NewMemoryStore()
+	router := newTestRouter(store)
+
+	testTable := []struct {
+		name           string
+		body           string
+		admin          bool
+		expectedStatus int
+		expectedPoints int
+		expectedRules  int
+		expectedVer    string
+	}{
+		{
+			name:           "a plain receipt",
+			body:           testReceiptJSON,
+			expectedStatus: http.StatusOK,
+			expectedPoints: 20,
+			expectedRules:  7,
+			expectedVer:    rules.DefaultRulesetVersion,
+		},
+		{
+			name:           "a wrapped receipt",
+			body:           `{"receipt": ` + testReceiptJSON + `}`,
+			expectedStatus: http.StatusOK,
+			expectedPoints: 20,
+			expectedRules:  7,
+			expectedVer:    rules.DefaultRulesetVersion,
+		},
+		{
+			name:           "a known ruleset version",
+			body:           `{"version": "default", "receipt": ` + testReceiptJSON + `}`,
+			expectedStatus: http.StatusOK,
+			expectedPoints: 20,
+			expectedRules:  7,
+			expectedVer:    rules.DefaultRulesetVersion,
+		},
+		{
+			name: "an inline ruleset",
+			body: `{"receipt": ` + testReceiptJSON + `, "ruleset": {"version": "what-if", "rules": [
+				{"type": "retailer_alphanumeric", "params": {"pointsPerCharacter": 2}}
+			]}}`,
+			admin:          true,
+			expectedStatus: http.StatusOK,
+			expectedPoints: 12,
+			expectedRules:  1,
+			expectedVer:    "what-if",
+		},
+		{
+			name:           "an inline ruleset with an unknown rule",
+			body:           `{"receipt": ` + testReceiptJSON + `, "ruleset": {"rules": [{"type": "no_such_rule"}]}}`,
+			admin:          true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "an inline ruleset without the admin token",
+			body:           `{"receipt": ` + testReceiptJSON + `, "ruleset": {"rules": [{"type": "item_pairs"}]}}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "an unknown ruleset version",
+			body:           `{"version": "no-such-version", "receipt": ` + testReceiptJSON + `}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "both a version and a ruleset",
+			body:           `{"version": "default", "ruleset": {"rules": []}, "receipt": ` + testReceiptJSON + `}`,
+			admin:          true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "an invalid receipt",
+			body:           `{"receipt": {"retailer": "Target"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed JSON",
+			body:           `{"retailer": `,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testTable {
+		request := doRequest
+		if test.admin {
+			request = doAdminRequest
+		}
+		w := request(router, http.MethodPost, "/points/simulate", test.body)
+		if w.Code != test.expectedStatus {
+			t.Errorf("%s: POST /points/simulate = got status %d, wanted %d: %s", test.name, w.Code, test.expectedStatus, w.Body.String())
+			continue
+		}
+		if test.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		var response SimulateResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response.Points != test.expectedPoints || len(response.Rules) != test.expectedRules || response.RulesetVersion != test.expectedVer {
+			t.Errorf("%s: POST /points/simulate = got %d points from %d rules of %q, wanted %d from %d of %q",
+				test.name, response.Points, len(response.Rules), response.RulesetVersion, test.expectedPoints, test.expectedRules, test.expectedVer)
+		}
+	}
+
+	// Nothing is stored
+	page, err := store.QueryReceipts(models.ReceiptQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Receipts) != 0 {
+		t.Errorf("simulating stored %d receipts, wanted none", len(page.Receipts))
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Receipt Status Info
+// @Description Where a receipt is in scoring
+type ReceiptStatusResponse struct {
+	// The receipt id
+	ID string `json:"id" example:"adb6b560-0eef-42bc-9d16-df48f30e89b2"`
+	// One of "pending", "scored" or "failed"
+	Status string `json:"status" example:"scored"`
+	// The points awarded, once the receipt is scored
+	Points *int `json:"points,omitempty" example:"28"`
+	// Why the receipt couldn't be scored, when scoring failed
+	Error string `json:"error,omitempty"`
+}
+
+// GetReceiptStatus	godoc
+// @Description 	Returns whether the receipt is still waiting to be scored, has been scored, or couldn't be scored
+// @Summary				Receipt Scoring Status
+// @Param					id path string true "The ID of the receipt"
+// @Produce				application/json
+// @Tags					reciepts
+// @Success				200 {object} ReceiptStatusResponse "The scoring status of the receipt"
+// @Failure				404 {object} ErrorMessage "No receipt found for that id"
+// @Router				/receipts/{id}/status [get]
+func (h *Handler) GetReceiptStatus(c *gin.Context) {
+	receipt, ok := h.findReceipt(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, receiptStatus(receipt))
+}
+
+// The scoring status of a receipt
+func receiptStatus(receipt *models.Receipt) ReceiptStatusResponse {
+	return ReceiptStatusResponse{
+		ID:     receipt.ID,
+		Status: receipt.Status,
+		Points: receipt.Points,
+		Error:  receipt.ScoreError,
+	}
+}
+
+// The points of a receipt are worked out once, when it is scored. Responds
+// with the status of a receipt that hasn't been scored, or why it couldn't
+// be, and returns false.
+func requireScored(c *gin.Context, receipt *models.Receipt) bool {
+	switch receipt.Status {
+	case models.StatusScored:
+		return true
+	case models.StatusFailed:
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			ErrorMessage{Message: receipt.ScoreError})
+	default:
+		c.JSON(http.StatusAccepted, receiptStatus(receipt))
+	}
+	return false
+}
+
+// Have the receipt with the given id scored. With a queue it is scored in
+// the background, without one it is scored before this returns.
+func (h *Handler) scoreReceipt(id string) {
+	if h.Queue != nil {
+		if !h.Queue.Enqueue(id) {
+			log.Printf("The scoring queue is full, receipt %s will be scored by the next sweep", id)
+		}
+		return
+	}
+
+	if err := scoring.ScoreReceipt(h.Store, id); err != nil {
+		log.Printf("Could not score receipt %s: %v", id, err)
+	}
+}
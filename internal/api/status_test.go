@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetReceiptStatus(t *testing.T) {
+	store := models.NewMemoryStore()
+	handler := NewHandler(store)
+	// A queue that is never run, so receipts stay pending
+	handler.Queue = scoring.NewQueue(store, 1, 10)
+	router := gin.New()
+	router.POST("/receipts/process", handler.CreateReceipt)
+	router.GET("/receipts/:id/points", handler.GetReceiptPoints)
+	router.GET("/receipts/:id/status", handler.GetReceiptStatus)
+
+	w := doRequest(router, http.MethodPost, "/receipts/process", testReceiptJSON)
+	var created CreatedReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	testTable := []struct {
+		path           string
+		expectedStatus int
+		expected       string
+	}{
+		{"/receipts/" + created.ID + "/status", http.StatusOK, models.StatusPending},
+		{"/receipts/" + created.ID + "/points", http.StatusAccepted, models.StatusPending},
+	}
+
+	for _, test := range testTable {
+		w = doRequest(router, http.MethodGet, test.path, "")
+		var status ReceiptStatusResponse
+		json.Unmarshal(w.Body.Bytes(), &status)
+		if w.Code != test.expectedStatus || status.Status != test.expected || status.ID != created.ID {
+			t.Errorf("GET %s = got %d %s, wanted %d with status %q", test.path, w.Code, w.Body.String(), test.expectedStatus, test.expected)
+		}
+	}
+
+	// Once scored the points are read from the receipt
+	scoring.ScoreReceipt(store, created.ID)
+	w = doRequest(router, http.MethodGet, "/receipts/"+created.ID+"/status", "")
+	var status ReceiptStatusResponse
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if status.Status != models.StatusScored || status.Points == nil || *status.Points != 20 {
+		t.Errorf("GET /receipts/{id}/status after scoring = got %s, wanted 20 points", w.Body.String())
+	}
+
+	w = doRequest(router, http.MethodGet, "/receipts/does-not-exist/status", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /receipts/{id}/status for a missing id = got status %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+}
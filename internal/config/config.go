@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The available receipt store backends
+const (
+	StoreMemory = "memory"
+	StoreSQLite = "sqlite"
+)
+
+// Server settings, read from environment variables
+type Config struct {
+	// Which receipt store to use: "memory" or "sqlite" (RECEIPT_STORE)
+	StoreBackend string
+	// Path of the SQLite database file (RECEIPT_SQLITE_PATH)
+	SQLitePath string
+	// YAML or JSON file of point rules, the built in rules are used when
+	// this is empty (RULESET_FILE)
+	RulesetFile string
+	// How often the ruleset file is checked for changes, 0 turns checking
+	// off and the rules are only reloaded on SIGHUP (RULESET_WATCH_INTERVAL)
+	RulesetWatchInterval time.Duration
+	// What to do with receipts whose items don't add up to the total:
+	// "strict", "lenient" or "flag" (RECONCILIATION_POLICY)
+	ReconciliationPolicy string
+	// The largest difference the lenient policy accepts, e.g. "0.50"
+	// (RECONCILIATION_TOLERANCE)
+	ReconciliationTolerance string
+	// What to do with a receipt that has already been processed: "reject",
+	// "existing" or "mark". Defaults to "mark", which keeps accepting them
+	// as before (DUPLICATE_POLICY)
+	DuplicatePolicy string
+	// How long Idempotency-Key headers are remembered, 0 keeps them forever
+	// (IDEMPOTENCY_KEY_TTL)
+	IdempotencyKeyTTL time.Duration
+	// The bearer token required by the /admin endpoints, which are turned
+	// off when it is empty (ADMIN_TOKEN)
+	AdminToken string
+	// The most receipts one batch request can hold (BATCH_MAX_SIZE)
+	BatchMaxSize int
+	// How many receipts are scored at once (SCORING_WORKERS)
+	ScoringWorkers int
+	// How many receipts can wait to be scored before new ones are left for
+	// the next sweep (SCORING_QUEUE_SIZE)
+	ScoringQueueSize int
+}
+
+// Build the configuration from the environment, falling back to defaults
+func Load() (Config, error) {
+	cfg := Config{
+		StoreBackend: getEnv("RECEIPT_STORE", StoreMemory),
+		SQLitePath:   getEnv("RECEIPT_SQLITE_PATH", "receipts.db"),
+		RulesetFile:  getEnv("RULESET_FILE", ""),
+
+		ReconciliationPolicy:    getEnv("RECONCILIATION_POLICY", "flag"),
+		ReconciliationTolerance: getEnv("RECONCILIATION_TOLERANCE", "0.00"),
+		DuplicatePolicy:         getEnv("DUPLICATE_POLICY", "mark"),
+		AdminToken:              getEnv("ADMIN_TOKEN", ""),
+	}
+
+	var err error
+	if cfg.RulesetWatchInterval, err = getEnvDuration("RULESET_WATCH_INTERVAL", 2*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.IdempotencyKeyTTL, err = getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.BatchMaxSize, err = getEnvInt("BATCH_MAX_SIZE", 100); err != nil {
+		return cfg, err
+	}
+	if cfg.ScoringWorkers, err = getEnvInt("SCORING_WORKERS", 4); err != nil {
+		return cfg, err
+	}
+	if cfg.ScoringQueueSize, err = getEnvInt("SCORING_QUEUE_SIZE", 1000); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// Returns the environment variable or the fallback if it isn't set
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Returns the environment variable as a duration such as "5s" or "1m"
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s must be a duration such as \"5s\", got %q", key, value)
+	}
+	return duration, nil
+}
+
+// Returns the environment variable as a positive whole number
+func getEnvInt(key string, fallback int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%s must be a positive whole number, got %q", key, value)
+	}
+	return number, nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The canonical forms purchase dates and times are stored in
+const (
+	DateLayout  = "2006-01-02"
+	ClockLayout = "15:04"
+)
+
+// Why a date or time couldn't be read
+var (
+	ErrUnknownDateLayout = errors.New("Could not read date")
+	ErrUnknownTimeLayout = errors.New("Could not scan time")
+	ErrInvalidHour       = errors.New("Invalid Hour format")
+	ErrInvalidMinute     = errors.New("Invalid Minute format")
+	ErrInvalidSecond     = errors.New("Invalid Second format")
+)
+
+// The date layouts receipts are sent in besides timestamps. Dates with
+// slashes are read month first.
+var dateLayouts = []string{DateLayout, "1/2/2006"}
+
+// The timestamp layouts receipts are sent in, with and without an offset
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// 24 hour time, or 12 hour time with AM or PM, with optional seconds
+var clockRegex = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?(?:\s*([AaPp])\.?[Mm]\.?)?$`)
+
+// Read a purchase date in any of the accepted layouts and return it as
+// YYYY-MM-DD. A timestamp with an offset is moved into location when there
+// is one, otherwise its offset is returned as the zone of the receipt.
+func NormalizeDate(raw string, location *time.Location) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, raw); err == nil {
+			return date.Format(DateLayout), "", nil
+		}
+	}
+
+	if timestamp, zone, ok := parseTimestamp(raw, location); ok {
+		return timestamp.Format(DateLayout), zone, nil
+	}
+	return "", "", ErrUnknownDateLayout
+}
+
+// Read a purchase time in any of the accepted layouts and return it as 24
+// hour HH:MM, dropping any seconds. Timestamps are read like NormalizeDate.
+func NormalizeTime(raw string, location *time.Location) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	if match := clockRegex.FindStringSubmatch(raw); match != nil {
+		clock, err := clockFromMatch(match)
+		return clock, "", err
+	}
+
+	if timestamp, zone, ok := parseTimestamp(raw, location); ok {
+		return timestamp.Format(ClockLayout), zone, nil
+	}
+	return "", "", ErrUnknownTimeLayout
+}
+
+// Check the parts of a time matched by clockRegex and format it as HH:MM
+func clockFromMatch(match []string) (string, error) {
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+
+	if meridiem := strings.ToUpper(match[4]); meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return "", ErrInvalidHour
+		}
+		// 12 AM is midnight and 12 PM is noon
+		hour %= 12
+		if meridiem == "P" {
+			hour += 12
+		}
+	} else if hour > 23 {
+		return "", ErrInvalidHour
+	}
+
+	if minute > 59 {
+		return "", ErrInvalidMinute
+	}
+	if match[3] != "" {
+		if second, _ := strconv.Atoi(match[3]); second > 59 {
+			return "", ErrInvalidSecond
+		}
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), nil
+}
+
+// Read a timestamp. One with an offset is moved into location, or when there
+// isn't one its offset is returned as a zone name.
+func parseTimestamp(raw string, location *time.Location) (time.Time, string, bool) {
+	for _, layout := range timestampLayouts {
+		timestamp, err := time.Parse(layout, raw)
+		if err != nil {
+			continue
+		}
+		if layout != time.RFC3339Nano {
+			// Read as the local time of the receipt
+			return timestamp, "", true
+		}
+
+		if location != nil {
+			return timestamp.In(location), "", true
+		}
+		if _, offset := timestamp.Zone(); offset == 0 {
+			return timestamp, "UTC", true
+		}
+		return timestamp, timestamp.Format("-07:00"), true
+	}
+	return time.Time{}, "", false
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	chicago, _ := LoadTimeZone("America/Chicago")
+
+	testTable := []struct {
+		raw      string
+		location *time.Location
+		expected string
+		zone     string
+	}{
+		{"2023-06-15", nil, "2023-06-15", ""},
+		{"06/15/2023", nil, "2023-06-15", ""},
+		{"6/5/2023", nil, "2023-06-05", ""},
+		{" 2023-06-15 ", nil, "2023-06-15", ""},
+		{"2023-06-15T15:40:00Z", nil, "2023-06-15", "UTC"},
+		{"2023-06-15T23:40:00-05:00", nil, "2023-06-15", "-05:00"},
+		{"2023-06-15T15:40:00", nil, "2023-06-15", ""},
+		// 02:30 UTC is still the evening before in Chicago
+		{"2023-06-16T02:30:00Z", chicago, "2023-06-15", ""},
+	}
+
+	for _, test := range testTable {
+		date, zone, err := NormalizeDate(test.raw, test.location)
+		if err != nil {
+			t.Errorf("NormalizeDate(%q) got an error: %q", test.raw, err.Error())
+			continue
+		}
+		if date != test.expected || zone != test.zone {
+			t.Errorf("NormalizeDate(%q) = got %q %q, wanted %q %q", test.raw, date, zone, test.expected, test.zone)
+		}
+	}
+
+	for _, raw := range []string{"", "2023-02-30", "15/06/2023", "June 15 2023", "2023-06-15T25:00:00Z"} {
+		if _, _, err := NormalizeDate(raw, nil); err == nil {
+			t.Errorf("NormalizeDate(%q) = got no error, wanted one", raw)
+		}
+	}
+}
+
+func TestNormalizeTime(t *testing.T) {
+	chicago, _ := LoadTimeZone("America/Chicago")
+
+	testTable := []struct {
+		raw      string
+		location *time.Location
+		expected string
+		zone     string
+	}{
+		{"15:40", nil, "15:40", ""},
+		{"9:05", nil, "09:05", ""},
+		{"15:40:59", nil, "15:40", ""},
+		{"3:40 PM", nil, "15:40", ""},
+		{"3:40pm", nil, "15:40", ""},
+		{"03:40:10 p.m.", nil, "15:40", ""},
+		{"12:15 AM", nil, "00:15", ""},
+		{"12:15 PM", nil, "12:15", ""},
+		{"2023-06-15T15:40:00Z", nil, "15:40", "UTC"},
+		{"2023-06-15T15:40:00+05:30", nil, "15:40", "+05:30"},
+		{"2023-06-15T20:40:00Z", chicago, "15:40", ""},
+	}
+
+	for _, test := range testTable {
+		clock, zone, err := NormalizeTime(test.raw, test.location)
+		if err != nil {
+			t.Errorf("NormalizeTime(%q) got an error: %q", test.raw, err.Error())
+			continue
+		}
+		if clock != test.expected || zone != test.zone {
+			t.Errorf("NormalizeTime(%q) = got %q %q, wanted %q %q", test.raw, clock, zone, test.expected, test.zone)
+		}
+	}
+
+	errorTable := []struct {
+		raw      string
+		expected error
+	}{
+		{"", ErrUnknownTimeLayout},
+		{"noon", ErrUnknownTimeLayout},
+		{"24:00", ErrInvalidHour},
+		{"13:00 PM", ErrInvalidHour},
+		{"0:30 AM", ErrInvalidHour},
+		{"15:60", ErrInvalidMinute},
+		{"15:40:60", ErrInvalidSecond},
+	}
+
+	for _, test := range errorTable {
+		if _, _, err := NormalizeTime(test.raw, nil); err != test.expected {
+			t.Errorf("NormalizeTime(%q) = got error %v, wanted %v", test.raw, err, test.expected)
+		}
+	}
+}
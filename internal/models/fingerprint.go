@@ -0,0 +1,48 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// The parts of a receipt that make it the same purchase, in a normalized form
+type fingerprintContent struct {
+	Retailer     string   `json:"retailer"`
+	PurchaseDate string   `json:"purchaseDate"`
+	PurchaseTime string   `json:"purchaseTime"`
+	TimeZone     string   `json:"timeZone,omitempty"`
+	Total        string   `json:"total"`
+	Items        []string `json:"items"`
+}
+
+// A hash of the receipt's retailer, date, time, time zone, total and items.
+// Receipts that differ only in letter case, spacing or the order of their
+// items have the same fingerprint.
+func Fingerprint(receipt Receipt) string {
+	content := fingerprintContent{
+		Retailer:     normalizeText(receipt.Retailer),
+		PurchaseDate: receipt.PurchaseDate,
+		PurchaseTime: receipt.PurchaseTime,
+		TimeZone:     receipt.TimeZone,
+		Total:        receipt.Total.String(),
+		Items:        make([]string, 0, len(receipt.Items)),
+	}
+
+	for _, item := range receipt.Items {
+		content.Items = append(content.Items, normalizeText(item.ShortDescription)+" "+item.Price.String())
+	}
+	sort.Strings(content.Items)
+
+	// Encoding as JSON keeps the fields apart whatever they contain
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Lower case the text and collapse its runs of whitespace to single spaces
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+)
+
+func fingerprintReceipt() Receipt {
+	return Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Mike & Ikes", Price: MustParseMoney("1.15")},
+		},
+		Total: MustParseMoney("3.40"),
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	original := Fingerprint(fingerprintReceipt())
+
+	same := map[string]func(r *Receipt){
+		"id":          func(r *Receipt) { r.ID = "another-id" },
+		"letter case": func(r *Receipt) { r.Retailer = "m&m CORNER market" },
+		"spacing":     func(r *Receipt) { r.Items[1].ShortDescription = "  Mike  &   Ikes " },
+		"item order":  func(r *Receipt) { r.Items[0], r.Items[1] = r.Items[1], r.Items[0] },
+	}
+	for name, change := range same {
+		receipt := fingerprintReceipt()
+		change(&receipt)
+		if got := Fingerprint(receipt); got != original {
+			t.Errorf("Fingerprint with a different %s = got %s, wanted %s", name, got, original)
+		}
+	}
+
+	different := map[string]func(r *Receipt){
+		"retailer":   func(r *Receipt) { r.Retailer = "M&M Corner Markets" },
+		"date":       func(r *Receipt) { r.PurchaseDate = "2022-03-21" },
+		"time":       func(r *Receipt) { r.PurchaseTime = "14:34" },
+		"time zone":  func(r *Receipt) { r.TimeZone = "America/Chicago" },
+		"total":      func(r *Receipt) { r.Total = MustParseMoney("3.41") },
+		"item price": func(r *Receipt) { r.Items[0].Price = MustParseMoney("2.26") },
+		"extra item": func(r *Receipt) { r.Items = append(r.Items, Item{ShortDescription: "Pez", Price: 0}) },
+		"swapped prices": func(r *Receipt) {
+			r.Items[0].Price, r.Items[1].Price = r.Items[1].Price, r.Items[0].Price
+		},
+	}
+	for name, change := range different {
+		receipt := fingerprintReceipt()
+		change(&receipt)
+		if Fingerprint(receipt) == original {
+			t.Errorf("Fingerprint with a different %s should not match the original", name)
+		}
+	}
+}
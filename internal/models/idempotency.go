@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned by a store when no record exists for the given idempotency key
+var ErrIdempotencyKeyNotFound = errors.New("Idempotency key not found")
+
+// The outcome of a request that was sent with an Idempotency-Key header, kept
+// so that a retry of the request gets the same response
+type IdempotencyRecord struct {
+	// The key sent by the client
+	Key string
+	// A hash of the request body the key was first used with
+	RequestHash string
+	// The status code of the original response
+	StatusCode int
+	// The id of the receipt in the original response
+	ReceiptID string
+	// When the key was first used
+	CreatedAt time.Time
+}
@@ -0,0 +1,416 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In-memory storage for the receipts, everything is lost on restart.
+// Safe for use by concurrent requests.
+type MemoryStore struct {
+	mu sync.RWMutex
+	// Receipts indexed by their id
+	receipts map[string]Receipt
+	// Receipt ids in the order they were added
+	order []string
+	// The position each receipt was added in, which never changes
+	sequence map[string]int64
+	nextSeq  int64
+	// Receipt ids by fingerprint, in the order they were added
+	fingerprints map[string][]string
+	// Idempotency records by their key
+	idempotencyKeys map[string]IdempotencyRecord
+	// Receipts that were deleted but not yet purged, by their id
+	deleted map[string]Receipt
+	// The revisions of each receipt, oldest first
+	revisions map[string][]Revision
+}
+
+// Creates an empty in-memory receipt store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		receipts:        map[string]Receipt{},
+		sequence:        map[string]int64{},
+		fingerprints:    map[string][]string{},
+		idempotencyKeys: map[string]IdempotencyRecord{},
+		deleted:         map[string]Receipt{},
+		revisions:       map[string][]Revision{},
+	}
+}
+
+// Add another receipt to our list of receipts
+func (s *MemoryStore) AddReceipt(newReceipt Receipt) (string, error) {
+	newReceipt.ID = ""
+	ids, err := s.AddReceipts([]Receipt{newReceipt})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// Add several receipts at once, none of them are added if any of their ids
+// are taken
+func (s *MemoryStore) AddReceipts(newReceipts []Receipt) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(newReceipts))
+	taken := map[string]bool{}
+	for _, newReceipt := range newReceipts {
+		id := newReceipt.ID
+		if id == "" {
+			id = NewReceiptID()
+		}
+
+		_, stored := s.receipts[id]
+		_, deleted := s.deleted[id]
+		if stored || deleted || taken[id] {
+			return nil, ErrDuplicateReceiptID
+		}
+		taken[id] = true
+		ids = append(ids, id)
+	}
+
+	for i, newReceipt := range newReceipts {
+		newReceipt.ID = ids[i]
+		defaultStatus(&newReceipt)
+		s.receipts[newReceipt.ID] = copyReceipt(newReceipt)
+		s.order = append(s.order, newReceipt.ID)
+		s.nextSeq++
+		s.sequence[newReceipt.ID] = s.nextSeq
+		s.addFingerprint(newReceipt.Fingerprint, newReceipt.ID)
+	}
+	return ids, nil
+}
+
+// Returns a copy of the receipt with the given id
+func (s *MemoryStore) GetReceipt(id string) (*Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok {
+		return nil, ErrReceiptNotFound
+	}
+
+	found := copyReceipt(receipt)
+	return &found, nil
+}
+
+// Returns copies of a page of the receipts that match the query
+func (s *MemoryStore) QueryReceipts(query ReceiptQuery) (ReceiptPage, error) {
+	if err := query.validate(); err != nil {
+		return ReceiptPage{}, err
+	}
+	cursor, err := query.decodeCursor()
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type match struct {
+		receipt Receipt
+		key     string
+		seq     int64
+	}
+
+	var matches []match
+	for _, id := range s.order {
+		receipt := s.receipts[id]
+		if query.matches(receipt) {
+			matches = append(matches, match{receipt, sortKey(query.sortOrder(), receipt), s.sequence[id]})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return query.compare(matches[i].key, matches[i].seq, matches[j].key, matches[j].seq) < 0
+	})
+
+	// Skip everything up to and including the end of the previous page
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			return query.compare(matches[i].key, matches[i].seq, cursor.Key, cursor.Seq) > 0
+		})
+	}
+	matches = matches[start:]
+
+	page := ReceiptPage{Receipts: []Receipt{}}
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+		last := matches[len(matches)-1]
+		page.NextCursor = query.encodeCursor(last.receipt, last.seq)
+	}
+	for _, match := range matches {
+		page.Receipts = append(page.Receipts, copyReceipt(match.receipt))
+	}
+	return page, nil
+}
+
+// Returns a copy of the earliest receipt with the given fingerprint
+func (s *MemoryStore) FindByFingerprint(fingerprint string) (*Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.fingerprints[fingerprint]
+	if fingerprint == "" || len(ids) == 0 {
+		return nil, ErrReceiptNotFound
+	}
+
+	found := copyReceipt(s.receipts[ids[0]])
+	return &found, nil
+}
+
+// Return a copy of our list of receipts
+func (s *MemoryStore) ListReceipts() ([]Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipts := make([]Receipt, 0, len(s.order))
+	for _, id := range s.order {
+		receipts = append(receipts, copyReceipt(s.receipts[id]))
+	}
+	return receipts, nil
+}
+
+// Replace the contents of a receipt, keeping its id and place in the order
+func (s *MemoryStore) UpdateReceipt(id string, receipt Receipt) error {
+	receipt.ID = id
+	defaultStatus(&receipt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.receipts[id]
+	if !ok {
+		return ErrReceiptNotFound
+	}
+
+	receipt.ContentVersion = existing.ContentVersion + 1
+	s.removeFingerprint(existing.Fingerprint, id)
+	s.receipts[id] = copyReceipt(receipt)
+	s.addFingerprint(receipt.Fingerprint, id)
+	return nil
+}
+
+// Store the outcome of scoring a receipt
+func (s *MemoryStore) SaveScore(id string, score ReceiptScore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok {
+		return ErrReceiptNotFound
+	}
+	if !score.fits(receipt) {
+		return ErrStaleScore
+	}
+
+	receipt.Status, receipt.Points, receipt.ScoreError = score.Status, score.Points, score.Error
+	receipt.Breakdown, receipt.RulesetVersion = score.Breakdown, score.RulesetVersion
+	s.receipts[id] = copyReceipt(receipt)
+	return nil
+}
+
+// Remove a receipt from our list of receipts, it is kept aside until it is
+// purged
+func (s *MemoryStore) DeleteReceipt(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok {
+		return ErrReceiptNotFound
+	}
+
+	delete(s.receipts, id)
+	delete(s.sequence, id)
+	s.order = removeId(s.order, id)
+	s.removeFingerprint(receipt.Fingerprint, id)
+	s.deleted[id] = receipt
+	return nil
+}
+
+// Permanently remove a receipt, deleted or not, along with the idempotency
+// keys that point at it
+func (s *MemoryStore) PurgeReceipt(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if receipt, ok := s.receipts[id]; ok {
+		delete(s.receipts, id)
+		delete(s.sequence, id)
+		s.order = removeId(s.order, id)
+		s.removeFingerprint(receipt.Fingerprint, id)
+	} else if _, ok := s.deleted[id]; ok {
+		delete(s.deleted, id)
+	} else {
+		return ErrReceiptNotFound
+	}
+
+	for key, record := range s.idempotencyKeys {
+		if record.ReceiptID == id {
+			delete(s.idempotencyKeys, key)
+		}
+	}
+	delete(s.revisions, id)
+	return nil
+}
+
+// Store the revision under the next number for its receipt
+func (s *MemoryStore) AddRevision(revision Revision) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revision.Number = len(s.revisions[revision.ReceiptID]) + 1
+	revision = copyRevision(revision)
+	s.revisions[revision.ReceiptID] = append(s.revisions[revision.ReceiptID], revision)
+	return copyRevision(revision), nil
+}
+
+// Returns copies of the revisions of the receipt
+func (s *MemoryStore) ListRevisions(receiptId string) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := make([]Revision, 0, len(s.revisions[receiptId]))
+	for _, revision := range s.revisions[receiptId] {
+		revisions = append(revisions, copyRevision(revision))
+	}
+	return revisions, nil
+}
+
+// Returns a copy of the numbered revision of the receipt
+func (s *MemoryStore) GetRevision(receiptId string, number int) (*Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := s.revisions[receiptId]
+	if number < 1 || number > len(revisions) {
+		return nil, ErrRevisionNotFound
+	}
+
+	found := copyRevision(revisions[number-1])
+	return &found, nil
+}
+
+// Index the receipt under its fingerprint, keeping the ids in the order the
+// receipts were added
+func (s *MemoryStore) addFingerprint(fingerprint string, id string) {
+	if fingerprint == "" {
+		return
+	}
+
+	ids := s.fingerprints[fingerprint]
+	i := sort.Search(len(ids), func(i int) bool { return s.sequence[ids[i]] > s.sequence[id] })
+	ids = append(ids, "")
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	s.fingerprints[fingerprint] = ids
+}
+
+// Stop indexing the receipt under its fingerprint
+func (s *MemoryStore) removeFingerprint(fingerprint string, id string) {
+	if ids := removeId(s.fingerprints[fingerprint], id); len(ids) > 0 {
+		s.fingerprints[fingerprint] = ids
+	} else {
+		delete(s.fingerprints, fingerprint)
+	}
+}
+
+// Store the idempotency record, replacing any with the same key
+func (s *MemoryStore) SaveIdempotencyKey(record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.idempotencyKeys[record.Key] = record
+	return nil
+}
+
+// Returns a copy of the idempotency record for the key
+func (s *MemoryStore) GetIdempotencyKey(key string) (*IdempotencyRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.idempotencyKeys[key]
+	if !ok {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	return &record, nil
+}
+
+// Remove the idempotency records created before the given time
+func (s *MemoryStore) PurgeIdempotencyKeys(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for key, record := range s.idempotencyKeys {
+		if record.CreatedAt.Before(before) {
+			delete(s.idempotencyKeys, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Empty the list of receipts
+func (s *MemoryStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.receipts = map[string]Receipt{}
+	s.order = nil
+	s.sequence = map[string]int64{}
+	s.fingerprints = map[string][]string{}
+	s.idempotencyKeys = map[string]IdempotencyRecord{}
+	s.deleted = map[string]Receipt{}
+	s.revisions = map[string][]Revision{}
+}
+
+// Returns ids without the given id
+func removeId(ids []string, id string) []string {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// Copies a receipt so that callers can't change what the store is holding
+func copyReceipt(receipt Receipt) Receipt {
+	if receipt.Items != nil {
+		receipt.Items = append([]Item(nil), receipt.Items...)
+	}
+	if receipt.Discrepancy != nil {
+		discrepancy := *receipt.Discrepancy
+		receipt.Discrepancy = &discrepancy
+	}
+	if receipt.Points != nil {
+		points := *receipt.Points
+		receipt.Points = &points
+	}
+	if receipt.Breakdown != nil {
+		receipt.Breakdown = append(json.RawMessage(nil), receipt.Breakdown...)
+	}
+	return receipt
+}
+
+// Copies a revision so that callers can't change what the store is holding
+func copyRevision(revision Revision) Revision {
+	revision.Changes = append([]FieldChange{}, revision.Changes...)
+	revision.Receipt = copyReceipt(revision.Receipt)
+	if revision.PointsBefore != nil {
+		points := *revision.PointsBefore
+		revision.PointsBefore = &points
+	}
+	if revision.PointsAfter != nil {
+		points := *revision.PointsAfter
+		revision.PointsAfter = &points
+	}
+	return revision
+}
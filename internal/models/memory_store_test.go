@@ -0,0 +1,99 @@
+package models
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	store := NewMemoryStore()
+
+	newReceipt := newTestReceipt()
+	newReceipt.Items = []Item{{ShortDescription: "Pepsi - 12-oz", Price: MustParseMoney("1.25")}}
+	newId, _ := store.AddReceipt(newReceipt)
+
+	// Changing the receipt we passed in must not change the stored one
+	newReceipt.Items[0].Price = MustParseMoney("99.99")
+
+	// Nor should changing what we got back
+	found, _ := store.GetReceipt(newId)
+	found.Retailer = "Walgreens"
+	found.Items[0].ShortDescription = "Dasani"
+
+	listed, _ := store.ListReceipts()
+	listed[0].Items[0].ShortDescription = "Dasani"
+
+	stored, _ := store.GetReceipt(newId)
+	if stored.Retailer != "Target" {
+		t.Errorf("GetReceipt retailer = got %q, wanted %q", stored.Retailer, "Target")
+	}
+	if stored.Items[0] != (Item{ShortDescription: "Pepsi - 12-oz", Price: MustParseMoney("1.25")}) {
+		t.Errorf("GetReceipt item = got %+v, wanted the original item", stored.Items[0])
+	}
+}
+
+// Run with `go test -race` to catch unsynchronized access
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	const writers = 50
+	const receiptsPerWriter = 100
+
+	store := NewMemoryStore()
+	ids := make(chan string, writers*receiptsPerWriter)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < receiptsPerWriter; n++ {
+				newReceipt := newTestReceipt()
+				newReceipt.Items = []Item{{ShortDescription: "Dasani", Price: MustParseMoney("1.40")}}
+
+				id, err := store.AddReceipt(newReceipt)
+				if err != nil {
+					t.Errorf("AddReceipt got an error: %q", err.Error())
+					return
+				}
+				ids <- id
+
+				// Read back what we just wrote while everyone else is writing
+				found, err := store.GetReceipt(id)
+				if err != nil || found.ID != id {
+					t.Errorf("GetReceipt(%q) could not find a receipt it just added", id)
+					return
+				}
+			}
+		}()
+
+		// Readers listing everything while the writers are busy
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				receipts, _ := store.ListReceipts()
+				for _, receipt := range receipts {
+					if len(receipt.Items) != 1 {
+						t.Errorf("ListReceipts returned a receipt with %d items, wanted 1", len(receipt.Items))
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("AddReceipt handed out the id %q twice", id)
+		}
+		seen[id] = true
+	}
+
+	receipts, _ := store.ListReceipts()
+	if len(receipts) != writers*receiptsPerWriter {
+		t.Errorf("ListReceipts = got %d receipts, wanted %d", len(receipts), writers*receiptsPerWriter)
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// An exact amount of money, kept as a whole number of cents so that adding
+// and comparing amounts never suffers from floating point error. It is
+// written and read as a decimal string such as "6.49".
+type Money int64
+
+// An optional minus sign, whole dollars, and the decimal places
+var moneyRegex = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d+))?$`)
+
+// The most dollars that still fit in cents
+const maxDollars = (math.MaxInt64 - 99) / 100
+
+// Parse a decimal string such as "6.49" into money. Anything that isn't a
+// plain decimal number with at most two decimal places is rejected.
+func ParseMoney(str string) (Money, error) {
+	matches := moneyRegex.FindStringSubmatch(str)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid money amount %q", str)
+	}
+
+	if len(matches[3]) > 2 {
+		return 0, fmt.Errorf("invalid money amount %q: more than two decimal places", str)
+	}
+
+	dollars, err := strconv.ParseInt(matches[2], 10, 64)
+	if err != nil || dollars > maxDollars {
+		return 0, fmt.Errorf("invalid money amount %q: too large", str)
+	}
+
+	// "6.5" means 50 cents, not 5
+	cents, _ := strconv.ParseInt((matches[3] + "00")[:2], 10, 64)
+
+	amount := dollars*100 + cents
+	if matches[1] == "-" {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+// Parse a decimal string into money, panicking if it's invalid. Meant for
+// amounts written in code.
+func MustParseMoney(str string) Money {
+	money, err := ParseMoney(str)
+	if err != nil {
+		panic(err)
+	}
+	return money
+}
+
+// The amount as a whole number of cents
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// The amount as a decimal string with two decimal places, e.g. "6.49"
+func (m Money) String() string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Money is written to JSON and YAML as a decimal string
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Money is read from JSON and YAML decimal strings
+func (m *Money) UnmarshalText(text []byte) error {
+	money, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// Money is stored in the database as its decimal string
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Read money from a database decimal string
+func (m *Money) Scan(src any) error {
+	switch value := src.(type) {
+	case string:
+		return m.UnmarshalText([]byte(value))
+	case []byte:
+		return m.UnmarshalText(value)
+	}
+	return fmt.Errorf("cannot read money from %T", src)
+}
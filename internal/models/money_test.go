@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	testTable := []struct {
+		arg1          string
+		expectedCents int64
+		expectedStr   string
+	}{
+		{"6.49", 649, "6.49"},
+		{"0.00", 0, "0.00"},
+		{"12.25", 1225, "12.25"},
+		{"7400.00", 740000, "7400.00"},
+		{"0.10", 10, "0.10"},
+		{"6.5", 650, "6.50"},
+		{"6", 600, "6.00"},
+		{"-1.05", -105, "-1.05"},
+	}
+
+	for _, test := range testTable {
+		money, err := ParseMoney(test.arg1)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) got an error: %q", test.arg1, err.Error())
+			continue
+		}
+		if money.Cents() != test.expectedCents {
+			t.Errorf("ParseMoney(%q) = got %d cents, wanted %d", test.arg1, money.Cents(), test.expectedCents)
+		}
+		if money.String() != test.expectedStr {
+			t.Errorf("ParseMoney(%q).String() = got %q, wanted %q", test.arg1, money.String(), test.expectedStr)
+		}
+	}
+}
+
+func TestParseMoneyErrors(t *testing.T) {
+	testTable := []struct {
+		arg1     string
+		expected string
+	}{
+		{"", "invalid money amount"},
+		{"abc", "invalid money amount"},
+		{"$6.49", "invalid money amount"},
+		{"6,49", "invalid money amount"},
+		{"6.", "invalid money amount"},
+		{".49", "invalid money amount"},
+		{"1e3", "invalid money amount"},
+		{" 6.49", "invalid money amount"},
+		{"6.499", "more than two decimal places"},
+		{"0.125", "more than two decimal places"},
+		{"99999999999999999999.00", "too large"},
+	}
+
+	for _, test := range testTable {
+		_, err := ParseMoney(test.arg1)
+		if err == nil {
+			t.Errorf("ParseMoney(%q) should have returned an error", test.arg1)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("ParseMoney(%q) = got error %q, wanted it to contain %q", test.arg1, err.Error(), test.expected)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var item Item
+	if err := json.Unmarshal([]byte(`{"shortDescription": "Dasani", "price": "1.40"}`), &item); err != nil {
+		t.Fatalf("json.Unmarshal got an error: %q", err.Error())
+	}
+	if item.Price.Cents() != 140 {
+		t.Errorf("json.Unmarshal price = got %d cents, wanted %d", item.Price.Cents(), 140)
+	}
+
+	output, _ := json.Marshal(item)
+	if string(output) != `{"shortDescription":"Dasani","price":"1.40"}` {
+		t.Errorf("json.Marshal = got %s, wanted the price written back as the same string", output)
+	}
+
+	for _, body := range []string{`{"price": 1.40}`, `{"price": "1.405"}`, `{"price": "one"}`} {
+		if err := json.Unmarshal([]byte(body), &item); err == nil {
+			t.Errorf("json.Unmarshal(%s) should have returned an error", body)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The orders receipts can be listed in
+const (
+	// The order the receipts were added
+	SortCreated = "created"
+	// Purchase date, receipts from the same day stay in the order they were added
+	SortDate = "date"
+	// Total, receipts with the same total stay in the order they were added
+	SortTotal = "total"
+	// Points awarded, receipts that haven't been scored count as no points.
+	// Receipts with the same points stay in the order they were added.
+	SortPoints = "points"
+)
+
+// The most receipts a page can hold when no limit is given
+const DefaultPageLimit = 100
+
+// Returned when a cursor is malformed or belongs to a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Which receipts to list, in what order, and where to start
+type ReceiptQuery struct {
+	// Only receipts from this retailer, ignoring letter case
+	Retailer string
+	// Only receipts purchased on or after this YYYY-MM-DD date
+	DateFrom string
+	// Only receipts purchased on or before this YYYY-MM-DD date
+	DateTo string
+	// Only receipts with a total of at least this much
+	MinTotal *Money
+	// Only receipts with a total of at most this much
+	MaxTotal *Money
+	// Only receipts with this scoring status
+	Status string
+	// SortCreated, SortDate, SortTotal or SortPoints, SortCreated when empty
+	Sort string
+	// Reverse the order
+	Descending bool
+	// The most receipts to return, every match when 0 or less
+	Limit int
+	// Where the previous page ended, empty for the first page
+	Cursor string
+}
+
+// Build the filters of a receipt query out of named values, as given to the
+// listing and backtest endpoints and the backtest command: retailer,
+// date_from, date_to, min_total, max_total and status. get returns an empty
+// string for a value that wasn't given.
+func ParseReceiptFilters(get func(name string) string) (ReceiptQuery, error) {
+	query := ReceiptQuery{
+		Retailer: get("retailer"),
+		DateFrom: get("date_from"),
+		DateTo:   get("date_to"),
+		Status:   get("status"),
+	}
+
+	for _, date := range []struct{ name, value string }{{"date_from", query.DateFrom}, {"date_to", query.DateTo}} {
+		if _, err := time.Parse(DateLayout, date.value); date.value != "" && err != nil {
+			return query, fmt.Errorf("%s must be a date formatted as YYYY-MM-DD", date.name)
+		}
+	}
+
+	for _, total := range []struct {
+		name   string
+		target **Money
+	}{{"min_total", &query.MinTotal}, {"max_total", &query.MaxTotal}} {
+		value := get(total.name)
+		if value == "" {
+			continue
+		}
+		amount, err := ParseMoney(value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be a dollar amount such as 10.00", total.name)
+		}
+		*total.target = &amount
+	}
+
+	switch query.Status {
+	case "", StatusPending, StatusScored, StatusFailed:
+	default:
+		return query, fmt.Errorf("status must be one of pending, scored or failed")
+	}
+
+	return query, nil
+}
+
+// One page of a receipt listing
+type ReceiptPage struct {
+	Receipts []Receipt
+	// Pass as the cursor of the next query to get the next page, empty on
+	// the last page
+	NextCursor string
+}
+
+// Where a page ended: the sort key of its last receipt and the order it was
+// added in, to break ties
+type pageCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Key        string `json:"k,omitempty"`
+	Seq        int64  `json:"n"`
+}
+
+// The sort of the query with the default filled in
+func (q ReceiptQuery) sortOrder() string {
+	if q.Sort == "" {
+		return SortCreated
+	}
+	return q.Sort
+}
+
+// Whether the receipt passes every filter of the query
+func (q ReceiptQuery) matches(receipt Receipt) bool {
+	if q.Retailer != "" && !strings.EqualFold(receipt.Retailer, strings.TrimSpace(q.Retailer)) {
+		return false
+	}
+	if q.DateFrom != "" && receipt.PurchaseDate < q.DateFrom {
+		return false
+	}
+	if q.DateTo != "" && receipt.PurchaseDate > q.DateTo {
+		return false
+	}
+	if q.MinTotal != nil && receipt.Total < *q.MinTotal {
+		return false
+	}
+	if q.MaxTotal != nil && receipt.Total > *q.MaxTotal {
+		return false
+	}
+	if q.Status != "" && receipt.Status != q.Status {
+		return false
+	}
+	return true
+}
+
+// The value the receipt is sorted by, as stored in a cursor
+func sortKey(sort string, receipt Receipt) string {
+	switch sort {
+	case SortDate:
+		return receipt.PurchaseDate
+	case SortTotal:
+		return strconv.FormatInt(receipt.Total.Cents(), 10)
+	case SortPoints:
+		if receipt.Points == nil {
+			return "0"
+		}
+		return strconv.Itoa(*receipt.Points)
+	}
+	return ""
+}
+
+// Read the cursor of the query, returns nil for the first page
+func (q ReceiptQuery) decodeCursor() (*pageCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != q.sortOrder() || cursor.Descending != q.Descending {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort == SortTotal || cursor.Sort == SortPoints {
+		if _, err := strconv.ParseInt(cursor.Key, 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// The cursor that continues the query after the receipt
+func (q ReceiptQuery) encodeCursor(receipt Receipt, seq int64) string {
+	data, _ := json.Marshal(pageCursor{
+		Sort:       q.sortOrder(),
+		Descending: q.Descending,
+		Key:        sortKey(q.sortOrder(), receipt),
+		Seq:        seq,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Checks the sort of the query
+func (q ReceiptQuery) validate() error {
+	switch q.sortOrder() {
+	case SortCreated, SortDate, SortTotal, SortPoints:
+		return nil
+	}
+	return errors.New("unknown sort " + strconv.Quote(q.Sort))
+}
+
+// Compares two sort keys of the given sort, returning -1, 0 or 1
+func compareSortKeys(sort string, a string, b string) int {
+	if sort == SortTotal || sort == SortPoints {
+		x, _ := strconv.ParseInt(a, 10, 64)
+		y, _ := strconv.ParseInt(b, 10, 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// Compares two positions in the order of the query, each a sort key and the
+// order the receipt was added in, returning -1, 0 or 1
+func (q ReceiptQuery) compare(keyA string, seqA int64, keyB string, seqB int64) int {
+	result := compareSortKeys(q.sortOrder(), keyA, keyB)
+	if result == 0 {
+		switch {
+		case seqA < seqB:
+			result = -1
+		case seqA > seqB:
+			result = 1
+		}
+	}
+	if q.Descending {
+		return -result
+	}
+	return result
+}
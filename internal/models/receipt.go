@@ -1,18 +1,13 @@
 package models
 
-import (
-	"errors"
-	"time"
-
-	"github.com/google/uuid"
-)
+import "encoding/json"
 
 // An item is a purchased item on a receipt
 type Item struct {
 	// The Short Product Description for the item.
 	ShortDescription string `json:"shortDescription" binding:"required"`
 	// The total price payed for this item.
-	Price string `json:"price" binding:"required"`
+	Price Money `json:"price" binding:"required" swaggertype:"string" example:"6.49"`
 }
 
 // A receipt is a listing of purchased items, and other metadata
@@ -21,54 +16,42 @@ type Receipt struct {
 	ID string `json:"id"`
 	// The name of the retailer or store the receipt is from.
 	Retailer string `json:"retailer" binding:"required"`
-	// The date of the purchase printed on the receipt.
+	// The date of the purchase printed on the receipt. Sent in any of the
+	// accepted layouts and stored as YYYY-MM-DD.
 	PurchaseDate string `json:"purchaseDate" binding:"required" time_format:"2006-01-02"`
-	// The time of the purchase printed on the receipt. 24-hour time expected.
+	// The time of the purchase printed on the receipt. Sent in any of the
+	// accepted layouts and stored as 24 hour HH:MM.
 	PurchaseTime string `json:"purchaseTime" binding:"required" time_format:"hh:mm"`
+	// The purchase date and time as they were sent, when they weren't
+	// already in their stored form
+	RawPurchaseDate string `json:"rawPurchaseDate,omitempty" example:"06/15/2023"`
+	RawPurchaseTime string `json:"rawPurchaseTime,omitempty" example:"3:40 PM"`
+	// The time zone the purchase date and time are in, an IANA name or a UTC
+	// offset. The ruleset decides the zone when it is left out.
+	TimeZone string `json:"timeZone,omitempty" example:"America/Chicago"`
 	// The total amount paid on the receipt.
-	Total string `json:"total" binding:"required"`
+	Total Money `json:"total" binding:"required" swaggertype:"string" example:"35.35"`
 	// The list of items in this receipt
 	Items []Item `json:"items" binding:"required,dive"`
-}
-
-// In-memory storage for the receipts
-var receipts = []Receipt{}
-
-// Searches the storage for a given receipt id and returns it
-func GetReceiptById(id string) (*Receipt, error) {
-	for i, b := range receipts {
-		if b.ID == id {
-			return &receipts[i], nil
-		}
-	}
-
-	return nil, errors.New("Receipt not found")
-}
-
-// Empty the list of receipts
-func ClearReceipts() {
-	receipts = []Receipt{}
-}
-
-// Return our list of receipts
-func GetReceipts() []Receipt {
-	return receipts
-}
-
-// Add another receipt to our list of receipts
-func AddToReceipts(newReceipt Receipt) string {
-	newId := uuid.NewString()
-	newReceipt.ID = newId
-	receipts = append(receipts, newReceipt)
-	return newId
-}
-
-func CheckReceipt(receipt Receipt) (bool, error) {
-	// Make sure that the purchase date and time are parseable
-	_, err := time.Parse("2006-01-02", receipt.PurchaseDate)
-	if err != nil {
-		return false, err
-	}
-
-	return true, nil
+	// Set when the items don't add up to the total but the receipt was
+	// accepted anyway
+	Discrepancy *Discrepancy `json:"discrepancy,omitempty"`
+	// A hash of the receipt's contents, identical receipts share it
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// The ID of the receipt this one duplicates, when duplicates are kept
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+	// Where the receipt is in scoring: "pending", "scored" or "failed"
+	Status string `json:"status,omitempty" example:"scored"`
+	// The points the receipt was awarded, once it is scored
+	Points *int `json:"points,omitempty" example:"28"`
+	// Why the receipt couldn't be scored, when scoring failed
+	ScoreError string `json:"scoreError,omitempty"`
+	// The version of the ruleset the receipt was scored with
+	RulesetVersion string `json:"rulesetVersion,omitempty" example:"default"`
+	// The result of every rule when the receipt was scored, as JSON. Served
+	// by the points breakdown endpoint.
+	Breakdown json.RawMessage `json:"-"`
+	// Counts the updates to the receipt's contents, so a score worked out
+	// from an older version of them isn't saved
+	ContentVersion int64 `json:"-"`
 }
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+)
+
+// The ways a receipt whose items don't add up to its total can be handled
+const (
+	// Reject the receipt
+	ReconcileStrict = "strict"
+	// Accept the receipt if the difference is within the tolerance, which
+	// allows for tax and rounding, and reject it otherwise
+	ReconcileLenient = "lenient"
+	// Accept the receipt but mark it with the discrepancy
+	ReconcileFlag = "flag"
+)
+
+// How receipt totals are reconciled against the item prices
+type ReconciliationPolicy struct {
+	// One of ReconcileStrict, ReconcileLenient or ReconcileFlag
+	Mode string
+	// The largest difference the lenient mode accepts
+	Tolerance Money
+}
+
+// Marks a receipt whose items don't add up to its total
+type Discrepancy struct {
+	// The sum of the item prices
+	ItemsTotal Money `json:"itemsTotal" swaggertype:"string" example:"33.35"`
+	// The receipt total minus the sum of the item prices
+	Difference Money `json:"difference" swaggertype:"string" example:"2.00"`
+}
+
+// The policy used when receipts are checked. Set once while the server is
+// starting up.
+var reconciliationPolicy = ReconciliationPolicy{Mode: ReconcileFlag}
+
+// Build a reconciliation policy from its mode and a tolerance such as "0.50"
+func NewReconciliationPolicy(mode string, tolerance string) (ReconciliationPolicy, error) {
+	switch mode {
+	case ReconcileStrict, ReconcileLenient, ReconcileFlag:
+	default:
+		return ReconciliationPolicy{}, fmt.Errorf("unknown reconciliation policy %q, expected %s, %s or %s",
+			mode, ReconcileStrict, ReconcileLenient, ReconcileFlag)
+	}
+
+	amount, err := ParseMoney(tolerance)
+	if err != nil {
+		return ReconciliationPolicy{}, fmt.Errorf("reconciliation tolerance: %w", err)
+	}
+	if amount < 0 {
+		return ReconciliationPolicy{}, fmt.Errorf("reconciliation tolerance can't be negative")
+	}
+
+	return ReconciliationPolicy{Mode: mode, Tolerance: amount}, nil
+}
+
+// Replace the policy used when receipts are checked
+func SetReconciliationPolicy(policy ReconciliationPolicy) {
+	reconciliationPolicy = policy
+}
+
+// Returns the policy used when receipts are checked
+func CurrentReconciliationPolicy() ReconciliationPolicy {
+	return reconciliationPolicy
+}
+
+// Compare the receipt total with the sum of its item prices, returns nil if
+// they match
+func Reconcile(receipt Receipt) *Discrepancy {
+	var itemsTotal Money
+	for _, item := range receipt.Items {
+		itemsTotal += item.Price
+	}
+
+	if itemsTotal == receipt.Total {
+		return nil
+	}
+	return &Discrepancy{ItemsTotal: itemsTotal, Difference: receipt.Total - itemsTotal}
+}
+
+// Whether the receipt may not be scored because its totals don't add up
+// and the policy is strict
+func ExcludedFromScoring(receipt Receipt) bool {
+	return reconciliationPolicy.Mode == ReconcileStrict && Reconcile(receipt) != nil
+}
+
+// Reconcile the receipt under the current policy, adding an error if the
+// policy rejects it and marking it with any discrepancy it accepts
+func reconcileReceipt(errs *fieldErrors, receipt *Receipt) {
+	receipt.Discrepancy = nil
+
+	discrepancy := Reconcile(*receipt)
+	if discrepancy == nil {
+		return
+	}
+
+	policy := reconciliationPolicy
+	difference := discrepancy.Difference
+	if difference < 0 {
+		difference = -difference
+	}
+
+	switch {
+	case policy.Mode == ReconcileStrict:
+		errs.add("total", CodeMismatch,
+			fmt.Sprintf("must equal the sum of the item prices, %s", discrepancy.ItemsTotal))
+	case policy.Mode == ReconcileLenient && difference > policy.Tolerance:
+		errs.add("total", CodeMismatch,
+			fmt.Sprintf("must be within %s of the sum of the item prices, %s", policy.Tolerance, discrepancy.ItemsTotal))
+	default:
+		receipt.Discrepancy = discrepancy
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Use the policy for the rest of the test, putting the old one back after
+func usePolicy(t *testing.T, policy ReconciliationPolicy) {
+	previous := CurrentReconciliationPolicy()
+	SetReconciliationPolicy(policy)
+	t.Cleanup(func() { SetReconciliationPolicy(previous) })
+}
+
+// A receipt whose items add up to 3.40 but which claims a total of total
+func mismatchedReceipt(total string) Receipt {
+	return Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Mike & Ikes", Price: MustParseMoney("1.15")},
+		},
+		Total: MustParseMoney(total),
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	testTable := []struct {
+		total    string
+		expected *Discrepancy
+	}{
+		{"3.40", nil},
+		{"3.65", &Discrepancy{ItemsTotal: MustParseMoney("3.40"), Difference: MustParseMoney("0.25")}},
+		{"3.00", &Discrepancy{ItemsTotal: MustParseMoney("3.40"), Difference: MustParseMoney("-0.40")}},
+	}
+
+	for _, test := range testTable {
+		if got := Reconcile(mismatchedReceipt(test.total)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Reconcile(total %s) = got %+v, wanted %+v", test.total, got, test.expected)
+		}
+	}
+}
+
+func TestReconciliationPolicies(t *testing.T) {
+	testTable := []struct {
+		mode      string
+		tolerance string
+		total     string
+		expected  []string
+		flagged   bool
+	}{
+		{ReconcileStrict, "0.00", "3.40", nil, false},
+		{ReconcileStrict, "0.00", "3.41", []string{"total:mismatch"}, false},
+		{ReconcileLenient, "0.50", "3.90", nil, true},
+		{ReconcileLenient, "0.50", "2.90", nil, true},
+		{ReconcileLenient, "0.50", "3.91", []string{"total:mismatch"}, false},
+		{ReconcileFlag, "0.00", "3.40", nil, false},
+		{ReconcileFlag, "0.00", "100.00", nil, true},
+	}
+
+	for _, test := range testTable {
+		policy, err := NewReconciliationPolicy(test.mode, test.tolerance)
+		if err != nil {
+			t.Fatalf("NewReconciliationPolicy(%q, %q) got an error: %q", test.mode, test.tolerance, err.Error())
+		}
+		usePolicy(t, policy)
+
+		var errs fieldErrors
+		receipt := mismatchedReceipt(test.total)
+		reconcileReceipt(&errs, &receipt)
+
+		if codes := fieldCodes(errs.err()); !reflect.DeepEqual(codes, test.expected) {
+			t.Errorf("%s policy with total %s = got errors %v, wanted %v", test.mode, test.total, codes, test.expected)
+		}
+		if flagged := receipt.Discrepancy != nil; flagged != test.flagged {
+			t.Errorf("%s policy with total %s = got flagged %v, wanted %v", test.mode, test.total, flagged, test.flagged)
+		}
+	}
+}
+
+func TestNewReconciliationPolicyErrors(t *testing.T) {
+	testTable := []struct {
+		mode      string
+		tolerance string
+	}{
+		{"loose", "0.00"},
+		{"", "0.00"},
+		{ReconcileLenient, "fifty cents"},
+		{ReconcileLenient, "0.505"},
+		{ReconcileLenient, "-0.50"},
+	}
+
+	for _, test := range testTable {
+		if _, err := NewReconciliationPolicy(test.mode, test.tolerance); err == nil {
+			t.Errorf("NewReconciliationPolicy(%q, %q) should have returned an error", test.mode, test.tolerance)
+		}
+	}
+}
+
+func TestExcludedFromScoring(t *testing.T) {
+	usePolicy(t, ReconciliationPolicy{Mode: ReconcileFlag})
+	if ExcludedFromScoring(mismatchedReceipt("5.00")) {
+		t.Errorf("ExcludedFromScoring should be false under the flag policy")
+	}
+
+	usePolicy(t, ReconciliationPolicy{Mode: ReconcileStrict})
+	if !ExcludedFromScoring(mismatchedReceipt("5.00")) {
+		t.Errorf("ExcludedFromScoring should be true for a mismatched receipt under the strict policy")
+	}
+	if ExcludedFromScoring(mismatchedReceipt("3.40")) {
+		t.Errorf("ExcludedFromScoring should be false for a receipt that adds up")
+	}
+}
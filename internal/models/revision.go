@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned by a store when a receipt has no revision with the given number
+var ErrRevisionNotFound = errors.New("Revision not found")
+
+// The changes a revision can record
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRescore = "rescore"
+)
+
+// One field that a revision changed
+type FieldChange struct {
+	// Path of the field, e.g. "total" or "items[1].price"
+	Field string `json:"field" example:"total"`
+	// The value before the change, missing if the field was added
+	Before any `json:"before,omitempty" swaggertype:"string" example:"18.74"`
+	// The value after the change, missing if the field was removed
+	After any `json:"after,omitempty" swaggertype:"string" example:"20.74"`
+}
+
+// An immutable record of one change to a receipt
+type Revision struct {
+	// The receipt that was changed
+	ReceiptID string `json:"receiptId"`
+	// Counts up from 1 for each receipt
+	Number int `json:"revision" example:"2"`
+	// One of "create", "update", "delete" or "rescore"
+	Action string `json:"action" example:"update"`
+	// Who made the change
+	Actor string `json:"actor" example:"support@example.com"`
+	// When the change was made
+	CreatedAt time.Time `json:"createdAt"`
+	// Every field that changed
+	Changes []FieldChange `json:"changes"`
+	// The points the receipt was worth before the change, missing when it
+	// didn't exist or hadn't been scored
+	PointsBefore *int `json:"pointsBefore,omitempty" example:"20"`
+	// The points the receipt was worth after the change, missing when it
+	// was deleted or hadn't been scored yet
+	PointsAfter *int `json:"pointsAfter,omitempty" example:"30"`
+	// The receipt as it was after the change, or as it was when deleted
+	Receipt Receipt `json:"receipt"`
+}
+
+// List the fields that differ between two versions of a receipt. Either can
+// be nil, for a receipt that was just created or was deleted.
+func DiffReceipts(before *Receipt, after *Receipt) []FieldChange {
+	changes := []FieldChange{}
+	compare := func(field string, old any, new any) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Before: old, After: new})
+		}
+	}
+
+	fields := func(receipt *Receipt) map[string]any {
+		values := map[string]any{}
+		if receipt == nil {
+			return values
+		}
+
+		values["retailer"] = receipt.Retailer
+		values["purchaseDate"] = receipt.PurchaseDate
+		values["purchaseTime"] = receipt.PurchaseTime
+		if receipt.TimeZone != "" {
+			values["timeZone"] = receipt.TimeZone
+		}
+		values["total"] = receipt.Total.String()
+		for i, item := range receipt.Items {
+			values[fmt.Sprintf("items[%d].shortDescription", i)] = item.ShortDescription
+			values[fmt.Sprintf("items[%d].price", i)] = item.Price.String()
+		}
+		if receipt.Discrepancy != nil {
+			values["discrepancy.itemsTotal"] = receipt.Discrepancy.ItemsTotal.String()
+			values["discrepancy.difference"] = receipt.Discrepancy.Difference.String()
+		}
+		if receipt.DuplicateOf != "" {
+			values["duplicateOf"] = receipt.DuplicateOf
+		}
+		return values
+	}
+	old, new := fields(before), fields(after)
+
+	// Walk the fields in a fixed order so that diffs read the same each time
+	order := []string{"retailer", "purchaseDate", "purchaseTime", "timeZone", "total"}
+	items := 0
+	if before != nil {
+		items = len(before.Items)
+	}
+	if after != nil && len(after.Items) > items {
+		items = len(after.Items)
+	}
+	for i := 0; i < items; i++ {
+		order = append(order, fmt.Sprintf("items[%d].shortDescription", i), fmt.Sprintf("items[%d].price", i))
+	}
+	order = append(order, "discrepancy.itemsTotal", "discrepancy.difference", "duplicateOf")
+
+	for _, field := range order {
+		compare(field, old[field], new[field])
+	}
+	return changes
+}
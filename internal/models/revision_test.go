@@ -0,0 +1,41 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffReceipts(t *testing.T) {
+	before := fingerprintReceipt()
+	after := fingerprintReceipt()
+	after.Retailer = "M&M Market"
+	after.Items[1].Price = MustParseMoney("1.25")
+	after.Items = append(after.Items, Item{ShortDescription: "Pez", Price: MustParseMoney("0.50")})
+	after.Discrepancy = &Discrepancy{ItemsTotal: MustParseMoney("4.00"), Difference: MustParseMoney("-0.60")}
+
+	expected := []FieldChange{
+		{Field: "retailer", Before: "M&M Corner Market", After: "M&M Market"},
+		{Field: "items[1].price", Before: "1.15", After: "1.25"},
+		{Field: "items[2].shortDescription", After: "Pez"},
+		{Field: "items[2].price", After: "0.50"},
+		{Field: "discrepancy.itemsTotal", After: "4.00"},
+		{Field: "discrepancy.difference", After: "-0.60"},
+	}
+	if got := DiffReceipts(&before, &after); !reflect.DeepEqual(got, expected) {
+		t.Errorf("DiffReceipts = got %+v, wanted %+v", got, expected)
+	}
+
+	if got := DiffReceipts(&before, &before); len(got) != 0 {
+		t.Errorf("DiffReceipts of the same receipt = got %+v, wanted no changes", got)
+	}
+
+	// A new receipt has every field added, a deleted one every field removed
+	created := DiffReceipts(nil, &before)
+	deleted := DiffReceipts(&before, nil)
+	if len(created) != 8 || created[0] != (FieldChange{Field: "retailer", After: "M&M Corner Market"}) {
+		t.Errorf("DiffReceipts of a new receipt = got %+v, wanted all 8 fields added", created)
+	}
+	if len(deleted) != 8 || deleted[3] != (FieldChange{Field: "total", Before: "3.40"}) {
+		t.Errorf("DiffReceipts of a deleted receipt = got %+v, wanted all 8 fields removed", deleted)
+	}
+}
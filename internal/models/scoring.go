@@ -0,0 +1,52 @@
+package models
+
+import "encoding/json"
+
+// Where a receipt is in scoring
+const (
+	// Waiting to be scored
+	StatusPending = "pending"
+	// Scored, the points are stored on the receipt
+	StatusScored = "scored"
+	// Couldn't be scored, the reason is stored on the receipt
+	StatusFailed = "failed"
+)
+
+// The ruleset version of receipts scored before versions were stored, they
+// have no breakdown
+const LegacyRulesetVersion = "legacy"
+
+// The outcome of scoring a receipt
+type ReceiptScore struct {
+	// StatusScored or StatusFailed
+	Status string
+	// The points awarded, nil unless the receipt was scored
+	Points *int
+	// The result of every rule as JSON, nil unless the receipt was scored
+	Breakdown json.RawMessage
+	// The version of the ruleset the receipt was scored with
+	RulesetVersion string
+	// Why scoring failed
+	Error string
+	// The content version of the receipt that was scored, the score isn't
+	// saved if the receipt has changed since
+	ContentVersion int64
+	// Only save the score if the receipt is still pending, so a background
+	// score doesn't replace a rescore that finished first
+	IfPending bool
+}
+
+// Whether the score can be saved on the receipt as it is now
+func (s ReceiptScore) fits(receipt Receipt) bool {
+	if s.ContentVersion != receipt.ContentVersion {
+		return false
+	}
+	return !s.IfPending || receipt.Status == StatusPending
+}
+
+// New and changed receipts wait to be scored
+func defaultStatus(receipt *Receipt) {
+	if receipt.Status == "" {
+		receipt.Status = StatusPending
+	}
+}
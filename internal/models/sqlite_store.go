@@ -0,0 +1,798 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Schema migrations for the SQLite store. Each entry is applied once, in
+// order, and its position in the list is its version number. Never edit an
+// entry that has shipped, append a new one instead.
+var sqliteMigrations = []string{
+	// 1: receipts
+	`CREATE TABLE receipts (
+		id            TEXT PRIMARY KEY,
+		retailer      TEXT NOT NULL,
+		purchase_date TEXT NOT NULL,
+		purchase_time TEXT NOT NULL,
+		total         TEXT NOT NULL
+	)`,
+	// 2: items, kept in the order they were printed on the receipt
+	`CREATE TABLE receipt_items (
+		receipt_id        TEXT NOT NULL REFERENCES receipts(id),
+		position          INTEGER NOT NULL,
+		short_description TEXT NOT NULL,
+		price             TEXT NOT NULL,
+		PRIMARY KEY (receipt_id, position)
+	)`,
+	// 3: discrepancy between the total and the item prices, NULL when they match
+	`ALTER TABLE receipts ADD COLUMN items_total TEXT;
+	ALTER TABLE receipts ADD COLUMN total_difference TEXT;`,
+	// 4: content fingerprints for finding duplicate receipts
+	`ALTER TABLE receipts ADD COLUMN fingerprint TEXT;
+	ALTER TABLE receipts ADD COLUMN duplicate_of TEXT;
+	CREATE INDEX receipts_fingerprint ON receipts(fingerprint);`,
+	// 5: idempotency keys, created_at is in unix nanoseconds
+	`CREATE TABLE idempotency_keys (
+		key          TEXT PRIMARY KEY,
+		request_hash TEXT NOT NULL,
+		status_code  INTEGER NOT NULL,
+		receipt_id   TEXT NOT NULL,
+		created_at   INTEGER NOT NULL
+	);
+	CREATE INDEX idempotency_keys_created_at ON idempotency_keys(created_at);`,
+	// 6: indexes for filtering and sorting, totals are also kept in cents so
+	// they sort as numbers
+	`ALTER TABLE receipts ADD COLUMN total_cents INTEGER;
+	UPDATE receipts SET total_cents = CAST(ROUND(CAST(total AS REAL) * 100) AS INTEGER);
+	CREATE INDEX receipts_retailer ON receipts(retailer COLLATE NOCASE);
+	CREATE INDEX receipts_purchase_date ON receipts(purchase_date);
+	CREATE INDEX receipts_total_cents ON receipts(total_cents);`,
+	// 7: soft deletes, deleted_at is in unix nanoseconds
+	`ALTER TABLE receipts ADD COLUMN deleted_at INTEGER`,
+	// 8: revision history, the changes and the receipt are kept as JSON and
+	// created_at is in unix nanoseconds
+	`CREATE TABLE receipt_revisions (
+		receipt_id    TEXT NOT NULL,
+		number        INTEGER NOT NULL,
+		action        TEXT NOT NULL,
+		actor         TEXT NOT NULL,
+		created_at    INTEGER NOT NULL,
+		changes       TEXT NOT NULL,
+		points_before INTEGER,
+		points_after  INTEGER,
+		receipt       TEXT NOT NULL,
+		PRIMARY KEY (receipt_id, number)
+	)`,
+	// 9: scoring status and the points awarded, receipts stored before
+	// scoring was saved are scored again
+	`ALTER TABLE receipts ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+	ALTER TABLE receipts ADD COLUMN points INTEGER;
+	ALTER TABLE receipts ADD COLUMN score_error TEXT;
+	CREATE INDEX receipts_status ON receipts(status);`,
+	// 10: the breakdown of every score as JSON and the version of the
+	// ruleset that produced it, receipts scored before keep their points
+	// under the legacy version
+	`ALTER TABLE receipts ADD COLUMN breakdown TEXT;
+	ALTER TABLE receipts ADD COLUMN ruleset_version TEXT;
+	UPDATE receipts SET ruleset_version = '` + LegacyRulesetVersion + `' WHERE status != 'pending';`,
+	// 11: the time zone of the purchase date and time, when the receipt gave one
+	`ALTER TABLE receipts ADD COLUMN time_zone TEXT`,
+	// 12: the purchase date and time as they were sent, when they weren't
+	// already in their stored form
+	`ALTER TABLE receipts ADD COLUMN raw_purchase_date TEXT;
+	ALTER TABLE receipts ADD COLUMN raw_purchase_time TEXT;`,
+	// 13: listing receipts by points, unscored receipts count as none
+	`CREATE INDEX receipts_points ON receipts(COALESCE(points, 0))`,
+	// 14: counts the updates to a receipt, so stale scores aren't saved
+	`ALTER TABLE receipts ADD COLUMN content_version INTEGER NOT NULL DEFAULT 0`,
+}
+
+// The column each sort orders by
+var sqliteSortColumns = map[string]string{
+	SortCreated: "rowid",
+	SortDate:    "purchase_date",
+	SortTotal:   "total_cents",
+	SortPoints:  "COALESCE(points, 0)",
+}
+
+// The receipt columns read by scanReceipt, in order
+const receiptColumns = `id, retailer, purchase_date, purchase_time, time_zone, raw_purchase_date, raw_purchase_time, total, items_total, total_difference, fingerprint, duplicate_of,
+	status, points, score_error, breakdown, ruleset_version, content_version`
+
+// Anything rows can be scanned from, a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Receipt storage backed by an embedded SQLite database
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// Opens (or creates) the SQLite database at path and brings its schema up
+// to date. Use ":memory:" for a throwaway database.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time, and every connection to
+	// ":memory:" would get its own empty database
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Close the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Apply every migration that hasn't been applied yet
+func (s *SQLiteStore) migrate() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for i := current; i < len(sqliteMigrations); i++ {
+		version := i + 1
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Store the receipt and its items under a newly generated id
+func (s *SQLiteStore) AddReceipt(newReceipt Receipt) (string, error) {
+	newReceipt.ID = ""
+	ids, err := s.AddReceipts([]Receipt{newReceipt})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// Store every receipt and its items in a single transaction
+func (s *SQLiteStore) AddReceipts(newReceipts []Receipt) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(newReceipts))
+	for _, newReceipt := range newReceipts {
+		if newReceipt.ID == "" {
+			newReceipt.ID = NewReceiptID()
+		}
+
+		err := insertReceipt(tx, newReceipt)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			err = ErrDuplicateReceiptID
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		ids = append(ids, newReceipt.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Replace the contents of a receipt and its items
+func (s *SQLiteStore) UpdateReceipt(id string, receipt Receipt) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defaultStatus(&receipt)
+	itemsTotal, difference := discrepancyValues(receipt)
+	result, err := tx.Exec(`UPDATE receipts SET retailer = ?, purchase_date = ?, purchase_time = ?, time_zone = ?,
+		raw_purchase_date = ?, raw_purchase_time = ?, total = ?,
+		items_total = ?, total_difference = ?, fingerprint = ?, duplicate_of = ?, total_cents = ?,
+		status = ?, points = ?, score_error = ?, breakdown = ?, ruleset_version = ?,
+		content_version = content_version + 1
+		WHERE id = ? AND deleted_at IS NULL`,
+		receipt.Retailer, receipt.PurchaseDate, receipt.PurchaseTime, nullString(receipt.TimeZone),
+		nullString(receipt.RawPurchaseDate), nullString(receipt.RawPurchaseTime), receipt.Total,
+		itemsTotal, difference, nullString(receipt.Fingerprint), nullString(receipt.DuplicateOf), receipt.Total.Cents(),
+		receipt.Status, receipt.Points, nullString(receipt.ScoreError),
+		nullString(string(receipt.Breakdown)), nullString(receipt.RulesetVersion),
+		id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		return ErrReceiptNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM receipt_items WHERE receipt_id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := insertItems(tx, id, receipt.Items); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Store the outcome of scoring a receipt
+func (s *SQLiteStore) SaveScore(id string, score ReceiptScore) error {
+	statement := `UPDATE receipts SET status = ?, points = ?, score_error = ?, breakdown = ?, ruleset_version = ?
+		WHERE id = ? AND deleted_at IS NULL AND content_version = ?`
+	if score.IfPending {
+		statement += ` AND status = '` + StatusPending + `'`
+	}
+	result, err := s.db.Exec(statement,
+		score.Status, score.Points, nullString(score.Error), nullString(string(score.Breakdown)), nullString(score.RulesetVersion),
+		id, score.ContentVersion)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		// Either the receipt is gone or the score no longer fits it
+		if _, err := s.GetReceipt(id); err != nil {
+			return err
+		}
+		return ErrStaleScore
+	}
+	return nil
+}
+
+// Load a single receipt and its items
+func (s *SQLiteStore) GetReceipt(id string) (*Receipt, error) {
+	receipt, err := scanReceipt(s.db.QueryRow(`SELECT `+receiptColumns+` FROM receipts WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	receipt.Items, err = s.loadItems(receipt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
+}
+
+// Load a page of the receipts that match the query
+func (s *SQLiteStore) QueryReceipts(query ReceiptQuery) (ReceiptPage, error) {
+	if err := query.validate(); err != nil {
+		return ReceiptPage{}, err
+	}
+	cursor, err := query.decodeCursor()
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	where := []string{`deleted_at IS NULL`}
+	var args []any
+
+	if query.Retailer != "" {
+		where = append(where, `retailer = ? COLLATE NOCASE`)
+		args = append(args, strings.TrimSpace(query.Retailer))
+	}
+	if query.DateFrom != "" {
+		where = append(where, `purchase_date >= ?`)
+		args = append(args, query.DateFrom)
+	}
+	if query.DateTo != "" {
+		where = append(where, `purchase_date <= ?`)
+		args = append(args, query.DateTo)
+	}
+	if query.MinTotal != nil {
+		where = append(where, `total_cents >= ?`)
+		args = append(args, query.MinTotal.Cents())
+	}
+	if query.MaxTotal != nil {
+		where = append(where, `total_cents <= ?`)
+		args = append(args, query.MaxTotal.Cents())
+	}
+	if query.Status != "" {
+		where = append(where, `status = ?`)
+		args = append(args, query.Status)
+	}
+
+	column := sqliteSortColumns[query.sortOrder()]
+	direction, after := "ASC", ">"
+	if query.Descending {
+		direction, after = "DESC", "<"
+	}
+
+	// Continue after the last receipt of the previous page
+	if cursor != nil {
+		if column == "rowid" {
+			where = append(where, `rowid `+after+` ?`)
+			args = append(args, cursor.Seq)
+		} else {
+			var key any = cursor.Key
+			if query.sortOrder() == SortTotal || query.sortOrder() == SortPoints {
+				key, _ = strconv.ParseInt(cursor.Key, 10, 64)
+			}
+			where = append(where, `(`+column+` `+after+` ? OR (`+column+` = ? AND rowid `+after+` ?))`)
+			args = append(args, key, key, cursor.Seq)
+		}
+	}
+
+	statement := `SELECT ` + receiptColumns + `, rowid FROM receipts WHERE ` + strings.Join(where, ` AND `)
+	statement += ` ORDER BY ` + column + ` ` + direction
+	if column != "rowid" {
+		statement += `, rowid ` + direction
+	}
+	if query.Limit > 0 {
+		// One more than asked for, to know if there is another page
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit+1)
+	}
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	page := ReceiptPage{Receipts: []Receipt{}}
+	var lastSeq int64
+	for rows.Next() {
+		if query.Limit > 0 && len(page.Receipts) == query.Limit {
+			page.NextCursor = query.encodeCursor(page.Receipts[len(page.Receipts)-1], lastSeq)
+			break
+		}
+
+		receipt, err := scanReceipt(rows, &lastSeq)
+		if err != nil {
+			rows.Close()
+			return ReceiptPage{}, err
+		}
+		page.Receipts = append(page.Receipts, *receipt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ReceiptPage{}, err
+	}
+
+	if err := s.loadItemsOf(page.Receipts); err != nil {
+		return ReceiptPage{}, err
+	}
+
+	return page, nil
+}
+
+// Load the earliest receipt with the given fingerprint
+func (s *SQLiteStore) FindByFingerprint(fingerprint string) (*Receipt, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT id FROM receipts WHERE fingerprint = ? AND deleted_at IS NULL ORDER BY rowid LIMIT 1`, fingerprint).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReceipt(id)
+}
+
+// Load every receipt in the order they were added
+func (s *SQLiteStore) ListReceipts() ([]Receipt, error) {
+	rows, err := s.db.Query(`SELECT ` + receiptColumns + ` FROM receipts WHERE deleted_at IS NULL ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := []Receipt{}
+	for rows.Next() {
+		receipt, err := scanReceipt(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		receipts = append(receipts, *receipt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Items are loaded after the receipts cursor is closed since the store
+	// only has a single connection
+	if err := s.loadItemsOf(receipts); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// Mark a receipt as deleted, it stays in the database until it is purged
+func (s *SQLiteStore) DeleteReceipt(id string) error {
+	result, err := s.db.Exec(`UPDATE receipts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		time.Now().UnixNano(), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrReceiptNotFound
+	}
+	return nil
+}
+
+// Remove a receipt, its items and the idempotency keys that point at it
+func (s *SQLiteStore) PurgeReceipt(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		`DELETE FROM receipt_items WHERE receipt_id = ?`,
+		`DELETE FROM idempotency_keys WHERE receipt_id = ?`,
+		`DELETE FROM receipt_revisions WHERE receipt_id = ?`,
+	} {
+		if _, err := tx.Exec(statement, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM receipts WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		return ErrReceiptNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Store the revision under the next number for its receipt
+func (s *SQLiteStore) AddRevision(revision Revision) (Revision, error) {
+	changes, err := json.Marshal(revision.Changes)
+	if err != nil {
+		return Revision{}, err
+	}
+	receipt, err := json.Marshal(revision.Receipt)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Revision{}, err
+	}
+
+	err = tx.QueryRow(`SELECT COALESCE(MAX(number), 0) + 1 FROM receipt_revisions WHERE receipt_id = ?`, revision.ReceiptID).
+		Scan(&revision.Number)
+	if err != nil {
+		tx.Rollback()
+		return Revision{}, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO receipt_revisions (receipt_id, number, action, actor, created_at, changes, points_before, points_after, receipt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		revision.ReceiptID, revision.Number, revision.Action, revision.Actor, revision.CreatedAt.UnixNano(),
+		string(changes), revision.PointsBefore, revision.PointsAfter, string(receipt))
+	if err != nil {
+		tx.Rollback()
+		return Revision{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Revision{}, err
+	}
+	return revision, nil
+}
+
+// Load the revisions of the receipt, oldest first
+func (s *SQLiteStore) ListRevisions(receiptId string) ([]Revision, error) {
+	rows, err := s.db.Query(`SELECT `+revisionColumns+` FROM receipt_revisions WHERE receipt_id = ? ORDER BY number`, receiptId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+	return revisions, rows.Err()
+}
+
+// Load the numbered revision of the receipt
+func (s *SQLiteStore) GetRevision(receiptId string, number int) (*Revision, error) {
+	revision, err := scanRevision(s.db.QueryRow(`SELECT `+revisionColumns+` FROM receipt_revisions WHERE receipt_id = ? AND number = ?`,
+		receiptId, number))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+	return revision, err
+}
+
+// Store the idempotency record, replacing any with the same key
+func (s *SQLiteStore) SaveIdempotencyKey(record IdempotencyRecord) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO idempotency_keys (key, request_hash, status_code, receipt_id, created_at) VALUES (?, ?, ?, ?, ?)`,
+		record.Key, record.RequestHash, record.StatusCode, record.ReceiptID, record.CreatedAt.UnixNano())
+	return err
+}
+
+// Load the idempotency record for the key
+func (s *SQLiteStore) GetIdempotencyKey(key string) (*IdempotencyRecord, error) {
+	var record IdempotencyRecord
+	var createdAt int64
+
+	err := s.db.QueryRow(`SELECT key, request_hash, status_code, receipt_id, created_at FROM idempotency_keys WHERE key = ?`, key).
+		Scan(&record.Key, &record.RequestHash, &record.StatusCode, &record.ReceiptID, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.CreatedAt = time.Unix(0, createdAt)
+	return &record, nil
+}
+
+// Remove the idempotency records created before the given time
+func (s *SQLiteStore) PurgeIdempotencyKeys(before time.Time) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// Insert a receipt and its items under the receipt's id
+func insertReceipt(tx *sql.Tx, receipt Receipt) error {
+	defaultStatus(&receipt)
+	itemsTotal, difference := discrepancyValues(receipt)
+	_, err := tx.Exec(`INSERT INTO receipts (`+receiptColumns+`, total_cents) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		receipt.ID, receipt.Retailer, receipt.PurchaseDate, receipt.PurchaseTime, nullString(receipt.TimeZone),
+		nullString(receipt.RawPurchaseDate), nullString(receipt.RawPurchaseTime), receipt.Total,
+		itemsTotal, difference, nullString(receipt.Fingerprint), nullString(receipt.DuplicateOf),
+		receipt.Status, receipt.Points, nullString(receipt.ScoreError),
+		nullString(string(receipt.Breakdown)), nullString(receipt.RulesetVersion), receipt.ContentVersion,
+		receipt.Total.Cents())
+	if err != nil {
+		return err
+	}
+
+	return insertItems(tx, receipt.ID, receipt.Items)
+}
+
+// Insert the items of a receipt in their printed order
+func insertItems(tx *sql.Tx, receiptId string, items []Item) error {
+	for position, item := range items {
+		_, err := tx.Exec(`INSERT INTO receipt_items (receipt_id, position, short_description, price) VALUES (?, ?, ?, ?)`,
+			receiptId, position, item.ShortDescription, item.Price)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The items_total and total_difference of a receipt, both NULL when it has
+// no discrepancy
+func discrepancyValues(receipt Receipt) (*Money, *Money) {
+	if receipt.Discrepancy == nil {
+		return nil, nil
+	}
+	return &receipt.Discrepancy.ItemsTotal, &receipt.Discrepancy.Difference
+}
+
+// Load the items of a receipt in their printed order
+func (s *SQLiteStore) loadItems(receiptId string) ([]Item, error) {
+	rows, err := s.db.Query(`SELECT short_description, price FROM receipt_items WHERE receipt_id = ? ORDER BY position`, receiptId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ShortDescription, &item.Price); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// How many receipts have their items loaded by one query, well under the
+// number of parameters SQLite allows in a statement
+const itemsBatchSize = 500
+
+// Load the items of every receipt with one query per batch of receipts,
+// rather than one per receipt
+func (s *SQLiteStore) loadItemsOf(receipts []Receipt) error {
+	for start := 0; start < len(receipts); start += itemsBatchSize {
+		end := start + itemsBatchSize
+		if end > len(receipts) {
+			end = len(receipts)
+		}
+		batch := receipts[start:end]
+
+		positions := make(map[string]int, len(batch))
+		args := make([]any, 0, len(batch))
+		for i := range batch {
+			batch[i].Items = []Item{}
+			positions[batch[i].ID] = i
+			args = append(args, batch[i].ID)
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		rows, err := s.db.Query(`SELECT receipt_id, short_description, price FROM receipt_items
+			WHERE receipt_id IN (`+placeholders+`) ORDER BY receipt_id, position`, args...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var receiptId string
+			var item Item
+			if err := rows.Scan(&receiptId, &item.ShortDescription, &item.Price); err != nil {
+				rows.Close()
+				return err
+			}
+			receipt := &batch[positions[receiptId]]
+			receipt.Items = append(receipt.Items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The revision columns read by scanRevision, in order
+const revisionColumns = `receipt_id, number, action, actor, created_at, changes, points_before, points_after, receipt`
+
+// Read the revisionColumns of a row into a revision
+func scanRevision(row scanner) (*Revision, error) {
+	var revision Revision
+	var createdAt int64
+	var changes, receipt string
+	var pointsBefore, pointsAfter sql.NullInt64
+
+	err := row.Scan(&revision.ReceiptID, &revision.Number, &revision.Action, &revision.Actor, &createdAt,
+		&changes, &pointsBefore, &pointsAfter, &receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	revision.CreatedAt = time.Unix(0, createdAt)
+	if pointsBefore.Valid {
+		points := int(pointsBefore.Int64)
+		revision.PointsBefore = &points
+	}
+	if pointsAfter.Valid {
+		points := int(pointsAfter.Int64)
+		revision.PointsAfter = &points
+	}
+	if err := json.Unmarshal([]byte(changes), &revision.Changes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(receipt), &revision.Receipt); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Read the receiptColumns of a row into a receipt, without its items. Any
+// columns selected after them are read into extra.
+func scanReceipt(row scanner, extra ...any) (*Receipt, error) {
+	var receipt Receipt
+	var timeZone, rawDate, rawTime, itemsTotal, difference, fingerprint, duplicateOf, scoreError, breakdown, rulesetVersion sql.NullString
+	var points sql.NullInt64
+
+	dest := []any{&receipt.ID, &receipt.Retailer, &receipt.PurchaseDate, &receipt.PurchaseTime, &timeZone, &rawDate, &rawTime, &receipt.Total,
+		&itemsTotal, &difference, &fingerprint, &duplicateOf, &receipt.Status, &points, &scoreError,
+		&breakdown, &rulesetVersion, &receipt.ContentVersion}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TimeZone = timeZone.String
+	receipt.RawPurchaseDate = rawDate.String
+	receipt.RawPurchaseTime = rawTime.String
+	receipt.Fingerprint = fingerprint.String
+	receipt.DuplicateOf = duplicateOf.String
+	receipt.ScoreError = scoreError.String
+	receipt.RulesetVersion = rulesetVersion.String
+	if breakdown.Valid {
+		receipt.Breakdown = json.RawMessage(breakdown.String)
+	}
+	if points.Valid {
+		value := int(points.Int64)
+		receipt.Points = &value
+	}
+
+	if itemsTotal.Valid && difference.Valid {
+		receipt.Discrepancy = &Discrepancy{}
+		if err := receipt.Discrepancy.ItemsTotal.Scan(itemsTotal.String); err != nil {
+			return nil, err
+		}
+		if err := receipt.Discrepancy.Difference.Scan(difference.String); err != nil {
+			return nil, err
+		}
+	}
+
+	return &receipt, nil
+}
+
+// Stores empty strings as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
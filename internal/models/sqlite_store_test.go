@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore got an error: %q", err.Error())
+	}
+
+	newId, _ := store.AddReceipt(newTestReceipt())
+	store.Close()
+
+	// Opening again must not re-run the migrations or lose the receipt
+	reopened, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore could not reopen the database: %q", err.Error())
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetReceipt(newId); err != nil {
+		t.Errorf("GetReceipt could not find %q after reopening: %v", newId, err)
+	}
+
+	var version int
+	reopened.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if version != len(sqliteMigrations) {
+		t.Errorf("schema version = got %d, wanted %d", version, len(sqliteMigrations))
+	}
+}
+
+func TestSQLiteStoreKeepsLegacyScores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	// A database from before ruleset versions were stored, with a scored
+	// receipt
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open got an error: %q", err.Error())
+	}
+	db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`)
+	for i, migration := range sqliteMigrations[:9] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatalf("migration %d got an error: %q", i+1, err.Error())
+		}
+		db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '')`, i+1)
+	}
+	db.Exec(`INSERT INTO receipts (id, retailer, purchase_date, purchase_time, total, status, points)
+		VALUES ('scored', 'Target', '2022-01-01', '13:01', '35.35', 'scored', 28)`)
+	db.Close()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore got an error: %q", err.Error())
+	}
+	defer store.Close()
+
+	receipt, err := store.GetReceipt("scored")
+	if err != nil {
+		t.Fatalf("GetReceipt got an error: %q", err.Error())
+	}
+	if receipt.Status != StatusScored || receipt.Points == nil || *receipt.Points != 28 || receipt.RulesetVersion != LegacyRulesetVersion {
+		t.Errorf("GetReceipt after migrating = got status %q, points %v and version %q, wanted %q, 28 and %q",
+			receipt.Status, receipt.Points, receipt.RulesetVersion, StatusScored, LegacyRulesetVersion)
+	}
+}
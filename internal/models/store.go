@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Returned by a store when no receipt exists for the given id
+var ErrReceiptNotFound = errors.New("Receipt not found")
+
+// Returned by a store when a score was worked out from contents of the
+// receipt that have since changed, or from a pending receipt that has since
+// been scored
+var ErrStaleScore = errors.New("The receipt changed while it was being scored")
+
+// Returned by a store when a receipt is added with an id that is taken
+var ErrDuplicateReceiptID = errors.New("Receipt id is already taken")
+
+// A receipt store keeps receipts somewhere, in memory or in a database
+type ReceiptStore interface {
+	// Assigns a new id to the receipt, stores it, and returns the id
+	AddReceipt(receipt Receipt) (string, error)
+	// Stores every receipt or, if any of them can't be stored, none of them.
+	// Receipts without an id are given a new one. Returns the ids in order.
+	AddReceipts(receipts []Receipt) ([]string, error)
+	// Returns the receipt with the given id or ErrReceiptNotFound
+	GetReceipt(id string) (*Receipt, error)
+	// Returns every stored receipt in the order they were added
+	ListReceipts() ([]Receipt, error)
+	// Returns a page of the receipts that match the query, or
+	// ErrInvalidCursor if the query's cursor can't be used
+	QueryReceipts(query ReceiptQuery) (ReceiptPage, error)
+	// Returns the earliest stored receipt with the given fingerprint or
+	// ErrReceiptNotFound
+	FindByFingerprint(fingerprint string) (*Receipt, error)
+	// Replaces the contents of the receipt with the given id, keeping the id
+	// and counting up its content version, or returns ErrReceiptNotFound
+	UpdateReceipt(id string, receipt Receipt) error
+	// Stores the outcome of scoring the receipt with the given id. Returns
+	// ErrStaleScore if the score no longer fits the receipt, or
+	// ErrReceiptNotFound.
+	SaveScore(id string, score ReceiptScore) error
+	// Soft deletes the receipt with the given id so that it is no longer
+	// returned, or returns ErrReceiptNotFound
+	DeleteReceipt(id string) error
+	// Permanently removes the receipt with the given id, deleted or not, and
+	// its revisions, or returns ErrReceiptNotFound
+	PurgeReceipt(id string) error
+	// Stores the revision under the next number for its receipt and returns
+	// it with the number filled in
+	AddRevision(revision Revision) (Revision, error)
+	// Returns the revisions of the receipt, oldest first
+	ListRevisions(receiptId string) ([]Revision, error)
+	// Returns the numbered revision of the receipt or ErrRevisionNotFound
+	GetRevision(receiptId string, number int) (*Revision, error)
+	// Stores the record, replacing any record with the same key
+	SaveIdempotencyKey(record IdempotencyRecord) error
+	// Returns the record for the key or ErrIdempotencyKeyNotFound
+	GetIdempotencyKey(key string) (*IdempotencyRecord, error)
+	// Removes every record created before the given time and returns how
+	// many were removed
+	PurgeIdempotencyKeys(before time.Time) (int, error)
+}
+
+// Generate an id for a new receipt
+func NewReceiptID() string {
+	return uuid.NewString()
+}
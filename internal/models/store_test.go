@@ -0,0 +1,514 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Every store implementation has to pass the same behaviour tests
+var storeFactories = map[string]func(t *testing.T) ReceiptStore{
+	"memory": func(t *testing.T) ReceiptStore {
+		return NewMemoryStore()
+	},
+	"sqlite": func(t *testing.T) ReceiptStore {
+		store, err := NewSQLiteStore(":memory:")
+		if err != nil {
+			t.Fatalf("NewSQLiteStore got an error: %q", err.Error())
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	},
+}
+
+// Run the test against a fresh instance of every store
+func forEachStore(t *testing.T, test func(t *testing.T, store ReceiptStore)) {
+	for name, factory := range storeFactories {
+		t.Run(name, func(t *testing.T) {
+			test(t, factory(t))
+		})
+	}
+}
+
+func newTestReceipt() Receipt {
+	return Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2023-06-16",
+		PurchaseTime: "13:30",
+		Total:        MustParseMoney("0.00"),
+		Items:        nil,
+	}
+}
+
+func TestEmptyGetReceiptById(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		_, err := store.GetReceipt("555")
+
+		if !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("GetReceipt should return ErrReceiptNotFound if id doesn't exist, got %v", err)
+		}
+	})
+}
+
+func TestAddReceiptsAndGetReceiptsById(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		newReceipt := newTestReceipt()
+		newReceipt.Items = []Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: MustParseMoney("6.49")},
+			{ShortDescription: "Emils Cheese Pizza", Price: MustParseMoney("12.25")},
+		}
+
+		// Add a new receipt to the store
+		newId, err := store.AddReceipt(newReceipt)
+		if err != nil {
+			t.Fatalf("AddReceipt got an error: %q", err.Error())
+		}
+
+		// Retrieve that receipt using the ID
+		foundReceipt, err := store.GetReceipt(newId)
+
+		if err != nil {
+			t.Fatalf("GetReceipt should have found the id")
+		}
+
+		if foundReceipt.ID != newId {
+			t.Errorf("GetReceipt did not find the id it just added")
+		}
+
+		if foundReceipt.Retailer != newReceipt.Retailer || foundReceipt.Total != newReceipt.Total {
+			t.Errorf("GetReceipt returned %+v, wanted the fields of %+v", foundReceipt, newReceipt)
+		}
+
+		if len(foundReceipt.Items) != 2 || foundReceipt.Items[1] != newReceipt.Items[1] {
+			t.Errorf("GetReceipt returned items %+v, wanted %+v", foundReceipt.Items, newReceipt.Items)
+		}
+	})
+}
+
+func TestGetReceipts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		emptyReceipts, _ := store.ListReceipts()
+
+		if len(emptyReceipts) != 0 {
+			t.Errorf("ListReceipts should be empty")
+		}
+
+		// Add several new receipts to the store
+		var ids []string
+		for n := 0; n < 5; n++ {
+			id, _ := store.AddReceipt(newTestReceipt())
+			ids = append(ids, id)
+		}
+
+		allReceipts, _ := store.ListReceipts()
+		receiptCount := len(allReceipts)
+
+		if receiptCount != 5 {
+			t.Fatalf("ListReceipts did not get the corrent number of receipts: expected %d, got %d", 5, receiptCount)
+		}
+
+		for i, receipt := range allReceipts {
+			if receipt.ID != ids[i] {
+				t.Errorf("ListReceipts[%d] = got id %q, wanted %q", i, receipt.ID, ids[i])
+			}
+		}
+	})
+}
+
+func TestDeleteReceipt(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		keepId, _ := store.AddReceipt(newTestReceipt())
+		deleteId, _ := store.AddReceipt(newTestReceipt())
+
+		if err := store.DeleteReceipt(deleteId); err != nil {
+			t.Fatalf("DeleteReceipt got an error: %q", err.Error())
+		}
+
+		if _, err := store.GetReceipt(deleteId); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("GetReceipt found a deleted receipt")
+		}
+
+		if _, err := store.GetReceipt(keepId); err != nil {
+			t.Errorf("DeleteReceipt removed the wrong receipt")
+		}
+
+		if err := store.DeleteReceipt(deleteId); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("DeleteReceipt should return ErrReceiptNotFound for a missing id, got %v", err)
+		}
+	})
+}
+
+func TestStoreKeepsDiscrepancy(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		newReceipt := newTestReceipt()
+		newReceipt.Discrepancy = &Discrepancy{ItemsTotal: MustParseMoney("3.40"), Difference: MustParseMoney("-0.40")}
+		flaggedId, _ := store.AddReceipt(newReceipt)
+		cleanId, _ := store.AddReceipt(newTestReceipt())
+
+		flagged, err := store.GetReceipt(flaggedId)
+		if err != nil {
+			t.Fatalf("GetReceipt got an error: %q", err.Error())
+		}
+		if flagged.Discrepancy == nil || *flagged.Discrepancy != *newReceipt.Discrepancy {
+			t.Errorf("GetReceipt discrepancy = got %+v, wanted %+v", flagged.Discrepancy, newReceipt.Discrepancy)
+		}
+
+		clean, _ := store.GetReceipt(cleanId)
+		if clean.Discrepancy != nil {
+			t.Errorf("GetReceipt discrepancy = got %+v, wanted nil", clean.Discrepancy)
+		}
+	})
+}
+
+func TestFindByFingerprint(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		if _, err := store.FindByFingerprint("abc"); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("FindByFingerprint should return ErrReceiptNotFound for an empty store, got %v", err)
+		}
+
+		newReceipt := newTestReceipt()
+		newReceipt.Fingerprint = "abc"
+		firstId, _ := store.AddReceipt(newReceipt)
+		newReceipt.DuplicateOf = firstId
+		secondId, _ := store.AddReceipt(newReceipt)
+
+		found, err := store.FindByFingerprint("abc")
+		if err != nil {
+			t.Fatalf("FindByFingerprint got an error: %q", err.Error())
+		}
+		if found.ID != firstId {
+			t.Errorf("FindByFingerprint = got id %q, wanted the earliest %q", found.ID, firstId)
+		}
+
+		second, _ := store.GetReceipt(secondId)
+		if second.Fingerprint != "abc" || second.DuplicateOf != firstId {
+			t.Errorf("GetReceipt = got fingerprint %q duplicating %q, wanted %q duplicating %q", second.Fingerprint, second.DuplicateOf, "abc", firstId)
+		}
+
+		// Once the original is gone the duplicate is found instead
+		store.DeleteReceipt(firstId)
+		found, err = store.FindByFingerprint("abc")
+		if err != nil || found.ID != secondId {
+			t.Errorf("FindByFingerprint after a delete = got %v %v, wanted id %q", found, err, secondId)
+		}
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		if _, err := store.GetIdempotencyKey("a"); !errors.Is(err, ErrIdempotencyKeyNotFound) {
+			t.Errorf("GetIdempotencyKey should return ErrIdempotencyKeyNotFound for a new key, got %v", err)
+		}
+
+		now := time.Now()
+		old := IdempotencyRecord{Key: "a", RequestHash: "h1", StatusCode: 201, ReceiptID: "r1", CreatedAt: now.Add(-time.Hour)}
+		recent := IdempotencyRecord{Key: "b", RequestHash: "h2", StatusCode: 200, ReceiptID: "r2", CreatedAt: now}
+		store.SaveIdempotencyKey(old)
+		store.SaveIdempotencyKey(recent)
+
+		record, err := store.GetIdempotencyKey("b")
+		if err != nil {
+			t.Fatalf("GetIdempotencyKey got an error: %q", err.Error())
+		}
+		if record.RequestHash != "h2" || record.StatusCode != 200 || record.ReceiptID != "r2" || !record.CreatedAt.Equal(now) {
+			t.Errorf("GetIdempotencyKey = got %+v, wanted %+v", *record, recent)
+		}
+
+		purged, err := store.PurgeIdempotencyKeys(now.Add(-time.Minute))
+		if err != nil || purged != 1 {
+			t.Errorf("PurgeIdempotencyKeys = got %d %v, wanted 1 purged", purged, err)
+		}
+		if _, err := store.GetIdempotencyKey("a"); !errors.Is(err, ErrIdempotencyKeyNotFound) {
+			t.Errorf("GetIdempotencyKey should return ErrIdempotencyKeyNotFound for a purged key, got %v", err)
+		}
+	})
+}
+
+// Fetch every page of the query, returning the retailers in order
+func queryAll(t *testing.T, store ReceiptStore, query ReceiptQuery) []string {
+	var retailers []string
+	for {
+		page, err := store.QueryReceipts(query)
+		if err != nil {
+			t.Fatalf("QueryReceipts got an error: %q", err.Error())
+		}
+		if query.Limit > 0 && len(page.Receipts) > query.Limit {
+			t.Fatalf("QueryReceipts = got %d receipts, wanted at most %d", len(page.Receipts), query.Limit)
+		}
+		for _, receipt := range page.Receipts {
+			retailers = append(retailers, receipt.Retailer)
+		}
+		if page.NextCursor == "" {
+			return retailers
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestQueryReceipts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		// target is never scored, so it counts as no points
+		for _, receipt := range []struct {
+			retailer, date, total string
+			points                int
+		}{
+			{"A", "2022-01-03", "5.00", 30},
+			{"B", "2022-01-01", "10.00", 10},
+			{"target", "2022-01-02", "9.00", -1},
+			{"D", "2022-01-01", "100.00", 10},
+			{"Target", "2022-01-05", "10.00", 50},
+		} {
+			newReceipt := newTestReceipt()
+			newReceipt.Retailer, newReceipt.PurchaseDate, newReceipt.Total = receipt.retailer, receipt.date, MustParseMoney(receipt.total)
+			newReceipt.Items = []Item{{ShortDescription: "Pez", Price: newReceipt.Total}}
+			id, _ := store.AddReceipt(newReceipt)
+			if receipt.points >= 0 {
+				points := receipt.points
+				store.SaveScore(id, ReceiptScore{Status: StatusScored, Points: &points})
+			}
+		}
+
+		minTotal, maxTotal := MustParseMoney("9.00"), MustParseMoney("10.00")
+		testTable := []struct {
+			name     string
+			query    ReceiptQuery
+			expected []string
+		}{
+			{"created", ReceiptQuery{}, []string{"A", "B", "target", "D", "Target"}},
+			{"created paged", ReceiptQuery{Limit: 2}, []string{"A", "B", "target", "D", "Target"}},
+			{"created descending", ReceiptQuery{Limit: 2, Descending: true}, []string{"Target", "D", "target", "B", "A"}},
+			{"date", ReceiptQuery{Sort: SortDate, Limit: 1}, []string{"B", "D", "target", "A", "Target"}},
+			{"date descending", ReceiptQuery{Sort: SortDate, Descending: true, Limit: 2}, []string{"Target", "A", "target", "D", "B"}},
+			// 100.00 sorts after 9.00 and 10.00 as a number, not as text
+			{"total", ReceiptQuery{Sort: SortTotal, Limit: 2}, []string{"A", "target", "B", "Target", "D"}},
+			{"points", ReceiptQuery{Sort: SortPoints, Limit: 2}, []string{"target", "B", "D", "A", "Target"}},
+			{"points descending", ReceiptQuery{Sort: SortPoints, Descending: true, Limit: 1}, []string{"Target", "A", "D", "B", "target"}},
+			{"retailer", ReceiptQuery{Retailer: " TARGET "}, []string{"target", "Target"}},
+			{"dates", ReceiptQuery{DateFrom: "2022-01-02", DateTo: "2022-01-03"}, []string{"A", "target"}},
+			{"totals", ReceiptQuery{MinTotal: &minTotal, MaxTotal: &maxTotal, Sort: SortTotal, Limit: 1}, []string{"target", "B", "Target"}},
+			{"nothing", ReceiptQuery{Retailer: "Walgreens", Limit: 1}, nil},
+		}
+
+		for _, test := range testTable {
+			if got := queryAll(t, store, test.query); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("QueryReceipts(%s) = got %v, wanted %v", test.name, got, test.expected)
+			}
+		}
+
+		for _, query := range []ReceiptQuery{
+			{Sort: "retailer"},
+			{Cursor: "not a cursor"},
+			{Sort: SortPoints, Cursor: ReceiptQuery{Sort: SortTotal}.encodeCursor(newTestReceipt(), 1)},
+			{Sort: SortDate, Cursor: ReceiptQuery{}.encodeCursor(newTestReceipt(), 1)},
+		} {
+			if _, err := store.QueryReceipts(query); err == nil {
+				t.Errorf("QueryReceipts(%+v) should have returned an error", query)
+			}
+		}
+	})
+}
+
+func TestUpdateReceipt(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		firstId, _ := store.AddReceipt(newTestReceipt())
+		secondId, _ := store.AddReceipt(newTestReceipt())
+
+		changed := newTestReceipt()
+		changed.Retailer = "Walmart"
+		changed.TimeZone = "America/Chicago"
+		changed.RawPurchaseDate, changed.RawPurchaseTime = "06/16/2023", "1:30 PM"
+		changed.Total = MustParseMoney("1.40")
+		changed.Items = []Item{{ShortDescription: "Dasani", Price: MustParseMoney("1.40")}}
+		changed.Fingerprint = "changed"
+		if err := store.UpdateReceipt(firstId, changed); err != nil {
+			t.Fatalf("UpdateReceipt got an error: %q", err.Error())
+		}
+
+		// Changed receipts wait to be scored again, as a new version
+		updated, _ := store.GetReceipt(firstId)
+		changed.ID, changed.Status, changed.ContentVersion = firstId, StatusPending, 1
+		if !reflect.DeepEqual(*updated, changed) {
+			t.Errorf("GetReceipt after an update = got %+v, wanted %+v", *updated, changed)
+		}
+
+		// The receipt keeps its place in the order
+		receipts, _ := store.ListReceipts()
+		if len(receipts) != 2 || receipts[0].ID != firstId || receipts[1].ID != secondId {
+			t.Errorf("ListReceipts after an update = got %+v, wanted the updated receipt first", receipts)
+		}
+
+		if found, err := store.FindByFingerprint("changed"); err != nil || found.ID != firstId {
+			t.Errorf("FindByFingerprint after an update = got %v %v, wanted id %q", found, err, firstId)
+		}
+
+		if err := store.UpdateReceipt("555", changed); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("UpdateReceipt should return ErrReceiptNotFound for a missing id, got %v", err)
+		}
+
+		store.DeleteReceipt(secondId)
+		if err := store.UpdateReceipt(secondId, changed); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("UpdateReceipt should return ErrReceiptNotFound for a deleted receipt, got %v", err)
+		}
+	})
+}
+
+func TestPurgeReceipt(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		deletedId, _ := store.AddReceipt(newTestReceipt())
+		liveId, _ := store.AddReceipt(newTestReceipt())
+		store.SaveIdempotencyKey(IdempotencyRecord{Key: "k", ReceiptID: liveId, StatusCode: 201, CreatedAt: time.Now()})
+
+		store.DeleteReceipt(deletedId)
+		for _, id := range []string{deletedId, liveId} {
+			if err := store.PurgeReceipt(id); err != nil {
+				t.Errorf("PurgeReceipt(%q) got an error: %q", id, err.Error())
+			}
+			if err := store.PurgeReceipt(id); !errors.Is(err, ErrReceiptNotFound) {
+				t.Errorf("PurgeReceipt should return ErrReceiptNotFound once purged, got %v", err)
+			}
+		}
+
+		if receipts, _ := store.ListReceipts(); len(receipts) != 0 {
+			t.Errorf("ListReceipts after purging = got %d receipts, wanted none", len(receipts))
+		}
+		if _, err := store.GetIdempotencyKey("k"); !errors.Is(err, ErrIdempotencyKeyNotFound) {
+			t.Errorf("PurgeReceipt should remove the idempotency keys of the receipt, got %v", err)
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		if revisions, err := store.ListRevisions("555"); err != nil || len(revisions) != 0 {
+			t.Errorf("ListRevisions for a receipt without history = got %v %v, wanted none", revisions, err)
+		}
+
+		receipt := newTestReceipt()
+		receipt.ID = "555"
+		points := 20
+		first := Revision{
+			ReceiptID:   "555",
+			Action:      RevisionCreate,
+			Actor:       "support",
+			CreatedAt:   time.Unix(1700000000, 0),
+			Changes:     DiffReceipts(nil, &receipt),
+			PointsAfter: &points,
+			Receipt:     receipt,
+		}
+		second := Revision{ReceiptID: "555", Action: RevisionDelete, Actor: "anonymous", CreatedAt: time.Unix(1700000100, 0),
+			Changes: DiffReceipts(&receipt, nil), PointsBefore: &points, Receipt: receipt}
+
+		for n, revision := range []Revision{first, second} {
+			added, err := store.AddRevision(revision)
+			if err != nil {
+				t.Fatalf("AddRevision got an error: %q", err.Error())
+			}
+			if added.Number != n+1 {
+				t.Errorf("AddRevision = got number %d, wanted %d", added.Number, n+1)
+			}
+		}
+
+		revisions, _ := store.ListRevisions("555")
+		first.Number, second.Number = 1, 2
+		if !reflect.DeepEqual(revisions, []Revision{first, second}) {
+			t.Errorf("ListRevisions = got %+v, wanted %+v", revisions, []Revision{first, second})
+		}
+
+		found, err := store.GetRevision("555", 2)
+		if err != nil || !reflect.DeepEqual(*found, second) {
+			t.Errorf("GetRevision = got %+v %v, wanted %+v", found, err, second)
+		}
+		if _, err := store.GetRevision("555", 3); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("GetRevision should return ErrRevisionNotFound for a missing number, got %v", err)
+		}
+	})
+}
+
+func TestAddReceipts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		existingId, _ := store.AddReceipt(newTestReceipt())
+
+		first, second := newTestReceipt(), newTestReceipt()
+		first.ID = NewReceiptID()
+		second.DuplicateOf = first.ID
+
+		ids, err := store.AddReceipts([]Receipt{first, second})
+		if err != nil {
+			t.Fatalf("AddReceipts got an error: %q", err.Error())
+		}
+		if len(ids) != 2 || ids[0] != first.ID || ids[1] == "" {
+			t.Errorf("AddReceipts = got ids %v, wanted %q and a new id", ids, first.ID)
+		}
+		if found, _ := store.GetReceipt(ids[1]); found == nil || found.DuplicateOf != first.ID {
+			t.Errorf("GetReceipt = got %+v, wanted a duplicate of %q", found, first.ID)
+		}
+
+		// Nothing is stored if any id is taken
+		clash := newTestReceipt()
+		clash.ID = existingId
+		if _, err := store.AddReceipts([]Receipt{newTestReceipt(), clash}); !errors.Is(err, ErrDuplicateReceiptID) {
+			t.Errorf("AddReceipts with a taken id should return ErrDuplicateReceiptID, got %v", err)
+		}
+		if receipts, _ := store.ListReceipts(); len(receipts) != 3 {
+			t.Errorf("ListReceipts after a failed AddReceipts = got %d receipts, wanted 3", len(receipts))
+		}
+	})
+}
+
+func TestSaveScore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store ReceiptStore) {
+		scoredId, _ := store.AddReceipt(newTestReceipt())
+		failedId, _ := store.AddReceipt(newTestReceipt())
+		pendingId, _ := store.AddReceipt(newTestReceipt())
+
+		if found, _ := store.GetReceipt(scoredId); found.Status != StatusPending || found.Points != nil {
+			t.Errorf("GetReceipt of a new receipt = got status %q and points %v, wanted pending with no points", found.Status, found.Points)
+		}
+
+		points := 28
+		breakdown := json.RawMessage(`[{"name":"item_pairs","points":28}]`)
+		score := ReceiptScore{Status: StatusScored, Points: &points, Breakdown: breakdown, RulesetVersion: "v2"}
+		if err := store.SaveScore(scoredId, score); err != nil {
+			t.Fatalf("SaveScore got an error: %q", err.Error())
+		}
+		store.SaveScore(failedId, ReceiptScore{Status: StatusFailed, Error: "no rules"})
+
+		found, _ := store.GetReceipt(scoredId)
+		if found.Status != StatusScored || found.Points == nil || *found.Points != points {
+			t.Errorf("GetReceipt after SaveScore = got status %q and points %v, wanted %q and %d", found.Status, found.Points, StatusScored, points)
+		}
+		if string(found.Breakdown) != string(breakdown) || found.RulesetVersion != "v2" {
+			t.Errorf("GetReceipt after SaveScore = got breakdown %s and version %q, wanted %s and %q", found.Breakdown, found.RulesetVersion, breakdown, "v2")
+		}
+		found, _ = store.GetReceipt(failedId)
+		if found.Status != StatusFailed || found.ScoreError != "no rules" || found.Points != nil {
+			t.Errorf("GetReceipt after a failed SaveScore = got %+v", found)
+		}
+
+		page, _ := store.QueryReceipts(ReceiptQuery{Status: StatusPending})
+		if len(page.Receipts) != 1 || page.Receipts[0].ID != pendingId {
+			t.Errorf("QueryReceipts for pending receipts = got %+v, wanted only %q", page.Receipts, pendingId)
+		}
+
+		if err := store.SaveScore("missing", ReceiptScore{Status: StatusScored}); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("SaveScore of a missing receipt should return ErrReceiptNotFound, got %v", err)
+		}
+
+		// Scores of contents that have since changed aren't saved
+		store.UpdateReceipt(pendingId, newTestReceipt())
+		if err := store.SaveScore(pendingId, score); !errors.Is(err, ErrStaleScore) {
+			t.Errorf("SaveScore of changed contents should return ErrStaleScore, got %v", err)
+		}
+		// Nor are scores only meant for pending receipts once they're scored
+		score.IfPending = true
+		if err := store.SaveScore(scoredId, score); !errors.Is(err, ErrStaleScore) {
+			t.Errorf("SaveScore of a scored receipt while pending should return ErrStaleScore, got %v", err)
+		}
+		score.ContentVersion = 1
+		if err := store.SaveScore(pendingId, score); err != nil {
+			t.Errorf("SaveScore of the latest contents got an error: %q", err.Error())
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A UTC offset such as +05:30, -0700 or +09
+var utcOffsetRegex = regexp.MustCompile(`^([+-])(\d{2}):?(\d{2})?$`)
+
+// Look up a time zone given as an IANA name such as "America/Chicago", or as
+// a UTC offset such as "-05:00". Offsets are named in the +HH:MM form, so the
+// same offset written two ways is the same zone.
+func LoadTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("the time zone is empty")
+	}
+	if name == "Z" || strings.EqualFold(name, "UTC") {
+		return time.UTC, nil
+	}
+
+	if match := utcOffsetRegex.FindStringSubmatch(name); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("%q is not a valid UTC offset", name)
+		}
+
+		offset := (hours*60 + minutes) * 60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(fmt.Sprintf("%s%02d:%02d", match[1], hours, minutes), offset), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return location, nil
+}
+
+// The moment of the purchase, reading the purchase date and time as the
+// local time of the given zone
+func (r Receipt) PurchasedAt(location *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", r.PurchaseDate+" "+r.PurchaseTime, location)
+}
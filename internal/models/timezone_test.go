@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	testTable := []struct {
+		name     string
+		expected string
+		offset   int
+	}{
+		{"America/Chicago", "America/Chicago", 0},
+		{"UTC", "UTC", 0},
+		{"Z", "UTC", 0},
+		{"-05:00", "-05:00", -5 * 60 * 60},
+		{"+0530", "+05:30", (5*60 + 30) * 60},
+		{"+09", "+09:00", 9 * 60 * 60},
+	}
+
+	for _, test := range testTable {
+		location, err := LoadTimeZone(test.name)
+		if err != nil {
+			t.Errorf("LoadTimeZone(%q) got an error: %q", test.name, err.Error())
+			continue
+		}
+		if location.String() != test.expected {
+			t.Errorf("LoadTimeZone(%q) = got %q, wanted %q", test.name, location.String(), test.expected)
+		}
+		if test.offset != 0 {
+			if _, offset := time.Date(2023, 1, 1, 0, 0, 0, 0, location).Zone(); offset != test.offset {
+				t.Errorf("LoadTimeZone(%q) = got an offset of %d, wanted %d", test.name, offset, test.offset)
+			}
+		}
+	}
+
+	for _, name := range []string{"", "Mars/Olympus", "+25:00", "-05:75", "5"} {
+		if _, err := LoadTimeZone(name); err == nil {
+			t.Errorf("LoadTimeZone(%q) = got no error, wanted one", name)
+		}
+	}
+}
+
+func TestPurchasedAt(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	if err != nil {
+		t.Fatalf("LoadTimeZone got an error: %q", err.Error())
+	}
+
+	receipt := Receipt{PurchaseDate: "2023-06-15", PurchaseTime: "15:40"}
+	purchased, err := receipt.PurchasedAt(chicago)
+	if err != nil {
+		t.Fatalf("PurchasedAt got an error: %q", err.Error())
+	}
+
+	expected := time.Date(2023, 6, 15, 20, 40, 0, 0, time.UTC)
+	if !purchased.Equal(expected) {
+		t.Errorf("PurchasedAt(America/Chicago) = got %v, wanted %v", purchased.UTC(), expected)
+	}
+}
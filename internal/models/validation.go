@@ -0,0 +1,269 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The reasons a field can fail validation
+const (
+	CodeMalformedJSON = "malformed_json"
+	CodeRequired      = "required"
+	CodeType          = "type"
+	CodePattern       = "pattern"
+	CodeInvalid       = "invalid"
+	CodeEmpty         = "empty"
+	CodeMismatch      = "mismatch"
+	CodeDuplicate     = "duplicate"
+)
+
+var (
+	// Letters, numbers, spaces and the punctuation found in store names
+	retailerRegex = regexp.MustCompile(`^[\w\s\-&'.]+$`)
+	// Letters, numbers, spaces and the punctuation found on item lines
+	descriptionRegex = regexp.MustCompile(`^[\w\s\-&'.]+$`)
+	// Dollars and exactly two decimal places
+	moneyPatternRegex = regexp.MustCompile(`^\d+\.\d{2}$`)
+	// 24 hour time as HH:MM, the form purchase times are stored in
+	timeRegex = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+)
+
+// A problem with one field of a receipt
+type FieldError struct {
+	// Path of the field, e.g. "total" or "items[1].price"
+	Field string `json:"field" example:"items[1].price"`
+	// A machine readable reason, e.g. "required" or "pattern"
+	Code string `json:"code" example:"pattern"`
+	// A human readable explanation
+	Message string `json:"message" example:"must be a dollar amount with two decimal places, e.g. 6.49"`
+}
+
+// Every problem found with a receipt
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldError := range e.Errors {
+		if fieldError.Field == "" {
+			messages = append(messages, fieldError.Message)
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Collects field errors, remembering which fields have already failed
+type fieldErrors struct {
+	errors []FieldError
+	failed map[string]bool
+}
+
+func (f *fieldErrors) add(field string, code string, message string) {
+	if f.failed == nil {
+		f.failed = map[string]bool{}
+	}
+	f.errors = append(f.errors, FieldError{Field: field, Code: code, Message: message})
+	f.failed[field] = true
+}
+
+// The collected errors as a ValidationError, or nil if there were none
+func (f *fieldErrors) err() error {
+	if len(f.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: f.errors}
+}
+
+// The shape of a receipt as it is sent to us, before anything is checked
+type rawReceipt struct {
+	Retailer     json.RawMessage `json:"retailer"`
+	PurchaseDate json.RawMessage `json:"purchaseDate"`
+	PurchaseTime json.RawMessage `json:"purchaseTime"`
+	TimeZone     json.RawMessage `json:"timeZone"`
+	Total        json.RawMessage `json:"total"`
+	Items        json.RawMessage `json:"items"`
+}
+
+type rawItem struct {
+	ShortDescription json.RawMessage `json:"shortDescription"`
+	Price            json.RawMessage `json:"price"`
+}
+
+// Read a receipt from a JSON body, checking every field. If anything is wrong
+// a *ValidationError listing every bad field is returned.
+func ParseReceipt(data []byte) (Receipt, error) {
+	var errs fieldErrors
+	var raw rawReceipt
+	var receipt Receipt
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		errs.add("", CodeMalformedJSON, "the body must be a JSON object: "+err.Error())
+		return receipt, errs.err()
+	}
+
+	receipt.Retailer, _ = stringField(&errs, "retailer", raw.Retailer)
+	receipt.PurchaseDate, _ = stringField(&errs, "purchaseDate", raw.PurchaseDate)
+	receipt.PurchaseTime, _ = stringField(&errs, "purchaseTime", raw.PurchaseTime)
+	if !isMissing(raw.TimeZone) {
+		receipt.TimeZone, _ = stringField(&errs, "timeZone", raw.TimeZone)
+	}
+	receipt.Total = moneyField(&errs, "total", raw.Total)
+	normalizePurchase(&receipt)
+
+	if isMissing(raw.Items) {
+		errs.add("items", CodeRequired, "is required")
+	} else {
+		var items []rawItem
+		if err := json.Unmarshal(raw.Items, &items); err != nil {
+			errs.add("items", CodeType, "must be a list of items")
+		}
+
+		receipt.Items = make([]Item, 0, len(items))
+		for i, item := range items {
+			prefix := fmt.Sprintf("items[%d].", i)
+			description, _ := stringField(&errs, prefix+"shortDescription", item.ShortDescription)
+			price := moneyField(&errs, prefix+"price", item.Price)
+			receipt.Items = append(receipt.Items, Item{ShortDescription: description, Price: price})
+		}
+	}
+
+	validateReceipt(&errs, receipt)
+	if len(errs.errors) == 0 {
+		reconcileReceipt(&errs, &receipt)
+	}
+	return receipt, errs.err()
+}
+
+// Checks that a receipt has values we can work with, reading its purchase
+// date and time in any of the accepted layouts. If it doesn't the error is a
+// *ValidationError listing every bad field.
+func CheckReceipt(receipt Receipt) (bool, error) {
+	var errs fieldErrors
+	normalizePurchase(&receipt)
+	validateReceipt(&errs, receipt)
+	if len(errs.errors) == 0 {
+		reconcileReceipt(&errs, &receipt)
+	}
+
+	if err := errs.err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Check the values of the receipt's fields, skipping any field that has
+// already failed
+func validateReceipt(errs *fieldErrors, receipt Receipt) {
+	check := func(field string, ok bool, code string, message string) {
+		if !errs.failed[field] && !ok {
+			errs.add(field, code, message)
+		}
+	}
+
+	check("retailer", strings.TrimSpace(receipt.Retailer) != "", CodeRequired, "is required")
+	check("retailer", retailerRegex.MatchString(receipt.Retailer), CodePattern,
+		"may only contain letters, numbers, spaces and - & ' .")
+
+	_, err := time.Parse(DateLayout, receipt.PurchaseDate)
+	check("purchaseDate", err == nil, CodeInvalid,
+		"must be a date such as 2023-06-15, 06/15/2023 or 2023-06-15T15:40:00Z")
+
+	check("purchaseTime", timeRegex.MatchString(receipt.PurchaseTime), CodePattern,
+		"must be a time such as 15:40, 15:40:00, 3:40 PM or 2023-06-15T15:40:00Z")
+
+	if receipt.TimeZone != "" {
+		_, err := LoadTimeZone(receipt.TimeZone)
+		check("timeZone", err == nil, CodeInvalid,
+			"must be an IANA time zone such as America/Chicago or a UTC offset such as -05:00")
+	}
+
+	check("total", receipt.Total >= 0, CodeInvalid, "can't be negative")
+
+	check("items", len(receipt.Items) > 0, CodeEmpty, "must have at least one item")
+	for i, item := range receipt.Items {
+		field := fmt.Sprintf("items[%d].shortDescription", i)
+		check(field, strings.TrimSpace(item.ShortDescription) != "", CodeRequired, "is required")
+		check(field, descriptionRegex.MatchString(item.ShortDescription), CodePattern,
+			"may only contain letters, numbers, spaces and - & ' .")
+
+		check(fmt.Sprintf("items[%d].price", i), item.Price >= 0, CodeInvalid, "can't be negative")
+	}
+}
+
+// Bring the purchase date and time into their stored forms, keeping what was
+// sent when it differs. A timestamp with an offset gives the receipt its zone
+// when it has none. Anything that can't be read is left for validateReceipt
+// to report.
+func normalizePurchase(receipt *Receipt) {
+	var location *time.Location
+	if receipt.TimeZone != "" {
+		location, _ = LoadTimeZone(receipt.TimeZone)
+	}
+
+	// The time zone of a timestamp, which the other field is then moved into
+	useZone := func(zone string) {
+		if zone != "" && location == nil {
+			receipt.TimeZone = zone
+			location, _ = LoadTimeZone(zone)
+		}
+	}
+
+	if date, zone, err := NormalizeDate(receipt.PurchaseDate, location); err == nil {
+		if date != receipt.PurchaseDate {
+			receipt.RawPurchaseDate, receipt.PurchaseDate = receipt.PurchaseDate, date
+		}
+		useZone(zone)
+	}
+	if clock, zone, err := NormalizeTime(receipt.PurchaseTime, location); err == nil {
+		if clock != receipt.PurchaseTime {
+			receipt.RawPurchaseTime, receipt.PurchaseTime = receipt.PurchaseTime, clock
+		}
+		useZone(zone)
+	}
+}
+
+// Whether a field was left out or sent as null
+func isMissing(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// Read a required string field
+func stringField(errs *fieldErrors, field string, raw json.RawMessage) (string, bool) {
+	if isMissing(raw) {
+		errs.add(field, CodeRequired, "is required")
+		return "", false
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		errs.add(field, CodeType, "must be a string")
+		return "", false
+	}
+	return value, true
+}
+
+// Read a required money field, which has to be a string like "6.49"
+func moneyField(errs *fieldErrors, field string, raw json.RawMessage) Money {
+	value, ok := stringField(errs, field, raw)
+	if !ok {
+		return 0
+	}
+
+	if !moneyPatternRegex.MatchString(value) {
+		errs.add(field, CodePattern, "must be a dollar amount with two decimal places, e.g. 6.49")
+		return 0
+	}
+
+	money, err := ParseMoney(value)
+	if err != nil {
+		errs.add(field, CodeInvalid, err.Error())
+		return 0
+	}
+	return money
+}
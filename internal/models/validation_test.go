@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const validReceiptJSON = `{
+	"retailer": "M&M Corner Market",
+	"purchaseDate": "2022-03-20",
+	"purchaseTime": "14:33",
+	"items": [
+		{"shortDescription": "Gatorade", "price": "2.25"},
+		{"shortDescription": "Mike & Ikes", "price": "1.15"}
+	],
+	"total": "3.40"
+}`
+
+func TestParseReceipt(t *testing.T) {
+	receipt, err := ParseReceipt([]byte(validReceiptJSON))
+	if err != nil {
+		t.Fatalf("ParseReceipt got an error: %q", err.Error())
+	}
+
+	expected := Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Mike & Ikes", Price: MustParseMoney("1.15")},
+		},
+		Total: MustParseMoney("3.40"),
+	}
+	if !reflect.DeepEqual(receipt, expected) {
+		t.Errorf("ParseReceipt = got %+v, wanted %+v", receipt, expected)
+	}
+}
+
+func TestParseReceiptDateTimeLayouts(t *testing.T) {
+	testTable := []struct {
+		date     string
+		clock    string
+		timeZone string
+		expected Receipt
+	}{
+		{"2023-06-15", "15:40", "", Receipt{PurchaseDate: "2023-06-15", PurchaseTime: "15:40"}},
+		{"06/15/2023", "3:40 PM", "", Receipt{
+			PurchaseDate: "2023-06-15", PurchaseTime: "15:40", RawPurchaseDate: "06/15/2023", RawPurchaseTime: "3:40 PM",
+		}},
+		{"2023-06-15T15:40:00Z", "15:40:00", "", Receipt{
+			PurchaseDate: "2023-06-15", PurchaseTime: "15:40", TimeZone: "UTC",
+			RawPurchaseDate: "2023-06-15T15:40:00Z", RawPurchaseTime: "15:40:00",
+		}},
+		// The date's offset becomes the zone the time is moved into
+		{"2023-06-15T15:40:00-05:00", "2023-06-15T20:40:00Z", "", Receipt{
+			PurchaseDate: "2023-06-15", PurchaseTime: "15:40", TimeZone: "-05:00",
+			RawPurchaseDate: "2023-06-15T15:40:00-05:00", RawPurchaseTime: "2023-06-15T20:40:00Z",
+		}},
+		{"2023-06-16", "2023-06-16T01:40:00Z", "America/Chicago", Receipt{
+			PurchaseDate: "2023-06-16", PurchaseTime: "20:40", TimeZone: "America/Chicago",
+			RawPurchaseTime: "2023-06-16T01:40:00Z",
+		}},
+	}
+
+	for _, test := range testTable {
+		body := map[string]any{
+			"retailer": "Target", "purchaseDate": test.date, "purchaseTime": test.clock, "total": "1.00",
+			"items": []map[string]string{{"shortDescription": "Pez", "price": "1.00"}},
+		}
+		if test.timeZone != "" {
+			body["timeZone"] = test.timeZone
+		}
+		data, _ := json.Marshal(body)
+
+		receipt, err := ParseReceipt(data)
+		if err != nil {
+			t.Errorf("ParseReceipt(%s, %s) got an error: %q", test.date, test.clock, err.Error())
+			continue
+		}
+		got := Receipt{
+			PurchaseDate: receipt.PurchaseDate, PurchaseTime: receipt.PurchaseTime, TimeZone: receipt.TimeZone,
+			RawPurchaseDate: receipt.RawPurchaseDate, RawPurchaseTime: receipt.RawPurchaseTime,
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("ParseReceipt(%s, %s) = got %+v, wanted %+v", test.date, test.clock, got, test.expected)
+		}
+	}
+}
+
+// The field and code of every error, to compare against expectations
+func fieldCodes(err error) []string {
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) {
+		return nil
+	}
+
+	var codes []string
+	for _, fieldError := range validationError.Errors {
+		codes = append(codes, fieldError.Field+":"+fieldError.Code)
+	}
+	return codes
+}
+
+func TestParseReceiptFieldErrors(t *testing.T) {
+	testTable := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"malformed", `{"retailer": `, []string{":malformed_json"}},
+		{"not an object", `["Target"]`, []string{":malformed_json"}},
+		{"empty object", `{}`, []string{
+			"retailer:required", "purchaseDate:required", "purchaseTime:required", "total:required", "items:required",
+		}},
+		{"wrong types", `{"retailer": 5, "purchaseDate": true, "purchaseTime": null, "total": 6.49, "items": "none"}`, []string{
+			"retailer:type", "purchaseDate:type", "purchaseTime:required", "total:type", "items:type",
+		}},
+		{"bad values", `{
+			"retailer": "Target!",
+			"purchaseDate": "2022-02-30",
+			"purchaseTime": "3:40 XM",
+			"total": "6.5",
+			"items": []
+		}`, []string{
+			"total:pattern", "retailer:pattern", "purchaseDate:invalid", "purchaseTime:pattern", "items:empty",
+		}},
+		{"bad items", `{
+			"retailer": "Target",
+			"purchaseDate": "2022-01-01",
+			"purchaseTime": "13:01",
+			"total": "6.49",
+			"items": [
+				{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+				{"shortDescription": "Emils <Cheese> Pizza", "price": "12.255"},
+				{"price": "1.00"},
+				{"shortDescription": "   ", "price": "-1.00"}
+			]
+		}`, []string{
+			"items[1].price:pattern", "items[2].shortDescription:required", "items[3].price:pattern",
+			"items[1].shortDescription:pattern", "items[3].shortDescription:required",
+		}},
+		{"blank retailer", `{"retailer": "  ", "purchaseDate": "2022-01-01", "purchaseTime": "24:00", "total": "1.00", "items": [{"shortDescription": "Pez", "price": "1.00"}]}`, []string{
+			"retailer:required", "purchaseTime:pattern",
+		}},
+		{"bad time zone", `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "timeZone": "Mars/Olympus", "total": "1.00", "items": [{"shortDescription": "Pez", "price": "1.00"}]}`, []string{
+			"timeZone:invalid",
+		}},
+		{"time zone type", `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "timeZone": -5, "total": "1.00", "items": [{"shortDescription": "Pez", "price": "1.00"}]}`, []string{
+			"timeZone:type",
+		}},
+	}
+
+	for _, test := range testTable {
+		_, err := ParseReceipt([]byte(test.body))
+		if codes := fieldCodes(err); !reflect.DeepEqual(codes, test.expected) {
+			t.Errorf("ParseReceipt(%s) = got errors %v, wanted %v", test.name, codes, test.expected)
+		}
+	}
+}
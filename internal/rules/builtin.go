@@ -0,0 +1,339 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The names of the built in rule types
+const (
+	RetailerAlphanumericRuleType = "retailer_alphanumeric"
+	RoundDollarTotalRuleType     = "round_dollar_total"
+	TotalMultipleRuleType        = "total_multiple"
+	ItemPairsRuleType            = "item_pairs"
+	ItemDescriptionRuleType      = "item_description"
+	OddPurchaseDayRuleType       = "odd_purchase_day"
+	PurchaseTimeRuleType         = "purchase_time"
+)
+
+// The built in rules in the order they are evaluated by default
+var DefaultRuleTypes = []string{
+	RetailerAlphanumericRuleType,
+	RoundDollarTotalRuleType,
+	TotalMultipleRuleType,
+	ItemPairsRuleType,
+	ItemDescriptionRuleType,
+	OddPurchaseDayRuleType,
+	PurchaseTimeRuleType,
+}
+
+// The registry of the built in rule types
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(RetailerAlphanumericRuleType, func() Rule {
+		return &RetailerAlphanumericRule{PointsPerCharacter: 1}
+	})
+	registry.Register(RoundDollarTotalRuleType, func() Rule {
+		return &RoundDollarTotalRule{Points: 50}
+	})
+	registry.Register(TotalMultipleRuleType, func() Rule {
+		return &TotalMultipleRule{Multiple: models.MustParseMoney("0.25"), Points: 25}
+	})
+	registry.Register(ItemPairsRuleType, func() Rule {
+		return &ItemPairsRule{GroupSize: 2, PointsPerGroup: 5}
+	})
+	registry.Register(ItemDescriptionRuleType, func() Rule {
+		return &ItemDescriptionRule{LengthMultiple: 3, PriceMultiplier: 0.2}
+	})
+	registry.Register(OddPurchaseDayRuleType, func() Rule {
+		return &OddPurchaseDayRule{Points: 6}
+	})
+	registry.Register(PurchaseTimeRuleType, func() Rule {
+		return &PurchaseTimeRule{After: "14:00", Before: "16:00", Points: 10}
+	})
+	registry.Register(BonusRuleType, func() Rule {
+		return &BonusRule{}
+	})
+	registry.Register(MultiplierRuleType, func() Rule {
+		return &MultiplierRule{Factor: 1}
+	})
+	registry.Register(ExpressionRuleType, func() Rule {
+		return &ExpressionRule{}
+	})
+	return registry
+}
+
+// Build the standard ruleset out of the built in rules
+func DefaultRuleset() *Ruleset {
+	ruleset, err := DefaultRegistry.Build(DefaultRuleTypes...)
+	if err != nil {
+		panic(err)
+	}
+	ruleset.SetVersion(DefaultRulesetVersion)
+	return ruleset
+}
+
+// One point for every alphanumeric character in the retailer name.
+type RetailerAlphanumericRule struct {
+	PointsPerCharacter int `yaml:"pointsPerCharacter"`
+}
+
+func (r *RetailerAlphanumericRule) Name() string {
+	return RetailerAlphanumericRuleType
+}
+
+func (r *RetailerAlphanumericRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	length := alphanumericLength(rec.Retailer)
+	return RuleResult{
+		Points: length * r.PointsPerCharacter,
+		Reason: fmt.Sprintf("Retailer name has %d alphanumeric characters", length),
+	}, nil
+}
+
+// 50 points if the total is a round dollar amount with no cents.
+type RoundDollarTotalRule struct {
+	Points int `yaml:"points"`
+}
+
+func (r *RoundDollarTotalRule) Name() string {
+	return RoundDollarTotalRuleType
+}
+
+func (r *RoundDollarTotalRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	if !isTotalRound(rec.Total) {
+		return RuleResult{Reason: "Total is not a round dollar amount"}, nil
+	}
+	return RuleResult{Points: r.Points, Reason: "Total is a round dollar amount"}, nil
+}
+
+// 25 points if the total is a multiple of 0.25
+type TotalMultipleRule struct {
+	Multiple models.Money `yaml:"multiple"`
+	Points   int          `yaml:"points"`
+}
+
+func (r *TotalMultipleRule) Name() string {
+	return TotalMultipleRuleType
+}
+
+func (r *TotalMultipleRule) Validate() error {
+	if r.Multiple.Cents() <= 0 {
+		return errors.New("multiple must be greater than 0")
+	}
+	return nil
+}
+
+func (r *TotalMultipleRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	if !isTotalAMultiplier(rec.Total, r.Multiple) {
+		return RuleResult{Reason: fmt.Sprintf("Total is not a multiple of %s", r.Multiple)}, nil
+	}
+	return RuleResult{Points: r.Points, Reason: fmt.Sprintf("Total is multiple of %s", r.Multiple)}, nil
+}
+
+// 5 points for every two items on the receipt.
+type ItemPairsRule struct {
+	GroupSize      int `yaml:"groupSize"`
+	PointsPerGroup int `yaml:"pointsPerGroup"`
+}
+
+func (r *ItemPairsRule) Name() string {
+	return ItemPairsRuleType
+}
+
+func (r *ItemPairsRule) Validate() error {
+	if r.GroupSize <= 0 {
+		return errors.New("groupSize must be greater than 0")
+	}
+	return nil
+}
+
+func (r *ItemPairsRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	groups := itemsLengthGrouping(rec.Items, r.GroupSize)
+	return RuleResult{
+		Points: groups * r.PointsPerGroup,
+		Reason: fmt.Sprintf("%d items (%d groups of %d @ %d points each)", len(rec.Items), groups, r.GroupSize, r.PointsPerGroup),
+	}, nil
+}
+
+// If the trimmed length of the item description is a multiple of 3,
+// multiply the price by 0.2 and round up to the nearest integer.
+// The result is the number of points earned.
+type ItemDescriptionRule struct {
+	LengthMultiple  int     `yaml:"lengthMultiple"`
+	PriceMultiplier float64 `yaml:"priceMultiplier"`
+}
+
+func (r *ItemDescriptionRule) Name() string {
+	return ItemDescriptionRuleType
+}
+
+func (r *ItemDescriptionRule) Validate() error {
+	if r.LengthMultiple <= 0 {
+		return errors.New("lengthMultiple must be greater than 0")
+	}
+	if r.PriceMultiplier < 0 {
+		return errors.New("priceMultiplier can't be negative")
+	}
+	if _, err := multiplierHundredths(r.PriceMultiplier); err != nil {
+		return fmt.Errorf("priceMultiplier %w", err)
+	}
+	return nil
+}
+
+func (r *ItemDescriptionRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *ItemDescriptionRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	var result RuleResult
+
+	multiplier, err := multiplierHundredths(r.PriceMultiplier)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	var values []*big.Rat
+	for _, item := range rec.Items {
+		descrLength, value := itemDescriptionPricePoints(item, r.LengthMultiple, multiplier)
+		if value > 0 {
+			result.Items = append(result.Items, PointRuleItem{
+				Price:             item.Price,
+				Description:       item.ShortDescription,
+				DescriptionLength: descrLength,
+				Value:             value.Float(),
+				Reason:            fmt.Sprintf("%q is %d characters (a multiple of %d), item price of %q * %g = %.2f", item.ShortDescription, descrLength, r.LengthMultiple, item.Price.String(), r.PriceMultiplier, value.Float()),
+			})
+			values = append(values, value.rat())
+		}
+	}
+
+	points, rounding := ctx.Rounding.roundItems(result.Items, values)
+	result.Points = points
+	result.Reason = fmt.Sprintf("%d item descriptions have a length that is a multiple of %d", len(result.Items), r.LengthMultiple) + rounding
+	return result, nil
+}
+
+// 6 points if the day in the purchase date is odd.
+type OddPurchaseDayRule struct {
+	Points int `yaml:"points"`
+	// The zone the day is read in, the receipt's own zone when empty
+	TimeZone string `yaml:"timeZone"`
+}
+
+func (r *OddPurchaseDayRule) Name() string {
+	return OddPurchaseDayRuleType
+}
+
+func (r *OddPurchaseDayRule) Validate() error {
+	if _, err := ruleZone(r.TimeZone); err != nil {
+		return fmt.Errorf("timeZone: %w", err)
+	}
+	return nil
+}
+
+func (r *OddPurchaseDayRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *OddPurchaseDayRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	zone, err := ruleZone(r.TimeZone)
+	if err != nil {
+		return RuleResult{}, err
+	}
+	local, err := purchaseIn(rec, ctx.Location, zone)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	where := ""
+	if local.from != nil {
+		where = fmt.Sprintf(" (%s in %s)", local.date, local.location)
+	}
+	if !oddPurchaseDate(local.date) {
+		return RuleResult{Reason: "Purchase day is even" + where}, nil
+	}
+	return RuleResult{Points: r.Points, Reason: "Purchase day is odd" + where}, nil
+}
+
+// 10 points if the time of purchase is after 2:00pm and before 4:00pm.
+type PurchaseTimeRule struct {
+	// The window in 24 hour time, both ends are exclusive
+	After  string `yaml:"after"`
+	Before string `yaml:"before"`
+	Points int    `yaml:"points"`
+	// The zone the window is in, the receipt's own zone when empty
+	TimeZone string `yaml:"timeZone"`
+	// Windows for purchases in particular zones, in place of after and before
+	Zones map[string]ClockWindow `yaml:"zones"`
+
+	windows map[string]ClockWindow
+}
+
+func (r *PurchaseTimeRule) Name() string {
+	return PurchaseTimeRuleType
+}
+
+// Checks the windows and keys them by zone, so they are only loaded once
+func (r *PurchaseTimeRule) Validate() error {
+	if _, _, err := (ClockWindow{After: r.After, Before: r.Before}).minutes(); err != nil {
+		return err
+	}
+	if _, err := ruleZone(r.TimeZone); err != nil {
+		return fmt.Errorf("timeZone: %w", err)
+	}
+
+	windows, err := zoneWindows(r.Zones)
+	if err != nil {
+		return err
+	}
+	r.windows = windows
+	return nil
+}
+
+func (r *PurchaseTimeRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *PurchaseTimeRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	zone, err := ruleZone(r.TimeZone)
+	if err != nil {
+		return RuleResult{}, err
+	}
+	local, err := purchaseIn(rec, ctx.Location, zone)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	windows := r.windows
+	if windows == nil && len(r.Zones) > 0 {
+		// Built in code rather than loaded from a ruleset file
+		if windows, err = zoneWindows(r.Zones); err != nil {
+			return RuleResult{}, err
+		}
+	}
+	window := ClockWindow{After: r.After, Before: r.Before}
+	if local.location != nil {
+		if zoneWindow, ok := windows[local.location.String()]; ok {
+			window = zoneWindow
+		}
+	}
+
+	after, before, err := window.minutes()
+	if err != nil {
+		return RuleResult{}, err
+	}
+	checkedTime, err := checkPurchaseTime(local.clock, after, before)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	if !checkedTime {
+		return RuleResult{Reason: fmt.Sprintf("%s is not between %s and %s", local.describeClock(rec), window.After, window.Before)}, nil
+	}
+	return RuleResult{Points: r.Points, Reason: fmt.Sprintf("%s is between %s and %s", local.describeClock(rec), window.After, window.Before)}, nil
+}
@@ -0,0 +1,345 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The layout of a ruleset file. JSON files use the same keys.
+//
+//	version: "2024-06"
+//	maxPoints: 500
+//	rounding:
+//	  mode: half_even
+//	  scope: total
+//	timeZone: America/New_York
+//	retailers:
+//	  - name: Target
+//	    timeZone: America/Chicago
+//	rules:
+//	  - type: item_pairs
+//	    params:
+//	      groupSize: 2
+//	      pointsPerGroup: 5
+//	  - type: purchase_time
+//	    enabled: false
+//	  - type: item_description
+//	    maxItemPoints: 50
+//	  - type: multiplier
+//	    name: target_double_points
+//	    validFrom: "2024-11-20"
+//	    validUntil: "2024-11-30"
+//	    params:
+//	      factor: 2
+//	      retailers: [Target]
+type rulesetConfig struct {
+	// Recorded with every score, a hash of the document when left out
+	Version string `yaml:"version"`
+	// The least and most points a receipt can be awarded
+	MinPoints *int `yaml:"minPoints"`
+	MaxPoints *int `yaml:"maxPoints"`
+	// How fractional points are rounded, decoded into a RoundingPolicy
+	Rounding yaml.Node `yaml:"rounding"`
+	// The time zone of receipts that don't give one and whose retailer
+	// doesn't have one
+	TimeZone string `yaml:"timeZone"`
+	// Profiles of retailers, each a retailerConfig
+	Retailers []yaml.Node `yaml:"retailers"`
+	Rules     []yaml.Node `yaml:"rules"`
+}
+
+// A retailer profile of a ruleset file
+type retailerConfig struct {
+	// The retailer name, matched ignoring letter case
+	Name string `yaml:"name"`
+	// The time zone of the retailer's receipts that don't give their own
+	TimeZone string `yaml:"timeZone"`
+}
+
+// A single rule entry of a ruleset file
+type ruleConfig struct {
+	// The registered rule type
+	Type string `yaml:"type"`
+	// Optional name, needed when the same type is used more than once
+	Name string `yaml:"name"`
+	// Rules are enabled unless this is set to false
+	Enabled *bool `yaml:"enabled"`
+	// The first and last purchase dates the rule awards points for, as
+	// YYYY-MM-DD. Either can be left out.
+	ValidFrom  string `yaml:"validFrom"`
+	ValidUntil string `yaml:"validUntil"`
+	// The zone the window's dates are in, receipts from other zones are
+	// moved into it. Without it the receipt's own zone is used.
+	TimeZone string `yaml:"timeZone"`
+	// The least and most points the rule can award, and the most a single
+	// item can contribute to it
+	MinPoints     *int `yaml:"minPoints"`
+	MaxPoints     *int `yaml:"maxPoints"`
+	MaxItemPoints *int `yaml:"maxItemPoints"`
+	// Parameters of the rule, anything left out keeps its default value
+	Params yaml.Node `yaml:"params"`
+}
+
+// Rules that can check their parameters after they've been loaded
+type validator interface {
+	Validate() error
+}
+
+// Gives a rule a different name than its type
+type namedRule struct {
+	Rule
+	name string
+}
+
+func (r *namedRule) Name() string {
+	return r.name
+}
+
+func (r *namedRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	return evaluateRule(r.Rule, rec, ctx)
+}
+
+// Read a YAML or JSON ruleset file and build it with the default registry
+func LoadRulesetFile(path string) (*Ruleset, error) {
+	return DefaultRegistry.LoadRulesetFile(path)
+}
+
+// Read a YAML or JSON ruleset file and build it
+func (r *Registry) LoadRulesetFile(path string) (*Ruleset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.ParseRuleset(data, path)
+}
+
+// Build a ruleset out of a YAML or JSON document. Every problem that is found
+// is reported with the line it is on, source names the document in errors.
+func (r *Registry) ParseRuleset(data []byte, source string) (*Ruleset, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("%s: the ruleset is empty", source)
+	}
+
+	document := root.Content[0]
+	lineError := func(node *yaml.Node, format string, args ...any) error {
+		return fmt.Errorf("%s:%d: %s", source, node.Line, fmt.Sprintf(format, args...))
+	}
+
+	var config rulesetConfig
+	if err := decodeNode(document, &config); err != nil {
+		return nil, lineError(err.node, "%s", err.Error())
+	}
+
+	var rounding RoundingPolicy
+	if config.Rounding.Kind != 0 {
+		if err := decodeNode(&config.Rounding, &rounding); err != nil {
+			return nil, lineError(err.node, "rounding: %s", err.Error())
+		}
+	}
+
+	var errs []error
+	ruleset := NewRuleset()
+	if err := ruleset.SetRounding(rounding); err != nil {
+		errs = append(errs, lineError(&config.Rounding, "%s", err.Error()))
+	}
+
+	if err := parseTimeZones(ruleset, document, &config); err != nil {
+		errs = append(errs, lineError(err.node, "%s", err.Error()))
+	}
+
+	for i := range config.Rules {
+		ruleNode := &config.Rules[i]
+
+		rule, err := r.buildRule(ruleNode, rounding)
+		if err != nil {
+			errs = append(errs, lineError(err.node, "rule %d: %s", i+1, err.Error()))
+			continue
+		}
+		if rule == nil {
+			// The rule is disabled
+			continue
+		}
+
+		if err := ruleset.Add(rule); err != nil {
+			errs = append(errs, lineError(ruleNode, "rule %d: %s, give it a different name", i+1, err.Error()))
+		}
+	}
+
+	if err := ruleset.SetPointLimits(config.MinPoints, config.MaxPoints); err != nil {
+		errs = append(errs, lineError(document, "%s", err.Error()))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	ruleset.digest = documentDigest(document, data)
+	if config.Version != "" {
+		ruleset.SetVersion(config.Version)
+	} else {
+		ruleset.SetVersion(contentVersion(ruleset.digest))
+	}
+	return ruleset, nil
+}
+
+// Read the time zones of the ruleset and its retailer profiles
+func parseTimeZones(ruleset *Ruleset, document *yaml.Node, config *rulesetConfig) *nodeError {
+	seen := map[string]bool{}
+	retailers := map[string]string{}
+	for i := range config.Retailers {
+		node := &config.Retailers[i]
+
+		var retailer retailerConfig
+		if err := decodeNode(node, &retailer); err != nil {
+			err.err = fmt.Errorf("retailer %d: %w", i+1, err.err)
+			return err
+		}
+		if strings.TrimSpace(retailer.Name) == "" {
+			return &nodeError{node, fmt.Errorf("retailer %d: name is required", i+1)}
+		}
+		if seen[retailerKey(retailer.Name)] {
+			return &nodeError{node, fmt.Errorf("retailer %d: %q is given more than once", i+1, retailer.Name)}
+		}
+		seen[retailerKey(retailer.Name)] = true
+		if retailer.TimeZone == "" {
+			continue
+		}
+		if _, err := models.LoadTimeZone(retailer.TimeZone); err != nil {
+			return &nodeError{node, fmt.Errorf("retailer %d: timeZone: %w", i+1, err)}
+		}
+		retailers[retailer.Name] = retailer.TimeZone
+	}
+
+	if err := ruleset.SetTimeZones(config.TimeZone, retailers); err != nil {
+		return &nodeError{document, err}
+	}
+	return nil
+}
+
+// An error about a particular node of the ruleset document
+type nodeError struct {
+	node *yaml.Node
+	err  error
+}
+
+func (e *nodeError) Error() string {
+	return e.err.Error()
+}
+
+// Build the rule described by a rule entry, nil if it is disabled
+func (r *Registry) buildRule(node *yaml.Node, rounding RoundingPolicy) (Rule, *nodeError) {
+	var config ruleConfig
+	if err := decodeNode(node, &config); err != nil {
+		return nil, err
+	}
+
+	if config.Type == "" {
+		return nil, &nodeError{node, errors.New("type is required")}
+	}
+
+	rule, err := r.New(config.Type)
+	if err != nil {
+		return nil, &nodeError{node, err}
+	}
+
+	// Lay the parameters over the rule's defaults
+	paramsNode := node
+	if config.Params.Kind != 0 {
+		paramsNode = &config.Params
+		if err := decodeNode(paramsNode, rule); err != nil {
+			err.err = fmt.Errorf("%s params: %w", config.Type, err.err)
+			return nil, err
+		}
+	}
+
+	if v, ok := rule.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, &nodeError{paramsNode, fmt.Errorf("%s params: %w", config.Type, err)}
+		}
+	}
+
+	if err := validateWindow(config.ValidFrom, config.ValidUntil); err != nil {
+		return nil, &nodeError{node, err}
+	}
+	windowZone, err := ruleZone(config.TimeZone)
+	if err != nil {
+		return nil, &nodeError{node, fmt.Errorf("timeZone: %w", err)}
+	}
+	if windowZone != nil && config.ValidFrom == "" && config.ValidUntil == "" {
+		return nil, &nodeError{node, errors.New("timeZone needs validFrom or validUntil")}
+	}
+	limits := pointLimits{min: config.MinPoints, max: config.MaxPoints}
+	if err := validateRuleLimits(limits, config.MaxItemPoints); err != nil {
+		return nil, &nodeError{node, err}
+	}
+	if config.MaxItemPoints != nil && rounding.roundsTotal() {
+		// Items have no points of their own to cap when only their sum is rounded
+		return nil, &nodeError{node, errors.New("maxItemPoints can't be used when rounding scope is total")}
+	}
+
+	if config.Enabled != nil && !*config.Enabled {
+		return nil, nil
+	}
+
+	// The limits go inside the window, a rule awards nothing outside it
+	if limits.min != nil || limits.max != nil || config.MaxItemPoints != nil {
+		rule = &limitedRule{Rule: rule, limits: limits, maxItemPoints: config.MaxItemPoints}
+	}
+	if config.ValidFrom != "" || config.ValidUntil != "" {
+		rule = &windowedRule{Rule: rule, validFrom: config.ValidFrom, validUntil: config.ValidUntil, location: windowZone}
+	}
+
+	if config.Name != "" {
+		rule = &namedRule{Rule: rule, name: config.Name}
+	}
+	return rule, nil
+}
+
+// Decode a mapping node into the struct v points to, one field at a time so
+// that errors point at the value that is wrong. Every key has to be a yaml
+// field of v, so that typos in a ruleset file aren't silently ignored.
+func decodeNode(node *yaml.Node, v any) *nodeError {
+	if node.Kind != yaml.MappingNode {
+		return &nodeError{node, errors.New("expected a mapping")}
+	}
+
+	target := reflect.ValueOf(v).Elem()
+	fields := map[string]reflect.Value{}
+	for i := 0; i < target.NumField(); i++ {
+		tag := strings.Split(target.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			fields[tag] = target.Field(i)
+		}
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		field, ok := fields[key.Value]
+		if !ok {
+			return &nodeError{key, fmt.Errorf("unknown field %q", key.Value)}
+		}
+
+		if err := value.Decode(field.Addr().Interface()); err != nil {
+			// The line is already part of the error we report
+			message := err.Error()
+			var typeError *yaml.TypeError
+			if errors.As(err, &typeError) {
+				message = strings.TrimPrefix(typeError.Errors[0], fmt.Sprintf("line %d: ", value.Line))
+			}
+			return &nodeError{value, fmt.Errorf("%s: %s", key.Value, message)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+func TestLoadDefaultRulesetFile(t *testing.T) {
+	ruleset, err := LoadRulesetFile("../../rulesets/default.yaml")
+	if err != nil {
+		t.Fatalf("LoadRulesetFile got an error: %q", err.Error())
+	}
+
+	if names := ruleNames(ruleset); !reflect.DeepEqual(names, DefaultRuleTypes) {
+		t.Errorf("LoadRulesetFile = got rules %v, wanted %v", names, DefaultRuleTypes)
+	}
+
+	// The shipped file has to score exactly like the built in rules
+	for _, test := range []struct {
+		receipt  models.Receipt
+		expected int
+	}{{targetReceipt, 28}, {cornerMarketReceipt, 109}} {
+		score, _ := ruleset.Calculate(test.receipt)
+		if score.Total != test.expected {
+			t.Errorf("Calculate(%q) = got %d points, wanted %d", test.receipt.Retailer, score.Total, test.expected)
+		}
+	}
+}
+
+func TestParseRulesetParams(t *testing.T) {
+	yamlRuleset := `
+rules:
+  - type: round_dollar_total
+    params:
+      points: 100
+  - type: item_pairs
+    params:
+      groupSize: 4
+  - type: purchase_time
+    enabled: false
+`
+	jsonRuleset := `{
+	"rules": [
+		{"type": "round_dollar_total", "params": {"points": 100}},
+		{"type": "item_pairs", "params": {"groupSize": 4}},
+		{"type": "purchase_time", "enabled": false}
+	]
+}`
+
+	for format, document := range map[string]string{"yaml": yamlRuleset, "json": jsonRuleset} {
+		ruleset, err := DefaultRegistry.ParseRuleset([]byte(document), "test."+format)
+		if err != nil {
+			t.Fatalf("ParseRuleset(%s) got an error: %q", format, err.Error())
+		}
+
+		rules := ruleset.Rules()
+		if len(rules) != 2 {
+			t.Fatalf("ParseRuleset(%s) = got %d rules, wanted the disabled rule to be left out", format, len(rules))
+		}
+
+		if rule := rules[0].(*RoundDollarTotalRule); rule.Points != 100 {
+			t.Errorf("ParseRuleset(%s) round dollar points = got %d, wanted %d", format, rule.Points, 100)
+		}
+
+		// Params that are left out keep their defaults
+		if rule := rules[1].(*ItemPairsRule); rule.GroupSize != 4 || rule.PointsPerGroup != 5 {
+			t.Errorf("ParseRuleset(%s) item pairs = got %+v, wanted a group size of 4 and 5 points", format, rule)
+		}
+	}
+}
+
+func TestParseRulesetNamedRules(t *testing.T) {
+	document := `
+rules:
+  - type: total_multiple
+    params:
+      multiple: 0.25
+  - type: total_multiple
+    name: total_multiple_of_five
+    params:
+      multiple: 5
+      points: 15
+`
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(document), "named.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	expected := []string{TotalMultipleRuleType, "total_multiple_of_five"}
+	if names := ruleNames(ruleset); !reflect.DeepEqual(names, expected) {
+		t.Errorf("ParseRuleset = got rules %v, wanted %v", names, expected)
+	}
+
+	score, _ := ruleset.Calculate(cornerMarketReceipt)
+	if score.Total != 25 {
+		t.Errorf("Calculate = got %d points, wanted %d", score.Total, 25)
+	}
+}
+
+func TestParseRulesetErrors(t *testing.T) {
+	testTable := []struct {
+		name     string
+		document string
+		expected []string
+	}{
+		{"syntax", "rules:\n  - type: [item_pairs\n", []string{"bad.yaml", "line"}},
+		{"empty", "", []string{"bad.yaml: the ruleset is empty"}},
+		{"unknown top level field", "rule:\n  - type: item_pairs\n", []string{`bad.yaml:1: unknown field "rule"`}},
+		{"missing type", "rules:\n  - params:\n      points: 5\n", []string{"bad.yaml:2: rule 1: type is required"}},
+		{"unknown type", "rules:\n  - type: item_pairs\n  - type: nope\n", []string{`bad.yaml:3: rule 2: unknown rule type "nope"`}},
+		{"unknown param", "rules:\n  - type: item_pairs\n    params:\n      groupsize: 3\n", []string{`bad.yaml:4: rule 1: item_pairs params: unknown field "groupsize"`}},
+		{"wrong param type", "rules:\n  - type: item_pairs\n    params:\n      groupSize: two\n", []string{"bad.yaml:4: rule 1: item_pairs params: groupSize: cannot unmarshal"}},
+		{"invalid param", "rules:\n  - type: purchase_time\n    params:\n      after: \"16:00\"\n      before: \"14:00\"\n", []string{"bad.yaml:4: rule 1: purchase_time params: after must be earlier than before"}},
+		{"multiplier precision", "rules:\n  - type: item_description\n    params:\n      priceMultiplier: 0.125\n", []string{"bad.yaml:4: rule 1: item_description params: priceMultiplier 0.125 has more than two decimal places"}},
+		{"money param", "rules:\n  - type: total_multiple\n    params:\n      multiple: 0.255\n", []string{"bad.yaml:4: rule 1: total_multiple params: multiple: invalid money amount"}},
+		{"window date", "rules:\n  - type: bonus\n    validFrom: 2024-11-31\n", []string{`bad.yaml:2: rule 1: validFrom "2024-11-31" must be a date formatted as YYYY-MM-DD`}},
+		{"window order", "rules:\n  - type: bonus\n    validFrom: 2024-11-30\n    validUntil: 2024-11-20\n", []string{"bad.yaml:2: rule 1: validFrom must not be later than validUntil"}},
+		{"empty retailer", "rules:\n  - type: multiplier\n    params:\n      retailers: [\"\"]\n", []string{"bad.yaml:4: rule 1: multiplier params: retailers can't have an empty name"}},
+		{"invalid expression", "rules:\n  - type: expression\n    params:\n      expression: total > 1\n", []string{"bad.yaml:4: rule 1: expression params: expression: the expression must give a number of points, not a bool"}},
+		{"missing expression", "rules:\n  - type: expression\n", []string{"bad.yaml:2: rule 1: expression params: expression is required"}},
+		{"rule limits", "rules:\n  - type: bonus\n    minPoints: 10\n    maxPoints: 5\n", []string{"bad.yaml:2: rule 1: minPoints must not be greater than maxPoints"}},
+		{"item limit", "rules:\n  - type: item_description\n    maxItemPoints: -1\n", []string{"bad.yaml:2: rule 1: maxItemPoints can't be negative"}},
+		{"receipt limits", "minPoints: 10\nmaxPoints: 5\nrules:\n  - type: bonus\n", []string{"bad.yaml:1: minPoints must not be greater than maxPoints"}},
+		{"rounding mode", "rounding:\n  mode: up\nrules:\n  - type: bonus\n", []string{`bad.yaml:2: rounding mode "up" must be one of ceil, floor, half_up or half_even`}},
+		{"rounding scope", "rounding:\n  scope: receipt\nrules:\n  - type: bonus\n", []string{`bad.yaml:2: rounding scope "receipt" must be item or total`}},
+		{"rounding field", "rounding:\n  places: 2\nrules:\n  - type: bonus\n", []string{`bad.yaml:2: rounding: unknown field "places"`}},
+		{"time zone", "timeZone: Mars/Olympus\nrules:\n  - type: bonus\n", []string{`bad.yaml:1: timeZone: unknown time zone "Mars/Olympus"`}},
+		{"retailer time zone", "retailers:\n  - name: Target\n    timeZone: \"+25:00\"\nrules:\n  - type: bonus\n", []string{`bad.yaml:2: retailer 1: timeZone: "+25:00" is not a valid UTC offset`}},
+		{"retailer twice", "retailers:\n  - name: Target\n  - name: target\nrules:\n  - type: bonus\n", []string{`bad.yaml:3: retailer 2: "target" is given more than once`}},
+		{"retailer field", "retailers:\n  - name: Target\n    zone: UTC\nrules:\n  - type: bonus\n", []string{`bad.yaml:3: retailer 1: unknown field "zone"`}},
+		{"zone window", "rules:\n  - type: purchase_time\n    params:\n      zones:\n        America/Denver:\n          after: \"16:00\"\n          before: \"15:00\"\n", []string{"bad.yaml:4: rule 1: purchase_time params: zones America/Denver: after must be earlier than before"}},
+		{"item limit with total rounding", "rounding:\n  scope: total\nrules:\n  - type: item_description\n    maxItemPoints: 5\n", []string{"bad.yaml:4: rule 1: maxItemPoints can't be used when rounding scope is total"}},
+		{"duplicate", "rules:\n  - type: item_pairs\n  - type: item_pairs\n", []string{`bad.yaml:3: rule 2: rule "item_pairs" is already in the ruleset`}},
+		{
+			"every error is reported",
+			"rules:\n  - type: nope\n  - type: item_pairs\n    params:\n      groupSize: 0\n",
+			[]string{`bad.yaml:2: rule 1: unknown rule type "nope"`, "bad.yaml:5: rule 2: item_pairs params: groupSize must be greater than 0"},
+		},
+	}
+
+	for _, test := range testTable {
+		_, err := DefaultRegistry.ParseRuleset([]byte(test.document), "bad.yaml")
+		if err == nil {
+			t.Errorf("ParseRuleset(%s) should have returned an error", test.name)
+			continue
+		}
+
+		for _, expected := range test.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("ParseRuleset(%s) = got error %q, wanted it to contain %q", test.name, err.Error(), expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,672 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// A small expression language for custom rules, e.g.
+//
+//	retailer matches "(?i)walgreens" && total > 20 ? 15 : 0
+//
+// Expressions are parsed and type checked once, into closures that can only
+// read the fields of a receipt. There are no loops or assignments, so every
+// expression finishes. Numbers are exact fractions, so 0.1 + 0.2 == 0.3 and
+// money never picks up rounding errors.
+//
+// Operators, from the loosest binding to the tightest:
+//
+//	c ? a : b
+//	||
+//	&&
+//	== !=
+//	< <= > >= matches contains
+//	+ -
+//	* / %
+//	! - (unary)
+//
+// Functions: len, trim, lower, upper (strings) and ceil, floor, round, abs,
+// min, max (numbers).
+
+// The types of values an expression can produce
+type exprType int
+
+const (
+	exprNumber exprType = iota + 1
+	exprString
+	exprBool
+)
+
+func (t exprType) String() string {
+	switch t {
+	case exprNumber:
+		return "number"
+	case exprString:
+		return "string"
+	case exprBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// What an expression is evaluated against: a receipt and, for per item
+// expressions, one of its items
+type exprScope struct {
+	receipt *models.Receipt
+	item    *models.Item
+}
+
+// A field of the receipt or item that expressions can read
+type exprVariable struct {
+	typ exprType
+	get func(scope exprScope) any
+}
+
+// The fields every expression can read
+var receiptVariables = map[string]exprVariable{
+	"retailer":     {exprString, func(s exprScope) any { return s.receipt.Retailer }},
+	"purchaseDate": {exprString, func(s exprScope) any { return s.receipt.PurchaseDate }},
+	"purchaseTime": {exprString, func(s exprScope) any { return s.receipt.PurchaseTime }},
+	"total":        {exprNumber, func(s exprScope) any { return moneyRat(s.receipt.Total) }},
+	"itemCount":    {exprNumber, func(s exprScope) any { return big.NewRat(int64(len(s.receipt.Items)), 1) }},
+}
+
+// The fields that per item expressions can also read
+var itemVariables = map[string]exprVariable{
+	"shortDescription": {exprString, func(s exprScope) any { return s.item.ShortDescription }},
+	"price":            {exprNumber, func(s exprScope) any { return moneyRat(s.item.Price) }},
+}
+
+// A function expressions can call
+type exprFunction struct {
+	params []exprType
+	result exprType
+	call   func(args []any) any
+}
+
+var exprFunctions = map[string]exprFunction{
+	"len": {[]exprType{exprString}, exprNumber, func(args []any) any {
+		return big.NewRat(int64(utf8.RuneCountInString(args[0].(string))), 1)
+	}},
+	"trim":  {[]exprType{exprString}, exprString, func(args []any) any { return strings.TrimSpace(args[0].(string)) }},
+	"lower": {[]exprType{exprString}, exprString, func(args []any) any { return strings.ToLower(args[0].(string)) }},
+	"upper": {[]exprType{exprString}, exprString, func(args []any) any { return strings.ToUpper(args[0].(string)) }},
+	"ceil":  {[]exprType{exprNumber}, exprNumber, func(args []any) any { return ratCeil(args[0].(*big.Rat)) }},
+	"floor": {[]exprType{exprNumber}, exprNumber, func(args []any) any { return ratFloor(args[0].(*big.Rat)) }},
+	"round": {[]exprType{exprNumber}, exprNumber, func(args []any) any { return ratRound(args[0].(*big.Rat)) }},
+	"abs":   {[]exprType{exprNumber}, exprNumber, func(args []any) any { return new(big.Rat).Abs(args[0].(*big.Rat)) }},
+	"min": {[]exprType{exprNumber, exprNumber}, exprNumber, func(args []any) any {
+		if args[0].(*big.Rat).Cmp(args[1].(*big.Rat)) <= 0 {
+			return args[0]
+		}
+		return args[1]
+	}},
+	"max": {[]exprType{exprNumber, exprNumber}, exprNumber, func(args []any) any {
+		if args[0].(*big.Rat).Cmp(args[1].(*big.Rat)) >= 0 {
+			return args[0]
+		}
+		return args[1]
+	}},
+}
+
+// A compiled expression that works out a number of points
+type expression struct {
+	root *exprNode
+}
+
+// A type checked part of an expression. Values are *big.Rat, string or bool
+// depending on the type.
+type exprNode struct {
+	typ  exprType
+	eval func(scope exprScope) (any, error)
+	// The value of a literal, nil for anything else
+	constant any
+}
+
+// Parse and type check an expression that gives a number. Per item
+// expressions can also read the fields of an item.
+func compileExpression(source string, perItem bool) (*expression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := receiptVariables
+	if perItem {
+		variables = map[string]exprVariable{}
+		for name, variable := range receiptVariables {
+			variables[name] = variable
+		}
+		for name, variable := range itemVariables {
+			variables[name] = variable
+		}
+	}
+
+	p := &exprParser{tokens: tokens, variables: variables}
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.errorAt(next, "unexpected %q", next.text)
+	}
+	if root.typ != exprNumber {
+		return nil, fmt.Errorf("the expression must give a number of points, not a %s", root.typ)
+	}
+
+	return &expression{root: root}, nil
+}
+
+// The most points an expression can give, in either direction
+var maxExpressionPoints = big.NewRat(1<<31-1, 1)
+
+// Evaluate the expression, returning the exact points before rounding
+func (e *expression) points(scope exprScope) (*big.Rat, error) {
+	value, err := e.root.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	exact := value.(*big.Rat)
+	if new(big.Rat).Abs(exact).Cmp(maxExpressionPoints) > 0 {
+		return nil, fmt.Errorf("%s points is out of range", exact.FloatString(2))
+	}
+	return exact, nil
+}
+
+// The kinds of tokens in an expression
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind tokenKind
+	// The text of the token, unquoted for strings
+	text string
+	// Where the token starts, in bytes
+	pos int
+}
+
+// The operators, longest first so "<=" isn't read as "<"
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!", "?", ":", "(", ")", ","}
+
+// Split an expression into tokens
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r >= '0' && r <= '9' || r == '.':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokenNumber, source[start:i], start})
+
+		case r == '"' || r == '`':
+			// Backquoted strings are raw, for patterns with backslashes
+			start := i
+			for i++; i < len(source) && source[i] != byte(r); i++ {
+				if r == '"' && source[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("column %d: the string is never closed", start+1)
+			}
+			i++
+			text, err := strconv.Unquote(source[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("column %d: invalid string %s", start+1, source[start:i])
+			}
+			tokens = append(tokens, exprToken{tokenString, text, start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(source) {
+				r, size := utf8.DecodeRuneInString(source[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, exprToken{tokenIdent, source[start:i], start})
+
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, exprToken{tokenOperator, op, i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("column %d: unexpected %q", i+1, r)
+			}
+		}
+	}
+
+	return append(tokens, exprToken{tokenEOF, "end of the expression", len(source)}), nil
+}
+
+// Builds the closures of an expression out of its tokens, one precedence
+// level per method
+type exprParser struct {
+	tokens    []exprToken
+	pos       int
+	variables map[string]exprVariable
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// Whether the next token is one of the operators or keywords
+func (p *exprParser) at(texts ...string) bool {
+	token := p.peek()
+	if token.kind != tokenOperator && token.kind != tokenIdent {
+		return false
+	}
+	for _, text := range texts {
+		if token.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.at(text) {
+		return p.errorAt(p.peek(), "expected %q but found %q", text, p.peek().text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) errorAt(token exprToken, format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", token.pos+1, fmt.Sprintf(format, args...))
+}
+
+// c ? a : b
+func (p *exprParser) parseConditional() (*exprNode, error) {
+	condition, err := p.parseBinary(0)
+	if err != nil || !p.at("?") {
+		return condition, err
+	}
+
+	question := p.next()
+	if condition.typ != exprBool {
+		return nil, p.errorAt(question, "the condition before ? must be a bool, not a %s", condition.typ)
+	}
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	colon := p.peek()
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if then.typ != otherwise.typ {
+		return nil, p.errorAt(colon, "both sides of : must be the same type, not %s and %s", then.typ, otherwise.typ)
+	}
+
+	return &exprNode{typ: then.typ, eval: func(scope exprScope) (any, error) {
+		value, err := condition.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		if value.(bool) {
+			return then.eval(scope)
+		}
+		return otherwise.eval(scope)
+	}}, nil
+}
+
+// The binary operators of each precedence level, loosest first
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">=", "matches", "contains"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// The binary operators from the given precedence level down
+func (p *exprParser) parseBinary(level int) (*exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.at(exprPrecedence[level]...) {
+		op := p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.binary(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// Type check a binary operator and build its closure
+func (p *exprParser) binary(op exprToken, left *exprNode, right *exprNode) (*exprNode, error) {
+	mismatch := func(wanted string) error {
+		return p.errorAt(op, "%s needs %s, not %s and %s", op.text, wanted, left.typ, right.typ)
+	}
+
+	switch op.text {
+	case "&&", "||":
+		if left.typ != exprBool || right.typ != exprBool {
+			return nil, mismatch("bools")
+		}
+		and := op.text == "&&"
+		return &exprNode{typ: exprBool, eval: func(scope exprScope) (any, error) {
+			value, err := left.eval(scope)
+			if err != nil {
+				return nil, err
+			}
+			// Stop as soon as the answer is known
+			if value.(bool) != and {
+				return value, nil
+			}
+			return right.eval(scope)
+		}}, nil
+
+	case "matches":
+		if left.typ != exprString || right.typ != exprString || right.constant == nil {
+			return nil, p.errorAt(op, "matches needs a string on the left and a quoted pattern on the right")
+		}
+		pattern, err := regexp.Compile(right.constant.(string))
+		if err != nil {
+			return nil, p.errorAt(op, "invalid pattern: %s", err.Error())
+		}
+		return stringTest(left, func(s string) bool { return pattern.MatchString(s) }), nil
+
+	case "contains":
+		if left.typ != exprString || right.typ != exprString {
+			return nil, mismatch("strings")
+		}
+		return binaryNode(exprBool, left, right, func(a any, b any) (any, error) {
+			return strings.Contains(a.(string), b.(string)), nil
+		}), nil
+
+	case "==", "!=", "<", "<=", ">", ">=":
+		if left.typ != right.typ {
+			return nil, mismatch("two values of the same type")
+		}
+		if left.typ == exprBool && op.text != "==" && op.text != "!=" {
+			return nil, mismatch("numbers or strings")
+		}
+		compare := op.text
+		return binaryNode(exprBool, left, right, func(a any, b any) (any, error) {
+			return compareValues(compare, a, b), nil
+		}), nil
+
+	default:
+		if left.typ != exprNumber || right.typ != exprNumber {
+			return nil, mismatch("numbers")
+		}
+		arithmetic := op.text
+		return binaryNode(exprNumber, left, right, func(a any, b any) (any, error) {
+			x, y := a.(*big.Rat), b.(*big.Rat)
+			switch arithmetic {
+			case "+":
+				return new(big.Rat).Add(x, y), nil
+			case "-":
+				return new(big.Rat).Sub(x, y), nil
+			case "*":
+				return new(big.Rat).Mul(x, y), nil
+			}
+			if y.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if arithmetic == "/" {
+				return new(big.Rat).Quo(x, y), nil
+			}
+			if !x.IsInt() || !y.IsInt() {
+				return nil, fmt.Errorf("%% needs whole numbers, not %s and %s", x.RatString(), y.RatString())
+			}
+			return new(big.Rat).SetInt(new(big.Int).Mod(x.Num(), y.Num())), nil
+		}), nil
+	}
+}
+
+// ! and unary -
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if !p.at("!", "-") {
+		return p.parsePrimary()
+	}
+
+	op := p.next()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	if op.text == "!" {
+		if operand.typ != exprBool {
+			return nil, p.errorAt(op, "! needs a bool, not a %s", operand.typ)
+		}
+		return &exprNode{typ: exprBool, eval: func(scope exprScope) (any, error) {
+			value, err := operand.eval(scope)
+			if err != nil {
+				return nil, err
+			}
+			return !value.(bool), nil
+		}}, nil
+	}
+
+	if operand.typ != exprNumber {
+		return nil, p.errorAt(op, "- needs a number, not a %s", operand.typ)
+	}
+	return &exprNode{typ: exprNumber, eval: func(scope exprScope) (any, error) {
+		value, err := operand.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).Neg(value.(*big.Rat)), nil
+	}}, nil
+}
+
+// Literals, fields, function calls and parentheses
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	token := p.next()
+
+	switch token.kind {
+	case tokenNumber:
+		value, ok := new(big.Rat).SetString(token.text)
+		if !ok {
+			return nil, p.errorAt(token, "invalid number %q", token.text)
+		}
+		return constantNode(exprNumber, value), nil
+
+	case tokenString:
+		return constantNode(exprString, token.text), nil
+
+	case tokenIdent:
+		switch token.text {
+		case "true", "false":
+			return constantNode(exprBool, token.text == "true"), nil
+		}
+
+		if p.at("(") {
+			return p.parseCall(token)
+		}
+
+		variable, ok := p.variables[token.text]
+		if !ok {
+			if _, isItemField := itemVariables[token.text]; isItemField {
+				return nil, p.errorAt(token, "%s can only be used by per item expressions", token.text)
+			}
+			return nil, p.errorAt(token, "unknown field %q", token.text)
+		}
+		return &exprNode{typ: variable.typ, eval: func(scope exprScope) (any, error) {
+			return variable.get(scope), nil
+		}}, nil
+
+	case tokenOperator:
+		if token.text == "(" {
+			inner, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, p.errorAt(token, "unexpected %q", token.text)
+}
+
+// name(arguments)
+func (p *exprParser) parseCall(name exprToken) (*exprNode, error) {
+	function, ok := exprFunctions[name.text]
+	if !ok {
+		return nil, p.errorAt(name, "unknown function %q", name.text)
+	}
+	p.next()
+
+	var args []*exprNode
+	for !p.at(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != len(function.params) {
+		return nil, p.errorAt(name, "%s takes %d arguments, not %d", name.text, len(function.params), len(args))
+	}
+	for i, arg := range args {
+		if arg.typ != function.params[i] {
+			return nil, p.errorAt(name, "argument %d of %s must be a %s, not a %s", i+1, name.text, function.params[i], arg.typ)
+		}
+	}
+
+	return &exprNode{typ: function.result, eval: func(scope exprScope) (any, error) {
+		values := make([]any, len(args))
+		for i, arg := range args {
+			value, err := arg.eval(scope)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return function.call(values), nil
+	}}, nil
+}
+
+// A literal value
+func constantNode(typ exprType, value any) *exprNode {
+	return &exprNode{typ: typ, constant: value, eval: func(exprScope) (any, error) {
+		return value, nil
+	}}
+}
+
+// Evaluate both sides and combine them
+func binaryNode(typ exprType, left *exprNode, right *exprNode, combine func(a any, b any) (any, error)) *exprNode {
+	return &exprNode{typ: typ, eval: func(scope exprScope) (any, error) {
+		a, err := left.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		return combine(a, b)
+	}}
+}
+
+// Test a string
+func stringTest(operand *exprNode, test func(s string) bool) *exprNode {
+	return &exprNode{typ: exprBool, eval: func(scope exprScope) (any, error) {
+		value, err := operand.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		return test(value.(string)), nil
+	}}
+}
+
+// Compare two values of the same type
+func compareValues(op string, a any, b any) bool {
+	var order int
+	switch x := a.(type) {
+	case *big.Rat:
+		order = x.Cmp(b.(*big.Rat))
+	case string:
+		order = strings.Compare(x, b.(string))
+	case bool:
+		if x != b.(bool) {
+			order = 1
+		}
+	}
+
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+	return order >= 0
+}
+
+// The exact value of an amount of money, in dollars
+func moneyRat(amount models.Money) *big.Rat {
+	return big.NewRat(amount.Cents(), 100)
+}
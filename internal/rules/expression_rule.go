@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The name of the custom expression rule type
+const ExpressionRuleType = "expression"
+
+// Points worked out by an expression over the fields of the receipt, or of
+// each of its items. Fractional points are rounded by the rounding policy of
+// the ruleset.
+type ExpressionRule struct {
+	Expression string `yaml:"expression"`
+	// Evaluate the expression once for every item and add up the points
+	PerItem bool `yaml:"perItem"`
+	// Explains the points in the breakdown, the expression is shown when empty
+	Description string `yaml:"description"`
+
+	compiled *expression
+}
+
+func (r *ExpressionRule) Name() string {
+	return ExpressionRuleType
+}
+
+// Compiles the expression, so it is only parsed once
+func (r *ExpressionRule) Validate() error {
+	if strings.TrimSpace(r.Expression) == "" {
+		return errors.New("expression is required")
+	}
+
+	compiled, err := compileExpression(r.Expression, r.PerItem)
+	if err != nil {
+		return fmt.Errorf("expression: %w", err)
+	}
+	r.compiled = compiled
+	return nil
+}
+
+func (r *ExpressionRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *ExpressionRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	compiled := r.compiled
+	if compiled == nil {
+		// Built in code rather than loaded from a ruleset file. It isn't kept,
+		// the rule may be evaluated by several receipts at once.
+		var err error
+		if compiled, err = compileExpression(r.Expression, r.PerItem); err != nil {
+			return RuleResult{}, fmt.Errorf("expression: %w", err)
+		}
+	}
+
+	if !r.PerItem {
+		value, err := compiled.points(exprScope{receipt: &rec})
+		if err != nil {
+			return RuleResult{}, fmt.Errorf("%s: %w", r.describe(), err)
+		}
+		points := ctx.Rounding.round(value)
+		if value.IsInt() {
+			return RuleResult{Points: points, Reason: fmt.Sprintf("%s gave %d points", r.describe(), points)}, nil
+		}
+		return RuleResult{
+			Points: points,
+			Reason: fmt.Sprintf("%s gave %s, %s is %d points", r.describe(), value.FloatString(2), ctx.Rounding.describe(), points),
+		}, nil
+	}
+
+	var result RuleResult
+	var values []*big.Rat
+	for i := range rec.Items {
+		item := &rec.Items[i]
+		value, err := compiled.points(exprScope{receipt: &rec, item: item})
+		if err != nil {
+			return RuleResult{}, fmt.Errorf("%s, item %q: %w", r.describe(), item.ShortDescription, err)
+		}
+		if value.Sign() == 0 {
+			continue
+		}
+
+		exact, _ := value.Float64()
+		result.Items = append(result.Items, PointRuleItem{
+			Description:       item.ShortDescription,
+			Price:             item.Price,
+			DescriptionLength: len(strings.TrimSpace(item.ShortDescription)),
+			Value:             exact,
+			Reason:            fmt.Sprintf("%q gave %s", item.ShortDescription, value.FloatString(2)),
+		})
+		values = append(values, value)
+	}
+
+	points, rounding := ctx.Rounding.roundItems(result.Items, values)
+	result.Points = points
+	result.Reason = fmt.Sprintf("%s gave points for %d items", r.describe(), len(result.Items)) + rounding
+	return result, nil
+}
+
+// The description, or the expression when there isn't one
+func (r *ExpressionRule) describe() string {
+	if r.Description != "" {
+		return r.Description
+	}
+	return fmt.Sprintf("%q", r.Expression)
+}
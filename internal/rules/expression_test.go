@@ -0,0 +1,153 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+var walgreensReceipt = models.Receipt{
+	Retailer:     "Walgreens",
+	PurchaseDate: "2022-01-02",
+	PurchaseTime: "08:13",
+	Items: []models.Item{
+		{ShortDescription: "Pepsi - 12-oz", Price: models.MustParseMoney("1.25")},
+		{ShortDescription: "Dasani", Price: models.MustParseMoney("1.40")},
+	},
+	Total: models.MustParseMoney("22.65"),
+}
+
+func TestExpressionPoints(t *testing.T) {
+	testTable := []struct {
+		expression string
+		receipt    models.Receipt
+		expected   int
+	}{
+		{`retailer matches "(?i)walgreens" && total > 20 ? 15 : 0`, walgreensReceipt, 15},
+		{`retailer matches "(?i)walgreens" && total > 20 ? 15 : 0`, targetReceipt, 0},
+		{`retailer matches ` + "`^\\w+$`" + ` ? 1 : 0`, walgreensReceipt, 1},
+		{`itemCount * 2 + 1`, targetReceipt, 11},
+		// Exact arithmetic, 0.1 + 0.2 is exactly 0.3
+		{`0.1 + 0.2 == 0.3 ? 1 : 0`, targetReceipt, 1},
+		// 35.35 / 10 is 3.535, rounded up by the default policy
+		{`total / 10`, targetReceipt, 4},
+		{`floor(total / 10)`, targetReceipt, 3},
+		{`ceil(total * 0.2)`, targetReceipt, 8},
+		{`-round(2.5)`, targetReceipt, -3},
+		{`max(min(total, 10), 2)`, targetReceipt, 10},
+		{`abs(-4)`, targetReceipt, 4},
+		{`len(lower(retailer)) % 4`, walgreensReceipt, 1},
+		{`purchaseDate >= "2022-01-01" && purchaseTime < "12:00" ? 5 : 0`, walgreensReceipt, 5},
+		{`!(retailer contains "Tar") ? 1 : 2`, targetReceipt, 2},
+		{`upper(trim(" a ")) == "A" || 1 / 0 > 1 ? 3 : 0`, targetReceipt, 3},
+	}
+
+	for _, test := range testTable {
+		compiled, err := compileExpression(test.expression, false)
+		if err != nil {
+			t.Errorf("compileExpression(%s) got an error: %q", test.expression, err.Error())
+			continue
+		}
+
+		value, err := compiled.points(exprScope{receipt: &test.receipt})
+		if err != nil {
+			t.Errorf("points(%s) got an error: %q", test.expression, err.Error())
+			continue
+		}
+		points := RoundingPolicy{}.round(value)
+		if points != test.expected {
+			t.Errorf("points(%s) for %s = got %d, wanted %d", test.expression, test.receipt.Retailer, points, test.expected)
+		}
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	testTable := []struct {
+		expression string
+		perItem    bool
+		expected   string
+	}{
+		{`retailer`, false, "must give a number of points, not a string"},
+		{`total > 20`, false, "must give a number of points, not a bool"},
+		{`total > "20"`, false, "column 7: > needs two values of the same type, not number and string"},
+		{`total ? 1 : 0`, false, "column 7: the condition before ? must be a bool"},
+		{`total > 1 ? 1 : "no"`, false, "both sides of : must be the same type"},
+		{`retailer matches total`, false, "matches needs a string on the left and a quoted pattern"},
+		{`retailer matches "(" ? 1 : 0`, false, "invalid pattern"},
+		{`price * 2`, false, "price can only be used by per item expressions"},
+		{`points`, false, `column 1: unknown field "points"`},
+		{`sqrt(total)`, false, `unknown function "sqrt"`},
+		{`ceil(total, 2)`, false, "ceil takes 1 arguments, not 2"},
+		{`len(total)`, false, "argument 1 of len must be a string, not a number"},
+		{`(total`, false, `expected ")"`},
+		{`total 2`, false, `column 7: unexpected "2"`},
+		{`"open`, false, "the string is never closed"},
+		{`total # 2`, false, `column 7: unexpected '#'`},
+		{`1.2.3`, false, `invalid number "1.2.3"`},
+		{`-retailer`, false, "- needs a number"},
+		{`!total`, false, "! needs a bool"},
+	}
+
+	for _, test := range testTable {
+		_, err := compileExpression(test.expression, test.perItem)
+		if err == nil {
+			t.Errorf("compileExpression(%s) should have returned an error", test.expression)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("compileExpression(%s) = got error %q, wanted it to contain %q", test.expression, err.Error(), test.expected)
+		}
+	}
+}
+
+func TestExpressionRule(t *testing.T) {
+	// The item description rule written as an expression scores the same
+	document := `
+rules:
+  - type: expression
+    name: description_length
+    params:
+      perItem: true
+      expression: 'len(trim(shortDescription)) % 3 == 0 ? ceil(price * 0.2) : 0'
+  - type: expression
+    name: walgreens_big_spender
+    params:
+      expression: 'retailer matches "(?i)walgreens" && total > 20 ? 15 : 0'
+      description: Big spenders at Walgreens
+`
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(document), "expressions.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+	builtin := &ItemDescriptionRule{LengthMultiple: 3, PriceMultiplier: 0.2}
+
+	for _, receipt := range []models.Receipt{targetReceipt, cornerMarketReceipt, walgreensReceipt} {
+		score, err := ruleset.Calculate(receipt)
+		if err != nil {
+			t.Fatalf("Calculate(%q) got an error: %q", receipt.Retailer, err.Error())
+		}
+		expected, _ := builtin.Evaluate(receipt)
+
+		perItem := score.Results[0]
+		if perItem.Points != expected.Points || len(perItem.Items) != len(expected.Items) {
+			t.Errorf("Calculate(%q) per item expression = got %d points over %d items, wanted %d over %d",
+				receipt.Retailer, perItem.Points, len(perItem.Items), expected.Points, len(expected.Items))
+		}
+
+		bigSpender := score.Results[1]
+		wanted := 0
+		if receipt.Retailer == "Walgreens" {
+			wanted = 15
+		}
+		if bigSpender.Points != wanted || !strings.HasPrefix(bigSpender.Reason, "Big spenders at Walgreens") {
+			t.Errorf("Calculate(%q) expression = got %d points (%q), wanted %d", receipt.Retailer, bigSpender.Points, bigSpender.Reason, wanted)
+		}
+	}
+
+	// Errors while evaluating fail the calculation
+	rule := &ExpressionRule{Expression: "total / (itemCount - itemCount)"}
+	if _, err := rule.Evaluate(targetReceipt); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("Evaluate of a division by zero = got error %v, wanted division by zero", err)
+	}
+}
@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The kinds of point adjustments
+const (
+	// The points were reduced to a maximum
+	AdjustmentCap = "cap"
+	// The points were raised to a minimum
+	AdjustmentMinimum = "minimum"
+)
+
+// The names of the breakdown lines that adjust the points of the whole receipt
+const (
+	ReceiptCapRuleName     = "receipt_cap"
+	ReceiptMinimumRuleName = "receipt_minimum"
+)
+
+// How a cap or minimum changed the points of a rule, item or receipt
+type PointAdjustment struct {
+	// "cap" or "minimum"
+	Kind string `json:"kind"`
+	// The maximum or minimum that was applied
+	Limit int `json:"limit"`
+	// The points before they were adjusted
+	Before int `json:"before"`
+	// The points after less the points before, negative for caps
+	Change int `json:"change"`
+}
+
+// The least and most points something can be awarded, either can be left out
+type pointLimits struct {
+	min *int
+	max *int
+}
+
+// Check that the minimum isn't above the maximum
+func (l pointLimits) validate() error {
+	if l.min != nil && l.max != nil && *l.min > *l.max {
+		return errors.New("minPoints must not be greater than maxPoints")
+	}
+	return nil
+}
+
+// Keep the points within the limits, returning how they were adjusted or
+// nil if they already were
+func (l pointLimits) apply(points int) (int, *PointAdjustment) {
+	switch {
+	case l.max != nil && points > *l.max:
+		return *l.max, &PointAdjustment{Kind: AdjustmentCap, Limit: *l.max, Before: points, Change: *l.max - points}
+	case l.min != nil && points < *l.min:
+		return *l.min, &PointAdjustment{Kind: AdjustmentMinimum, Limit: *l.min, Before: points, Change: *l.min - points}
+	}
+	return points, nil
+}
+
+// Explain an adjustment, e.g. "capped at 50, down from 1000"
+func (a *PointAdjustment) describe() string {
+	if a.Kind == AdjustmentCap {
+		return fmt.Sprintf("capped at %d, down from %d", a.Limit, a.Before)
+	}
+	return fmt.Sprintf("raised to the minimum of %d, up from %d", a.Limit, a.Before)
+}
+
+// Keeps the points of a rule, and of each item it scores, within limits.
+// Items are capped first, then the rule as a whole.
+type limitedRule struct {
+	Rule
+	limits pointLimits
+	// The most points a single item can contribute
+	maxItemPoints *int
+}
+
+func validateRuleLimits(limits pointLimits, maxItemPoints *int) error {
+	if maxItemPoints != nil && *maxItemPoints < 0 {
+		return errors.New("maxItemPoints can't be negative")
+	}
+	return limits.validate()
+}
+
+func (r *limitedRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *limitedRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	result, err := evaluateRule(r.Rule, rec, ctx)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	if r.maxItemPoints != nil {
+		itemLimits := pointLimits{max: r.maxItemPoints}
+		for i := range result.Items {
+			item := &result.Items[i]
+			points, adjustment := itemLimits.apply(item.Points)
+			if adjustment == nil {
+				continue
+			}
+			item.Points, item.Adjustment = points, adjustment
+			item.Reason += ", " + adjustment.describe()
+			result.Points += adjustment.Change
+		}
+	}
+
+	points, adjustment := r.limits.apply(result.Points)
+	if adjustment != nil {
+		result.Points, result.Adjustment = points, adjustment
+		result.Reason += ", " + adjustment.describe()
+	}
+	return result, nil
+}
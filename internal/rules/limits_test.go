@@ -0,0 +1,126 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+func TestPointLimits(t *testing.T) {
+	ten, fifty := 10, 50
+
+	testTable := []struct {
+		limits     pointLimits
+		points     int
+		expected   int
+		adjustment *PointAdjustment
+	}{
+		{pointLimits{max: &fifty}, 40, 40, nil},
+		{pointLimits{max: &fifty}, 50, 50, nil},
+		{pointLimits{max: &fifty}, 1000, 50, &PointAdjustment{Kind: AdjustmentCap, Limit: 50, Before: 1000, Change: -950}},
+		{pointLimits{min: &ten}, 4, 10, &PointAdjustment{Kind: AdjustmentMinimum, Limit: 10, Before: 4, Change: 6}},
+		{pointLimits{min: &ten, max: &fifty}, 30, 30, nil},
+		{pointLimits{}, 1000, 1000, nil},
+	}
+
+	for _, test := range testTable {
+		got, adjustment := test.limits.apply(test.points)
+		if got != test.expected {
+			t.Errorf("apply(%d) = got %d, wanted %d", test.points, got, test.expected)
+		}
+		if (adjustment == nil) != (test.adjustment == nil) || (adjustment != nil && *adjustment != *test.adjustment) {
+			t.Errorf("apply(%d) = got adjustment %+v, wanted %+v", test.points, adjustment, test.adjustment)
+		}
+	}
+}
+
+func TestRulesetLimits(t *testing.T) {
+	expensive := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:01",
+		Items: []models.Item{
+			{ShortDescription: "TV Set", Price: models.MustParseMoney("5000.00")},
+			{ShortDescription: "Gum", Price: models.MustParseMoney("1.00")},
+		},
+		Total: models.MustParseMoney("5001.00"),
+	}
+
+	document := `
+maxPoints: 100
+rules:
+  - type: retailer_alphanumeric
+    minPoints: 10
+  - type: item_description
+    maxItemPoints: 50
+  - type: round_dollar_total
+    maxPoints: 20
+`
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(document), "limits.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	score, err := ruleset.Calculate(expensive)
+	if err != nil {
+		t.Fatalf("Calculate got an error: %q", err.Error())
+	}
+
+	// 6 raised to 10, the television's 1000 capped at 50 plus 1 for the gum,
+	// 50 capped at 20, then the 81 left is under the receipt cap
+	expected := []struct {
+		name       string
+		points     int
+		adjustment string
+		change     int
+	}{
+		{RetailerAlphanumericRuleType, 10, AdjustmentMinimum, 4},
+		{ItemDescriptionRuleType, 51, "", 0},
+		{RoundDollarTotalRuleType, 20, AdjustmentCap, -30},
+	}
+	if len(score.Results) != len(expected) || score.Total != 81 {
+		t.Fatalf("Calculate = got %d points from %+v, wanted 81 from %d rules", score.Total, score.Results, len(expected))
+	}
+	for i, test := range expected {
+		result := score.Results[i]
+		if result.Name != test.name || result.Points != test.points {
+			t.Errorf("rule %d = got %q with %d points, wanted %q with %d", i, result.Name, result.Points, test.name, test.points)
+		}
+		if (result.Adjustment == nil) != (test.adjustment == "") ||
+			(result.Adjustment != nil && (result.Adjustment.Kind != test.adjustment || result.Adjustment.Change != test.change)) {
+			t.Errorf("rule %q = got adjustment %+v, wanted %q by %d", result.Name, result.Adjustment, test.adjustment, test.change)
+		}
+	}
+
+	television := score.Results[1].Items[0]
+	if television.Points != 50 || television.Adjustment == nil || television.Adjustment.Before != 1000 {
+		t.Errorf("television = got %d points with adjustment %+v, wanted 50 capped from 1000", television.Points, television.Adjustment)
+	}
+
+	// The receipt cap is a line of its own, so the lines still add up
+	lower := 60
+	ruleset.SetPointLimits(nil, &lower)
+	score, _ = ruleset.Calculate(expensive)
+	last := score.Results[len(score.Results)-1]
+	sum := 0
+	for _, result := range score.Results {
+		sum += result.Points
+	}
+	if score.Total != 60 || sum != 60 || last.Name != ReceiptCapRuleName || last.Points != -21 {
+		t.Errorf("Calculate with a receipt cap of 60 = got %d points, lines adding up to %d and last line %+v, wanted 60 and a %q line of -21",
+			score.Total, sum, last, ReceiptCapRuleName)
+	}
+
+	// A minimum lifts receipts that earn too little
+	floor := 200
+	ruleset.SetPointLimits(&floor, nil)
+	score, _ = ruleset.Calculate(expensive)
+	if last := score.Results[len(score.Results)-1]; score.Total != 200 || last.Name != ReceiptMinimumRuleName || last.Points != 119 {
+		t.Errorf("Calculate with a receipt minimum of 200 = got %d points and last line %+v, wanted 200 and a %q line of 119",
+			score.Total, last, ReceiptMinimumRuleName)
+	}
+
+	if err := ruleset.SetPointLimits(&floor, &lower); err == nil {
+		t.Errorf("SetPointLimits with a minimum over the maximum should have returned an error")
+	}
+}
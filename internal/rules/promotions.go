@@ -0,0 +1,168 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The names of the promotional rule types. They do nothing until they are
+// configured, so they aren't part of the default ruleset.
+const (
+	BonusRuleType      = "bonus"
+	MultiplierRuleType = "multiplier"
+)
+
+// A flat number of points for every receipt, or only for receipts from the
+// given retailers
+type BonusRule struct {
+	Points int `yaml:"points"`
+	// Retailer names, ignoring letter case. Every retailer when empty.
+	Retailers []string `yaml:"retailers"`
+}
+
+func (r *BonusRule) Name() string {
+	return BonusRuleType
+}
+
+func (r *BonusRule) Validate() error {
+	return validateRetailers(r.Retailers)
+}
+
+func (r *BonusRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	if !retailerMatches(r.Retailers, rec.Retailer) {
+		return RuleResult{Reason: fmt.Sprintf("Only for purchases at %s", strings.Join(r.Retailers, ", "))}, nil
+	}
+	return RuleResult{Points: r.Points, Reason: fmt.Sprintf("Bonus of %d points", r.Points)}, nil
+}
+
+// Multiplies the points awarded by the rules evaluated before it, for every
+// receipt or only for receipts from the given retailers. The extra points
+// are rounded by the rounding policy of the ruleset.
+type MultiplierRule struct {
+	// 2 doubles the points
+	Factor float64 `yaml:"factor"`
+	// Retailer names, ignoring letter case. Every retailer when empty.
+	Retailers []string `yaml:"retailers"`
+}
+
+func (r *MultiplierRule) Name() string {
+	return MultiplierRuleType
+}
+
+func (r *MultiplierRule) Validate() error {
+	if r.Factor < 0 {
+		return errors.New("factor can't be negative")
+	}
+	if _, err := multiplierHundredths(r.Factor); err != nil {
+		return fmt.Errorf("factor %w", err)
+	}
+	return validateRetailers(r.Retailers)
+}
+
+// Without the points of the other rules there is nothing to multiply
+func (r *MultiplierRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *MultiplierRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	if !retailerMatches(r.Retailers, rec.Retailer) {
+		return RuleResult{Reason: fmt.Sprintf("Only for purchases at %s", strings.Join(r.Retailers, ", "))}, nil
+	}
+
+	factor, err := multiplierHundredths(r.Factor)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	extra := big.NewRat(int64(ctx.Subtotal)*(factor-100), 100)
+	points := ctx.Rounding.round(extra)
+	return RuleResult{
+		Points: points,
+		Reason: fmt.Sprintf("%d points so far * %g is %s extra, %s is %d points", ctx.Subtotal, r.Factor, extra.FloatString(2), ctx.Rounding.describe(), points),
+	}, nil
+}
+
+// Every retailer has to have a name
+func validateRetailers(retailers []string) error {
+	for _, retailer := range retailers {
+		if strings.TrimSpace(retailer) == "" {
+			return errors.New("retailers can't have an empty name")
+		}
+	}
+	return nil
+}
+
+// Whether the retailer is one of the retailers, ignoring letter case. Every
+// retailer matches an empty list.
+func retailerMatches(retailers []string, retailer string) bool {
+	if len(retailers) == 0 {
+		return true
+	}
+	for _, name := range retailers {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(retailer)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Only awards the points of a rule to receipts purchased within a window of
+// dates. Either end may be left open.
+type windowedRule struct {
+	Rule
+	// YYYY-MM-DD dates, both ends are inclusive
+	validFrom  string
+	validUntil string
+	// The zone the dates are in, nil for the receipt's own zone
+	location *time.Location
+}
+
+// Check the dates of a window, they have to be YYYY-MM-DD and in order
+func validateWindow(validFrom string, validUntil string) error {
+	for _, date := range []struct{ name, value string }{{"validFrom", validFrom}, {"validUntil", validUntil}} {
+		if _, err := time.Parse("2006-01-02", date.value); date.value != "" && err != nil {
+			return fmt.Errorf("%s %q must be a date formatted as YYYY-MM-DD", date.name, date.value)
+		}
+	}
+	if validFrom != "" && validUntil != "" && validFrom > validUntil {
+		return errors.New("validFrom must not be later than validUntil")
+	}
+	return nil
+}
+
+func (r *windowedRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	return r.EvaluateContext(rec, EvalContext{})
+}
+
+func (r *windowedRule) EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	local, err := purchaseIn(rec, ctx.Location, r.location)
+	if err != nil {
+		return RuleResult{}, err
+	}
+
+	// YYYY-MM-DD dates sort the same as strings
+	if (r.validFrom != "" && local.date < r.validFrom) || (r.validUntil != "" && local.date > r.validUntil) {
+		where := ""
+		if local.from != nil {
+			where = fmt.Sprintf(" (%s in %s)", local.date, local.location)
+		}
+		return RuleResult{Reason: fmt.Sprintf("Purchased on %s%s, %s", rec.PurchaseDate, where, r.describeWindow())}, nil
+	}
+	return evaluateRule(r.Rule, rec, ctx)
+}
+
+// Describe the window, e.g. "only valid from 2024-11-20 to 2024-11-30"
+func (r *windowedRule) describeWindow() string {
+	switch {
+	case r.validFrom == "":
+		return "only valid until " + r.validUntil
+	case r.validUntil == "":
+		return "only valid from " + r.validFrom
+	}
+	return fmt.Sprintf("only valid from %s to %s", r.validFrom, r.validUntil)
+}
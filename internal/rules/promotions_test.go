@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+func TestPromotionalRules(t *testing.T) {
+	document := `
+rules:
+  - type: retailer_alphanumeric
+  - type: multiplier
+    name: target_double_points
+    validFrom: "2022-01-01"
+    validUntil: "2022-01-31"
+    params:
+      factor: 2
+      retailers: [target]
+  - type: bonus
+    name: new_year_bonus
+    validUntil: "2022-01-01"
+    params:
+      points: 100
+`
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(document), "promotions.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	lateTarget := targetReceipt
+	lateTarget.PurchaseDate = "2022-02-01"
+
+	testTable := []struct {
+		name     string
+		receipt  models.Receipt
+		expected []int
+	}{
+		// 6 points doubled, and the bonus on the last day of its window
+		{"in both windows", targetReceipt, []int{6, 6, 100}},
+		{"after both windows", lateTarget, []int{6, 0, 0}},
+		// The multiplier is only for Target
+		{"another retailer", cornerMarketReceipt, []int{14, 0, 0}},
+	}
+
+	for _, test := range testTable {
+		score, err := ruleset.Calculate(test.receipt)
+		if err != nil {
+			t.Fatalf("Calculate(%s) got an error: %q", test.name, err.Error())
+		}
+
+		if len(score.Results) != len(test.expected) {
+			t.Fatalf("Calculate(%s) = got %d results, wanted %d", test.name, len(score.Results), len(test.expected))
+		}
+		for i, result := range score.Results {
+			if result.Points != test.expected[i] || result.Reason == "" {
+				t.Errorf("Calculate(%s) rule %q = got %d points (%q), wanted %d", test.name, result.Name, result.Points, result.Reason, test.expected[i])
+			}
+		}
+	}
+
+	if names := ruleNames(ruleset); names[1] != "target_double_points" || names[2] != "new_year_bonus" {
+		t.Errorf("ParseRuleset = got rules %v, wanted the promotions under their own names", names)
+	}
+}
+
+func TestMultiplierRule(t *testing.T) {
+	testTable := []struct {
+		factor   float64
+		subtotal int
+		expected int
+	}{
+		{2, 20, 20},
+		{1, 20, 0},
+		// 3.5 extra points round up
+		{1.5, 7, 4},
+		{0.5, 20, -10},
+		{3, 0, 0},
+	}
+
+	for _, test := range testTable {
+		rule := &MultiplierRule{Factor: test.factor}
+		result, err := rule.EvaluateContext(targetReceipt, EvalContext{Subtotal: test.subtotal})
+		if err != nil {
+			t.Fatalf("EvaluateContext got an error: %q", err.Error())
+		}
+		if result.Points != test.expected {
+			t.Errorf("MultiplierRule{%g}.EvaluateContext(%d) = got %d, wanted %d", test.factor, test.subtotal, result.Points, test.expected)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Keeps the active ruleset in sync with a ruleset file. The new ruleset is
+// swapped in atomically, scoring that is already running finishes with the
+// ruleset it started with.
+type Reloader struct {
+	path     string
+	registry *Registry
+
+	// Serializes reloads so an older file can't win a race with a newer one
+	mu sync.Mutex
+	// What the file looked like when it was last loaded
+	modTime time.Time
+	size    int64
+}
+
+// Create a reloader for the ruleset file at path, built with the default registry
+func NewReloader(path string) *Reloader {
+	return &Reloader{path: path, registry: DefaultRegistry}
+}
+
+// Load the ruleset file and make it the active ruleset. If the file is
+// invalid the active ruleset is left alone and the error is returned.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Stat before reading, so a write that lands while we read is picked up
+	// by the next check instead of being missed
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+
+	ruleset, err := r.registry.LoadRulesetFile(r.path)
+	if err != nil {
+		// Remember this version of the file anyway, there's no point trying
+		// it again until it changes
+		r.modTime, r.size = info.ModTime(), info.Size()
+		return err
+	}
+
+	// Remembered either way, like an invalid file
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return SetRuleset(ruleset)
+}
+
+// Reload the ruleset and log the outcome
+func (r *Reloader) reloadAndLog() {
+	if err := r.Reload(); err != nil {
+		log.Printf("Could not reload the ruleset, keeping the current rules:\n%v", err)
+		return
+	}
+	log.Printf("Reloaded %d rules from %s, version %s", len(ActiveRuleset().Rules()), r.path, ActiveRuleset().Version())
+}
+
+// Whether the file is different from the one that was last loaded
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		// Editors often replace the file, so it can briefly be missing
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
+
+// Reload whenever the file changes, checking every interval, or when a value
+// arrives on signals. Blocks until the context is cancelled.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, signals <-chan os.Signal) {
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			log.Printf("Received %v, reloading the ruleset", sig)
+			r.reloadAndLog()
+		case <-ticks:
+			if r.changed() {
+				r.reloadAndLog()
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// A rule that waits until it is released, to hold a calculation in flight
+type blockingRule struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingRule) Name() string {
+	return "blocking"
+}
+
+func (r *blockingRule) Evaluate(rec models.Receipt) (RuleResult, error) {
+	close(r.started)
+	<-r.release
+	return RuleResult{}, nil
+}
+
+// Put the default ruleset back once the test is over
+func restoreActiveRuleset(t *testing.T) {
+	previous := ActiveRuleset()
+	t.Cleanup(func() { SetRuleset(previous) })
+}
+
+// Write contents to the ruleset file at path
+func writeRulesetFile(t *testing.T, path string, contents string) {
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+}
+
+func activePoints(t *testing.T) int {
+	points, err := CalculatePoints(cornerMarketReceipt)
+	if err != nil {
+		t.Fatalf("CalculatePoints got an error: %q", err.Error())
+	}
+	return points
+}
+
+func TestReloaderReload(t *testing.T) {
+	restoreActiveRuleset(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_total\n    params:\n      points: 50\n")
+	reloader := NewReloader(path)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload got an error: %q", err.Error())
+	}
+	if points := activePoints(t); points != 50 {
+		t.Errorf("CalculatePoints after Reload = got %d, wanted %d", points, 50)
+	}
+
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_total\n    params:\n      points: 75\n")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload got an error: %q", err.Error())
+	}
+	if points := activePoints(t); points != 75 {
+		t.Errorf("CalculatePoints after second Reload = got %d, wanted %d", points, 75)
+	}
+
+	// A broken file leaves the current rules in place
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_totl\n")
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("Reload of an invalid file should return an error")
+	}
+	if points := activePoints(t); points != 75 {
+		t.Errorf("CalculatePoints after a failed Reload = got %d, wanted the old %d", points, 75)
+	}
+
+	// So does a file that changes the rules of a version that was loaded
+	writeRulesetFile(t, path, "version: reloaded\nrules:\n  - type: round_dollar_total\n    params:\n      points: 60\n")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload got an error: %q", err.Error())
+	}
+	writeRulesetFile(t, path, "version: reloaded\nrules:\n  - type: round_dollar_total\n    params:\n      points: 90\n")
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("Reload of changed rules under the same version should return an error")
+	}
+	if points := activePoints(t); points != 60 {
+		t.Errorf("CalculatePoints after a refused Reload = got %d, wanted the old %d", points, 60)
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	restoreActiveRuleset(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_total\n    params:\n      points: 50\n")
+	reloader := NewReloader(path)
+	reloader.Reload()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	go reloader.Watch(ctx, 10*time.Millisecond, signals)
+
+	// Wait for the watcher to notice a change to the file
+	waitForPoints := func(expected int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for activePoints(t) != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("the active ruleset never awarded %d points", expected)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_total\n    params:\n      points: 80\n")
+	// Make sure the modification time moves even on coarse file systems
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	waitForPoints(80)
+
+	// Stop the polling from seeing the next change, only the signal should
+	cancel()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 0, signals)
+
+	writeRulesetFile(t, path, "rules:\n  - type: round_dollar_total\n    params:\n      points: 90\n")
+	signals <- syscall.SIGHUP
+	waitForPoints(90)
+}
+
+func TestSetRulesetDuringCalculation(t *testing.T) {
+	restoreActiveRuleset(t)
+
+	blocking := &blockingRule{started: make(chan struct{}), release: make(chan struct{})}
+	SetRuleset(NewRuleset(&RoundDollarTotalRule{Points: 50}, blocking))
+
+	result := make(chan int)
+	go func() {
+		points, _ := CalculatePoints(cornerMarketReceipt)
+		result <- points
+	}()
+
+	// Swap the rules while the calculation is half way through
+	<-blocking.started
+	SetRuleset(NewRuleset(&RoundDollarTotalRule{Points: 500}))
+	close(blocking.release)
+
+	if points := <-result; points != 50 {
+		t.Errorf("CalculatePoints in flight = got %d, wanted it to finish on the old ruleset with %d", points, 50)
+	}
+	if points := activePoints(t); points != 500 {
+		t.Errorf("CalculatePoints after the swap = got %d, wanted %d", points, 500)
+	}
+}
@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// The ways fractional points can be rounded to whole points
+const (
+	// Up to the next whole point, the default
+	RoundCeil = "ceil"
+	// Down to the previous whole point
+	RoundFloor = "floor"
+	// To the nearest whole point, halves away from zero
+	RoundHalfUp = "half_up"
+	// To the nearest whole point, halves to the even point (banker's rounding)
+	RoundHalfEven = "half_even"
+)
+
+// What fractional points are rounded
+const (
+	// Every item is rounded on its own, the default
+	RoundPerItem = "item"
+	// The fractional points of every item of a rule are added up and rounded once
+	RoundTotal = "total"
+)
+
+// How a ruleset turns fractional points into whole points. The zero value
+// rounds every item up.
+type RoundingPolicy struct {
+	// RoundCeil, RoundFloor, RoundHalfUp or RoundHalfEven
+	Mode string `yaml:"mode"`
+	// RoundPerItem or RoundTotal
+	Scope string `yaml:"scope"`
+}
+
+// Check the mode and scope are known
+func (p RoundingPolicy) validate() error {
+	switch p.Mode {
+	case "", RoundCeil, RoundFloor, RoundHalfUp, RoundHalfEven:
+	default:
+		return fmt.Errorf("rounding mode %q must be one of ceil, floor, half_up or half_even", p.Mode)
+	}
+
+	switch p.Scope {
+	case "", RoundPerItem, RoundTotal:
+	default:
+		return fmt.Errorf("rounding scope %q must be item or total", p.Scope)
+	}
+	return nil
+}
+
+// Whether the fractional points of items are added up before rounding
+func (p RoundingPolicy) roundsTotal() bool {
+	return p.Scope == RoundTotal
+}
+
+// Round the points to a whole number of points. Every rule rounds through
+// here, so the points and their explanation can't disagree.
+func (p RoundingPolicy) round(points *big.Rat) int {
+	var rounded *big.Rat
+	switch p.Mode {
+	case RoundFloor:
+		rounded = ratFloor(points)
+	case RoundHalfUp:
+		rounded = ratRound(points)
+	case RoundHalfEven:
+		rounded = ratRoundHalfEven(points)
+	default:
+		rounded = ratCeil(points)
+	}
+	return int(rounded.Num().Int64())
+}
+
+// Describe the rounding for explanations, e.g. "rounded up"
+func (p RoundingPolicy) describe() string {
+	switch p.Mode {
+	case RoundFloor:
+		return "rounded down"
+	case RoundHalfUp:
+		return "rounded half up"
+	case RoundHalfEven:
+		return "rounded half to even"
+	}
+	return "rounded up"
+}
+
+// Round down to a whole number
+func ratFloor(r *big.Rat) *big.Rat {
+	// Euclidean division rounds down when the divisor is positive, which the
+	// denominator always is
+	return new(big.Rat).SetInt(new(big.Int).Div(r.Num(), r.Denom()))
+}
+
+// Round up to a whole number
+func ratCeil(r *big.Rat) *big.Rat {
+	return new(big.Rat).Neg(ratFloor(new(big.Rat).Neg(r)))
+}
+
+// Round to the nearest whole number, halves away from zero
+func ratRound(r *big.Rat) *big.Rat {
+	half := big.NewRat(1, 2)
+	if r.Sign() < 0 {
+		return ratCeil(new(big.Rat).Sub(r, half))
+	}
+	return ratFloor(new(big.Rat).Add(r, half))
+}
+
+// Round to the nearest whole number, halves to the even number
+func ratRoundHalfEven(r *big.Rat) *big.Rat {
+	floor := ratFloor(r)
+	switch new(big.Rat).Sub(r, floor).Cmp(big.NewRat(1, 2)) {
+	case -1:
+		return floor
+	case 1:
+		return new(big.Rat).Add(floor, big.NewRat(1, 1))
+	}
+
+	if new(big.Int).Rem(floor.Num(), big.NewInt(2)).Sign() == 0 {
+		return floor
+	}
+	return new(big.Rat).Add(floor, big.NewRat(1, 1))
+}
+
+// Round the fractional points of the items of a rule, each on its own or
+// their sum once depending on the scope. Fills in the points of every item
+// and the end of its reason, and returns the points of the rule along with
+// the end of its reason.
+func (p RoundingPolicy) roundItems(items []PointRuleItem, values []*big.Rat) (int, string) {
+	if !p.roundsTotal() {
+		total := 0
+		for i := range items {
+			items[i].Points = p.round(values[i])
+			items[i].Reason += fmt.Sprintf(", %s is %d points", p.describe(), items[i].Points)
+			total += items[i].Points
+		}
+		return total, ""
+	}
+
+	sum := new(big.Rat)
+	for i := range items {
+		items[i].Points = 0
+		items[i].Reason += ", rounded together with the other items"
+		sum.Add(sum, values[i])
+	}
+	points := p.round(sum)
+	return points, fmt.Sprintf(", together worth %s, %s is %d points", sum.FloatString(2), p.describe(), points)
+}
@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestRoundingPolicyRound(t *testing.T) {
+	testTable := []struct {
+		mode     string
+		value    *big.Rat
+		expected int
+	}{
+		{"", big.NewRat(21, 10), 3},
+		{RoundCeil, big.NewRat(-21, 10), -2},
+		{RoundCeil, big.NewRat(3, 1), 3},
+		{RoundFloor, big.NewRat(29, 10), 2},
+		{RoundFloor, big.NewRat(-21, 10), -3},
+		{RoundHalfUp, big.NewRat(5, 2), 3},
+		{RoundHalfUp, big.NewRat(-5, 2), -3},
+		{RoundHalfUp, big.NewRat(249, 100), 2},
+		{RoundHalfEven, big.NewRat(5, 2), 2},
+		{RoundHalfEven, big.NewRat(7, 2), 4},
+		{RoundHalfEven, big.NewRat(-5, 2), -2},
+		{RoundHalfEven, big.NewRat(251, 100), 3},
+	}
+
+	for _, test := range testTable {
+		policy := RoundingPolicy{Mode: test.mode}
+		if points := policy.round(test.value); points != test.expected {
+			t.Errorf("round(%s) with mode %q = got %d, wanted %d", test.value.FloatString(2), test.mode, points, test.expected)
+		}
+	}
+}
+
+func TestRoundingPolicyScope(t *testing.T) {
+	// The item descriptions of the Target receipt are worth 2.45 and 2.40
+	testTable := []struct {
+		policy   RoundingPolicy
+		expected int
+	}{
+		{RoundingPolicy{}, 6},
+		{RoundingPolicy{Mode: RoundFloor, Scope: RoundPerItem}, 4},
+		{RoundingPolicy{Mode: RoundHalfUp}, 4},
+		{RoundingPolicy{Scope: RoundTotal}, 5},
+		{RoundingPolicy{Mode: RoundFloor, Scope: RoundTotal}, 4},
+		{RoundingPolicy{Mode: RoundHalfUp, Scope: RoundTotal}, 5},
+		{RoundingPolicy{Mode: RoundHalfEven, Scope: RoundTotal}, 5},
+	}
+
+	rule := &ItemDescriptionRule{LengthMultiple: 3, PriceMultiplier: 0.2}
+	for _, test := range testTable {
+		result, err := rule.EvaluateContext(targetReceipt, EvalContext{Rounding: test.policy})
+		if err != nil {
+			t.Fatalf("EvaluateContext got an error: %q", err.Error())
+		}
+		if result.Points != test.expected {
+			t.Errorf("item_description with %+v = got %d, wanted %d", test.policy, result.Points, test.expected)
+		}
+
+		// The breakdown has to add up to the points
+		sum := 0
+		for _, item := range result.Items {
+			sum += item.Points
+		}
+		if test.policy.roundsTotal() {
+			if sum != 0 || !strings.Contains(result.Reason, "together worth 4.85") {
+				t.Errorf("item_description with %+v = got items worth %d and reason %q, wanted 0 and the sum in the reason", test.policy, sum, result.Reason)
+			}
+		} else if sum != result.Points {
+			t.Errorf("item_description with %+v = got items worth %d, wanted %d", test.policy, sum, result.Points)
+		}
+	}
+}
+
+func TestRulesetRounding(t *testing.T) {
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte("rounding:\n  mode: floor\n  scope: total\nrules:\n  - type: item_description\n  - type: expression\n    params:\n      expression: total / 10\n"), "rounding.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	score, err := ruleset.Calculate(targetReceipt)
+	if err != nil {
+		t.Fatalf("Calculate got an error: %q", err.Error())
+	}
+	// 4.85 rounded down, then 3.535 rounded down
+	if score.Total != 7 {
+		t.Errorf("Calculate with floor rounding = got %d, wanted %d", score.Total, 7)
+	}
+
+	if err := ruleset.SetRounding(RoundingPolicy{Mode: "nearest"}); err == nil {
+		t.Errorf("SetRounding with an unknown mode = got no error, wanted one")
+	}
+}
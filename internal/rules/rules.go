@@ -1,36 +1,34 @@
 package rules
 
 import (
-	"errors"
 	"fmt"
 	"log"
 	"math"
+	"math/big"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
 )
 
-// Stores the result of the point calculation of every item in a receipt
+// Stores the result of the point calculation of an item in a receipt
 type PointRuleItem struct {
-	Description       string
-	Price             string
-	DescriptionLength int
-	Value             float64
-}
-
-// Stores the calculated results for a point calculation for a receipt
-type PointRules struct {
-	AlphanumericPoints int
-	RoundDollarPoints  int
-	MultiplierPoints   int
-	GroupingPoints     int
-	GroupingAmount     int
-	PurchaseDatePoints int
-	PurchaseTimePoints int
-	RuleItems          []PointRuleItem
+	// The item's description as printed on the receipt
+	Description string `json:"description"`
+	// The item's price
+	Price models.Money `json:"price" swaggertype:"string" example:"12.25"`
+	// The trimmed length of the description
+	DescriptionLength int `json:"descriptionLength"`
+	// The fractional points before rounding
+	Value float64 `json:"value"`
+	// The points the item contributed
+	Points int `json:"points"`
+	// A human readable explanation of the points
+	Reason string `json:"reason"`
+	// How a cap changed the points of the item, missing if none did
+	Adjustment *PointAdjustment `json:"adjustment,omitempty"`
 }
 
 // Calculates the alphanumeric length of a string
@@ -44,39 +42,21 @@ func alphanumericLength(str string) int {
 	return len(newStr)
 }
 
-// Tests to see if the total in the string is a round number
+// Tests to see if the total is a round number
 // 10.00 = true, 10.25 = false
-func isTotalRound(str string) bool {
-	amount, error := strconv.ParseFloat(str, 64)
-
-	if error != nil {
-		return false
-	}
-
-	// Take the amount minus the double casted amount int -> float and if there's any left
-	// Then it's not a round dollar amount
-	if amount-float64(int64(amount)) > 0.0 {
-		return false
-	}
-
-	return true
+func isTotalRound(total models.Money) bool {
+	// Any cents left over means it's not a round dollar amount
+	return total.Cents()%100 == 0
 }
 
-// Checks to see if the total is a multiplier of the given multiplier
-// 8.00 is a multiplier of 0.25 but not of 0.75
-func isTotalAMultiplier(str string, multiplier float64) bool {
-	amount, error := strconv.ParseFloat(str, 64)
-
-	if error != nil {
+// Checks to see if the total is a multiple of the given amount
+// 8.00 is a multiple of 0.25 but not of 0.75
+func isTotalAMultiplier(total models.Money, multiple models.Money) bool {
+	if multiple.Cents() <= 0 {
 		return false
 	}
 
-	// Can't use % here for floats - need to use the math.Mod function
-	if math.Mod(amount, multiplier) == 0 {
-		return true
-	}
-
-	return false
+	return total.Cents()%multiple.Cents() == 0
 }
 
 // Returns the number of groups that can be formed from a list of items
@@ -93,20 +73,42 @@ func itemsLengthGrouping(items []models.Item, grouping int) int {
 	return numberOfGroups
 }
 
+// Points with a fractional part, kept as a whole number of ten-thousandths
+// of a point. A price in cents times a multiplier in hundredths lands
+// exactly on a ten-thousandth, so no precision is lost before rounding.
+type fractionalPoints int64
+
+const fractionalPointsPerPoint = 10000
+
+// The exact points, to be rounded by a rounding policy
+func (p fractionalPoints) rat() *big.Rat {
+	return big.NewRat(int64(p), fractionalPointsPerPoint)
+}
+
+// The points as a float, for display only
+func (p fractionalPoints) Float() float64 {
+	return float64(p) / fractionalPointsPerPoint
+}
+
+// Converts a multiplier such as 0.2 into a whole number of hundredths,
+// multipliers with more than two decimal places are rejected
+func multiplierHundredths(multiplier float64) (int64, error) {
+	hundredths := math.Round(multiplier * 100)
+	if math.Abs(multiplier*100-hundredths) > 1e-9 {
+		return 0, fmt.Errorf("%g has more than two decimal places", multiplier)
+	}
+	return int64(hundredths), nil
+}
+
 // Determines the trimmed length of an items description, and the point value
-// based on if the length is divisible by three
-func itemDescriptionPricePoints(item models.Item) (int, float64) {
+// based on if the length is divisible by lengthMultiple. The point value is
+// the price times the multiplier, which is given in hundredths (0.2 is 20).
+func itemDescriptionPricePoints(item models.Item, lengthMultiple int, priceMultiplier int64) (int, fractionalPoints) {
 	newStr := strings.Trim(item.ShortDescription, " ")
 	length := len(newStr)
 
-	if length%3 == 0 {
-		amount, error := strconv.ParseFloat(item.Price, 64)
-
-		if error != nil {
-			return length, 0
-		}
-
-		value := amount * 0.2
+	if lengthMultiple > 0 && length%lengthMultiple == 0 {
+		value := fractionalPoints(item.Price.Cents() * priceMultiplier)
 		return length, value
 	}
 	return length, 0
@@ -130,131 +132,107 @@ func oddPurchaseDate(str string) bool {
 	return false
 }
 
-// Check to see if the purchase time is between 2pm and 4pm
-// Input is assumed to be in 24 hour format e.g. 15:40
-func checkPurchaseTime(str string) (bool, error) {
-	var hour int
-	var minute int
-
-	// Parsing the string into a hh:mm format
-	n, err := fmt.Sscanf(str, "%d:%d", &hour, &minute)
+// Parses a clock time e.g. 15:40 or 3:40 PM into the minutes after midnight
+func parseClock(str string) (int, error) {
+	// The end of the day, so a window can run until midnight
+	if strings.TrimSpace(str) == "24:00" {
+		return 24 * 60, nil
+	}
 
-	// If the scanning produced an error
+	clock, _, err := models.NormalizeTime(str, nil)
 	if err != nil {
-		return false, errors.New("Could not scan time")
+		return 0, err
 	}
 
-	// If the parsed time is somehow not a 24 hour clock
-	if hour > 24 || hour < 0 {
-		return false, errors.New("Invalid Hour format")
+	parsed, err := time.Parse(models.ClockLayout, clock)
+	if err != nil {
+		return 0, err
 	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
 
-	// If the parsed minutes is not in minutes
-	if minute > 59 || minute < 0 {
-		return false, errors.New("Invalid Minute format")
+// Check to see if the purchase time is strictly between the after and before
+// times, given in minutes after midnight (2pm and 4pm is 840 and 960). The
+// time is read like any purchase time, e.g. 15:40 or 3:40 PM, and should
+// already be in the zone the window is for.
+func checkPurchaseTime(str string, after int, before int) (bool, error) {
+	minutes, err := parseClock(str)
+	if err != nil {
+		return false, err
 	}
 
-	// If we parsed data and:
-	// The hour is greater than 2pm
-	// The hour is 2pm, and the minute is at least 1 or more
-	// The hour is less than 6pm
-	if n > 0 && (hour > 14 || hour == 14 && minute >= 1) && hour < 16 {
+	// If the time is after the start of the window and before the end of it
+	if minutes > after && minutes < before {
 		return true, nil
 	}
 
 	return false, nil
 }
 
-// Given a receipt, calculate the amount of points it's worth based on
-// a series of rules
-func CalculatePoints(rec models.Receipt) (int, error) {
-	var rulePoints PointRules
-	currentPoints := 0
-
-	// One point for every alphanumeric character in the retailer name.
-	rulePoints.AlphanumericPoints = alphanumericLength(rec.Retailer)
-	currentPoints += rulePoints.AlphanumericPoints
+// The ruleset used by CalculatePoints. It is swapped as a whole, a ruleset
+// must not be changed once it has been made active.
+var activeRuleset atomic.Pointer[Ruleset]
 
-	// 50 points if the total is a round dollar amount with no cents.
-	if isTotalRound(rec.Total) {
-		rulePoints.RoundDollarPoints = 50
-		currentPoints += rulePoints.RoundDollarPoints
-	}
+func init() {
+	SetRuleset(DefaultRuleset())
+}
 
-	// 5 points if the total is a multiple of 0.25
-	if isTotalAMultiplier(rec.Total, 0.25) {
-		rulePoints.MultiplierPoints = 25
-		currentPoints += rulePoints.MultiplierPoints
-	}
-
-	// 5 points for every two items on the receipt.
-	rulePoints.GroupingAmount = itemsLengthGrouping(rec.Items, 2)
-	rulePoints.GroupingPoints = rulePoints.GroupingAmount * 5
-	currentPoints += rulePoints.GroupingPoints
-
-	// If the trimmed length of the item description is a multiple of 3,
-	// multiply the price by 0.2 and round up to the nearest integer.
-	// The result is the number of points earned.
-	for _, item := range rec.Items {
-		descrLength, value := itemDescriptionPricePoints(item)
-		if value > 0 {
-			rulePoints.RuleItems = append(rulePoints.RuleItems, PointRuleItem{Price: item.Price, Description: item.ShortDescription, DescriptionLength: descrLength, Value: value})
-			currentPoints += int(math.Ceil(value))
-		}
+// Replace the ruleset used by CalculatePoints. Calculations that are
+// already running finish with the ruleset they started with. A versioned
+// ruleset stays available by its version after it is replaced. Returns an
+// error and keeps the current ruleset if the version is taken by other rules.
+func SetRuleset(ruleset *Ruleset) error {
+	if err := RegisterRuleset(ruleset); err != nil {
+		return err
 	}
+	activeRuleset.Store(ruleset)
+	return nil
+}
 
-	// 6 points if the day in the purchase date is odd.
-	if oddPurchaseDate(rec.PurchaseDate) {
-		rulePoints.PurchaseDatePoints = 6
-		currentPoints += rulePoints.PurchaseDatePoints
-	}
+// Returns the ruleset used by CalculatePoints
+func ActiveRuleset() *Ruleset {
+	return activeRuleset.Load()
+}
 
-	// 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-	checkedTime, err := checkPurchaseTime(rec.PurchaseTime)
+// Given a receipt, calculate the amount of points it's worth based on
+// the active ruleset
+func CalculatePoints(rec models.Receipt) (int, error) {
+	score, err := CalculateBreakdown(rec)
 	if err != nil {
 		return 0, err
 	}
 
-	if checkedTime {
-		rulePoints.PurchaseTimePoints = 10
-		currentPoints += rulePoints.PurchaseTimePoints
+	return score.Total, nil
+}
+
+// Given a receipt, calculate the points it's worth under the active ruleset
+// along with the points and reason of every rule
+func CalculateBreakdown(rec models.Receipt) (Score, error) {
+	score, err := ActiveRuleset().Calculate(rec)
+	if err != nil {
+		return Score{}, err
 	}
 
 	// Breakdown output in console
-	showBreakdown(rulePoints, currentPoints, rec)
+	showBreakdown(score, rec)
 
-	return currentPoints, nil
+	return score, nil
 }
 
 // Log the results of the point calculation
-func showBreakdown(rulePoints PointRules, totalPoints int, rec models.Receipt) {
+func showBreakdown(score Score, rec models.Receipt) {
 	log.Printf("Breakdown for Receipt ID (%q):", rec.ID)
-	if rulePoints.AlphanumericPoints > 0 {
-		log.Printf("%6d points - Retailer name has %d alphanumeric characters \n", rulePoints.AlphanumericPoints, rulePoints.AlphanumericPoints)
-	}
-	if rulePoints.RoundDollarPoints > 0 {
-		log.Printf("%6d points - Total is a round dollar amount \n", rulePoints.RoundDollarPoints)
-	}
-	if rulePoints.MultiplierPoints > 0 {
-		log.Printf("%6d points - Total is multiple of 0.25 \n", rulePoints.MultiplierPoints)
-	}
-	if rulePoints.GroupingPoints > 0 {
-		log.Printf("%6d points - %d items (%d pairs @ %d points each) \n", rulePoints.GroupingPoints, len(rec.Items), rulePoints.GroupingAmount, 5)
-	}
-	// Then loop through our rule items
-	for _, ruleItem := range rulePoints.RuleItems {
-		roundedPoints := int(math.Ceil(ruleItem.Value))
-		if roundedPoints > 0 {
-			log.Printf("%6d points - %q is %d characters (a multiple of 3)\n", roundedPoints, ruleItem.Description, ruleItem.DescriptionLength)
-			log.Printf("%s item price of %q * 0.2 = %.2f, rounded up is %d points \n", strings.Repeat(" ", 16), ruleItem.Price, ruleItem.Value, roundedPoints)
+	for _, result := range score.Results {
+		if result.Points <= 0 {
+			continue
+		}
+
+		log.Printf("%6d points - %s \n", result.Points, result.Reason)
+		// Then loop through the items that contributed to the rule
+		for _, ruleItem := range result.Items {
+			log.Printf("%s %s \n", strings.Repeat(" ", 16), ruleItem.Reason)
 		}
-	}
-	if rulePoints.PurchaseDatePoints > 0 {
-		log.Printf("%6d points - Purchase day is odd \n", rulePoints.PurchaseDatePoints)
-	}
-	if rulePoints.PurchaseTimePoints > 0 {
-		log.Printf("%6d points - %q is between 2:00pm and 4:00pm \n", rulePoints.PurchaseTimePoints, rec.PurchaseTime)
 	}
 	log.Println("+ -------")
-	log.Printf("= %d points", totalPoints)
+	log.Printf("= %d points", score.Total)
 }
@@ -20,7 +20,7 @@ type IsTotalRoundStruct struct {
 
 type IsTotalAMultiplierStruct struct {
 	arg1     string
-	arg2     float64
+	arg2     string
 	expected bool
 }
 
@@ -77,7 +77,7 @@ func TestIsTotalRound(t *testing.T) {
 	}
 
 	for _, test := range testTable {
-		if output := isTotalRound(test.arg1); output != test.expected {
+		if output := isTotalRound(models.MustParseMoney(test.arg1)); output != test.expected {
 			t.Errorf("isTotalRound(%q) = got %t, wanted %t", test.arg1, output, test.expected)
 		}
 	}
@@ -85,16 +85,19 @@ func TestIsTotalRound(t *testing.T) {
 
 func TestIsTotalAMultiplier(t *testing.T) {
 	testTable := []IsTotalAMultiplierStruct{
-		{"10.00", 0.25, true},
-		{"5.04", 0.25, false},
-		{"2.75", 0.25, true},
-		{"7400.00", 0.25, true},
-		{"9.01", 0.25, false},
+		{"10.00", "0.25", true},
+		{"5.04", "0.25", false},
+		{"2.75", "0.25", true},
+		{"7400.00", "0.25", true},
+		{"9.01", "0.25", false},
+		{"0.30", "0.10", true},
+		{"0.70", "0.10", true},
+		{"4.00", "0.00", false},
 	}
 
 	for _, test := range testTable {
-		if output := isTotalAMultiplier(test.arg1, test.arg2); output != test.expected {
-			t.Errorf("isTotalAMultiplier(%q, %f) = got %t, wanted %t", test.arg1, test.arg2, output, test.expected)
+		if output := isTotalAMultiplier(models.MustParseMoney(test.arg1), models.MustParseMoney(test.arg2)); output != test.expected {
+			t.Errorf("isTotalAMultiplier(%q, %q) = got %t, wanted %t", test.arg1, test.arg2, output, test.expected)
 		}
 	}
 }
@@ -104,22 +107,22 @@ func TestItemsLengthGrouping(t *testing.T) {
 		{nil, 2, 0},
 		{[]models.Item{}, 2, 0},
 		{[]models.Item{
-			{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
-			{ShortDescription: "Dasani", Price: "1.40"},
+			{ShortDescription: "Pepsi - 12-oz", Price: models.MustParseMoney("1.25")},
+			{ShortDescription: "Dasani", Price: models.MustParseMoney("1.40")},
 		}, 2, 1},
 		{[]models.Item{
-			{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+			{ShortDescription: "Pepsi - 12-oz", Price: models.MustParseMoney("1.25")},
 		}, 2, 0},
 		{[]models.Item{
-			{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
-			{ShortDescription: "Dasani", Price: "1.40"},
-			{ShortDescription: "Mike & Ikes", Price: "1.15"},
-			{ShortDescription: "Snickers Ice Cream Bar", Price: "2.25"},
+			{ShortDescription: "Pepsi - 12-oz", Price: models.MustParseMoney("1.25")},
+			{ShortDescription: "Dasani", Price: models.MustParseMoney("1.40")},
+			{ShortDescription: "Mike & Ikes", Price: models.MustParseMoney("1.15")},
+			{ShortDescription: "Snickers Ice Cream Bar", Price: models.MustParseMoney("2.25")},
 		}, 2, 2},
 		{[]models.Item{
-			{ShortDescription: "Vitamin Water", Price: "1.99"},
-			{ShortDescription: "Mike & Ikes", Price: "1.15"},
-			{ShortDescription: "Snickers Ice Cream Bar", Price: "2.25"},
+			{ShortDescription: "Vitamin Water", Price: models.MustParseMoney("1.99")},
+			{ShortDescription: "Mike & Ikes", Price: models.MustParseMoney("1.15")},
+			{ShortDescription: "Snickers Ice Cream Bar", Price: models.MustParseMoney("2.25")},
 		}, 2, 1},
 	}
 
@@ -132,20 +135,20 @@ func TestItemsLengthGrouping(t *testing.T) {
 
 func TestItemDescriptionPricePoints(t *testing.T) {
 	testTable := []ItemDescriptionPricePointsStruct{
-		{models.Item{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}, 13, 0},
-		{models.Item{ShortDescription: "Target", Price: "1.25"}, 6, 0.25},
-		{models.Item{ShortDescription: "  Pez  ", Price: "1.00"}, 3, 0.20},
+		{models.Item{ShortDescription: "Pepsi - 12-oz", Price: models.MustParseMoney("1.25")}, 13, 0},
+		{models.Item{ShortDescription: "Target", Price: models.MustParseMoney("1.25")}, 6, 0.25},
+		{models.Item{ShortDescription: "  Pez  ", Price: models.MustParseMoney("1.00")}, 3, 0.20},
 	}
 
 	for _, test := range testTable {
-		outputLength, outputValue := itemDescriptionPricePoints(test.arg1)
+		outputLength, outputValue := itemDescriptionPricePoints(test.arg1, 3, 20)
 
 		if outputLength != test.expectedLength {
 			t.Errorf("itemDescriptionPricePoints(%q) = got %d length, wanted %d length", test.arg1.ShortDescription, outputLength, test.expectedLength)
 		}
 
-		if outputValue != test.expectedValue {
-			t.Errorf("itemDescriptionPricePoints(%q) = got %f value, wanted %f value", test.arg1.ShortDescription, outputValue, test.expectedValue)
+		if outputValue.Float() != test.expectedValue {
+			t.Errorf("itemDescriptionPricePoints(%q) = got %f value, wanted %f value", test.arg1.ShortDescription, outputValue.Float(), test.expectedValue)
 		}
 	}
 }
@@ -165,6 +168,30 @@ func TestOddPurchaseDate(t *testing.T) {
 	}
 }
 
+func TestParseClock(t *testing.T) {
+	testTable := []struct {
+		clock    string
+		expected int
+	}{
+		{"14:00", 14 * 60},
+		{"2:00 PM", 14 * 60},
+		{"09:30:15", 9*60 + 30},
+		{"12:00 AM", 0},
+		{"24:00", 24 * 60},
+	}
+
+	for _, test := range testTable {
+		minutes, err := parseClock(test.clock)
+		if err != nil {
+			t.Errorf("parseClock(%q) got an error: %q", test.clock, err.Error())
+			continue
+		}
+		if minutes != test.expected {
+			t.Errorf("parseClock(%q) = got %d, wanted %d", test.clock, minutes, test.expected)
+		}
+	}
+}
+
 func TestCheckPurchaseTime(t *testing.T) {
 	testTable := []CheckPurchaseTimeStruct{
 		{"11:15", false, nil},
@@ -182,7 +209,7 @@ func TestCheckPurchaseTime(t *testing.T) {
 	}
 
 	for _, test := range testTable {
-		output, err := checkPurchaseTime(test.arg1)
+		output, err := checkPurchaseTime(test.arg1, 14*60, 16*60)
 		if output != test.expected {
 			t.Errorf("checkPurchaseTime(%q) = got %t, wanted %t", test.arg1, output, test.expected)
 		}
@@ -0,0 +1,258 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// A rule awards points to a receipt for one reason
+type Rule interface {
+	// The unique name of the rule within a ruleset
+	Name() string
+	// Calculate the points the receipt earns under this rule
+	Evaluate(rec models.Receipt) (RuleResult, error)
+}
+
+// What a rule is evaluated with besides the receipt
+type EvalContext struct {
+	// The points awarded by the rules evaluated before it
+	Subtotal int
+	// How the ruleset rounds fractional points
+	Rounding RoundingPolicy
+	// The zone the receipt's purchase date and time are in, nil when not known
+	Location *time.Location
+}
+
+// A rule that needs more than the receipt to work out its points
+type contextRule interface {
+	Rule
+	// Calculate the points the receipt earns under this rule
+	EvaluateContext(rec models.Receipt, ctx EvalContext) (RuleResult, error)
+}
+
+// Evaluate the rule, passing it the context if it wants it
+func evaluateRule(rule Rule, rec models.Receipt, ctx EvalContext) (RuleResult, error) {
+	if r, ok := rule.(contextRule); ok {
+		return r.EvaluateContext(rec, ctx)
+	}
+	return rule.Evaluate(rec)
+}
+
+// The outcome of evaluating a single rule against a receipt
+type RuleResult struct {
+	// The name of the rule that produced this result
+	Name string `json:"name"`
+	// The points awarded by the rule
+	Points int `json:"points"`
+	// A human readable explanation of the points
+	Reason string `json:"reason"`
+	// The contribution of each item, for rules that score items
+	Items []PointRuleItem `json:"items,omitempty"`
+	// How a cap or minimum changed the points, missing if none did
+	Adjustment *PointAdjustment `json:"adjustment,omitempty"`
+}
+
+// The points a receipt earned under a ruleset, and why
+type Score struct {
+	// The sum of the points of every rule
+	Total int `json:"total"`
+	// The result of every rule in the order they were evaluated
+	Results []RuleResult `json:"rules"`
+}
+
+// An ordered list of rules that are evaluated together to score a receipt
+type Ruleset struct {
+	rules   []Rule
+	version string
+	// The least and most points a receipt can be awarded
+	limits pointLimits
+	// How fractional points are rounded
+	rounding RoundingPolicy
+	// The time zones of receipts that don't give their own
+	zones timeZones
+	// A hash of the document the ruleset was parsed from, empty for rulesets
+	// built in code
+	digest string
+}
+
+// Create a ruleset that evaluates the rules in the given order
+func NewRuleset(rules ...Rule) *Ruleset {
+	return &Ruleset{rules: append([]Rule(nil), rules...)}
+}
+
+// Returns the version of the ruleset, empty if it doesn't have one
+func (rs *Ruleset) Version() string {
+	return rs.version
+}
+
+// Name the version of the ruleset. Scores record the version of the ruleset
+// that produced them.
+func (rs *Ruleset) SetVersion(version string) {
+	rs.version = version
+}
+
+// Limit the points of every receipt, nil leaves that end open. The rule
+// points are added up first, then kept within the limits.
+func (rs *Ruleset) SetPointLimits(minPoints *int, maxPoints *int) error {
+	limits := pointLimits{min: minPoints, max: maxPoints}
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	rs.limits = limits
+	return nil
+}
+
+// Returns how the ruleset rounds fractional points
+func (rs *Ruleset) Rounding() RoundingPolicy {
+	return rs.rounding
+}
+
+// Change how the ruleset rounds fractional points
+func (rs *Ruleset) SetRounding(policy RoundingPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	rs.rounding = policy
+	return nil
+}
+
+// Returns the rules in evaluation order
+func (rs *Ruleset) Rules() []Rule {
+	return append([]Rule(nil), rs.rules...)
+}
+
+// Append a rule to the end of the ruleset
+func (rs *Ruleset) Add(rule Rule) error {
+	if rs.indexOf(rule.Name()) >= 0 {
+		return fmt.Errorf("rule %q is already in the ruleset", rule.Name())
+	}
+	rs.rules = append(rs.rules, rule)
+	return nil
+}
+
+// Remove the named rule, returns false if it wasn't in the ruleset
+func (rs *Ruleset) Remove(name string) bool {
+	i := rs.indexOf(name)
+	if i < 0 {
+		return false
+	}
+	rs.rules = append(rs.rules[:i], rs.rules[i+1:]...)
+	return true
+}
+
+// Move the named rule so it is evaluated at the given position
+func (rs *Ruleset) Move(name string, position int) error {
+	i := rs.indexOf(name)
+	if i < 0 {
+		return fmt.Errorf("rule %q is not in the ruleset", name)
+	}
+	if position < 0 || position >= len(rs.rules) {
+		return fmt.Errorf("position %d is out of range", position)
+	}
+
+	rule := rs.rules[i]
+	rs.rules = append(rs.rules[:i], rs.rules[i+1:]...)
+	rs.rules = append(rs.rules[:position], append([]Rule{rule}, rs.rules[position:]...)...)
+	return nil
+}
+
+// Evaluate every rule against the receipt and add up the points
+func (rs *Ruleset) Calculate(rec models.Receipt) (Score, error) {
+	var score Score
+
+	location, err := rs.zones.locate(rec)
+	if err != nil {
+		return Score{}, err
+	}
+
+	for _, rule := range rs.rules {
+		result, err := evaluateRule(rule, rec, EvalContext{Subtotal: score.Total, Rounding: rs.rounding, Location: location})
+		if err != nil {
+			return Score{}, err
+		}
+
+		result.Name = rule.Name()
+		score.Results = append(score.Results, result)
+		score.Total += result.Points
+	}
+
+	// A receipt level adjustment is a line of its own, so the lines still
+	// add up to the total
+	if total, adjustment := rs.limits.apply(score.Total); adjustment != nil {
+		name := ReceiptCapRuleName
+		if adjustment.Kind == AdjustmentMinimum {
+			name = ReceiptMinimumRuleName
+		}
+		score.Results = append(score.Results, RuleResult{
+			Name:       name,
+			Points:     adjustment.Change,
+			Reason:     "Receipt points " + adjustment.describe(),
+			Adjustment: adjustment,
+		})
+		score.Total = total
+	}
+
+	return score, nil
+}
+
+// Find the position of the named rule, -1 if it isn't in the ruleset
+func (rs *Ruleset) indexOf(name string) int {
+	for i, rule := range rs.rules {
+		if rule.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// A registry knows how to build every available type of rule
+type Registry struct {
+	factories map[string]func() Rule
+}
+
+// Create an empty registry
+func NewRegistry() *Registry {
+	return &Registry{factories: map[string]func() Rule{}}
+}
+
+// Make a rule type available to rulesets built by this registry
+func (r *Registry) Register(ruleType string, factory func() Rule) {
+	r.factories[ruleType] = factory
+}
+
+// The names of every registered rule type, sorted
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.factories))
+	for ruleType := range r.factories {
+		types = append(types, ruleType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build a single rule of the given type
+func (r *Registry) New(ruleType string) (Rule, error) {
+	factory, ok := r.factories[ruleType]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule type %q", ruleType)
+	}
+	return factory(), nil
+}
+
+// Build a ruleset out of the given rule types, evaluated in that order
+func (r *Registry) Build(ruleTypes ...string) (*Ruleset, error) {
+	ruleset := NewRuleset()
+	for _, ruleType := range ruleTypes {
+		rule, err := r.New(ruleType)
+		if err != nil {
+			return nil, err
+		}
+		if err := ruleset.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return ruleset, nil
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The example receipts from the receipt processor challenge
+var targetReceipt = models.Receipt{
+	Retailer:     "Target",
+	PurchaseDate: "2022-01-01",
+	PurchaseTime: "13:01",
+	Items: []models.Item{
+		{ShortDescription: "Mountain Dew 12PK", Price: models.MustParseMoney("6.49")},
+		{ShortDescription: "Emils Cheese Pizza", Price: models.MustParseMoney("12.25")},
+		{ShortDescription: "Knorr Creamy Chicken", Price: models.MustParseMoney("1.26")},
+		{ShortDescription: "Doritos Nacho Cheese", Price: models.MustParseMoney("3.35")},
+		{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: models.MustParseMoney("12.00")},
+	},
+	Total: models.MustParseMoney("35.35"),
+}
+
+var cornerMarketReceipt = models.Receipt{
+	Retailer:     "M&M Corner Market",
+	PurchaseDate: "2022-03-20",
+	PurchaseTime: "14:33",
+	Items: []models.Item{
+		{ShortDescription: "Gatorade", Price: models.MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: models.MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: models.MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: models.MustParseMoney("2.25")},
+	},
+	Total: models.MustParseMoney("9.00"),
+}
+
+// Names of the rules in a ruleset, in order
+func ruleNames(ruleset *Ruleset) []string {
+	var names []string
+	for _, rule := range ruleset.Rules() {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
+func TestDefaultRulesetCalculate(t *testing.T) {
+	testTable := []struct {
+		receipt  models.Receipt
+		expected int
+	}{
+		{targetReceipt, 28},
+		{cornerMarketReceipt, 109},
+	}
+
+	for _, test := range testTable {
+		score, err := DefaultRuleset().Calculate(test.receipt)
+		if err != nil {
+			t.Fatalf("Calculate(%q) got an error: %q", test.receipt.Retailer, err.Error())
+		}
+
+		if score.Total != test.expected {
+			t.Errorf("Calculate(%q) = got %d points, wanted %d", test.receipt.Retailer, score.Total, test.expected)
+		}
+
+		if len(score.Results) != len(DefaultRuleTypes) {
+			t.Errorf("Calculate(%q) = got %d results, wanted one per rule", test.receipt.Retailer, len(score.Results))
+		}
+	}
+}
+
+func TestRulesetAddRemoveMove(t *testing.T) {
+	ruleset := DefaultRuleset()
+
+	if !ruleset.Remove(PurchaseTimeRuleType) {
+		t.Errorf("Remove(%q) = got false, wanted true", PurchaseTimeRuleType)
+	}
+	if ruleset.Remove(PurchaseTimeRuleType) {
+		t.Errorf("Remove(%q) twice = got true, wanted false", PurchaseTimeRuleType)
+	}
+
+	// Without the time rule the corner market receipt loses its 10 points
+	score, _ := ruleset.Calculate(cornerMarketReceipt)
+	if score.Total != 99 {
+		t.Errorf("Calculate without %q = got %d points, wanted %d", PurchaseTimeRuleType, score.Total, 99)
+	}
+
+	if err := ruleset.Add(&OddPurchaseDayRule{Points: 6}); err == nil {
+		t.Errorf("Add of a rule that is already in the ruleset should return an error")
+	}
+
+	if err := ruleset.Move(OddPurchaseDayRuleType, 0); err != nil {
+		t.Fatalf("Move got an error: %q", err.Error())
+	}
+
+	expected := []string{
+		OddPurchaseDayRuleType,
+		RetailerAlphanumericRuleType,
+		RoundDollarTotalRuleType,
+		TotalMultipleRuleType,
+		ItemPairsRuleType,
+		ItemDescriptionRuleType,
+	}
+	if names := ruleNames(ruleset); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Rules after Move = got %v, wanted %v", names, expected)
+	}
+
+	if err := ruleset.Move("missing", 0); err == nil {
+		t.Errorf("Move of a missing rule should return an error")
+	}
+	if err := ruleset.Move(OddPurchaseDayRuleType, 10); err == nil {
+		t.Errorf("Move to an out of range position should return an error")
+	}
+}
+
+func TestRegistryBuild(t *testing.T) {
+	ruleset, err := DefaultRegistry.Build(ItemPairsRuleType, RetailerAlphanumericRuleType)
+	if err != nil {
+		t.Fatalf("Build got an error: %q", err.Error())
+	}
+
+	expected := []string{ItemPairsRuleType, RetailerAlphanumericRuleType}
+	if names := ruleNames(ruleset); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Build = got rules %v, wanted %v", names, expected)
+	}
+
+	if _, err := DefaultRegistry.Build("not_a_rule"); err == nil {
+		t.Errorf("Build of an unknown rule type should return an error")
+	}
+
+	if _, err := DefaultRegistry.Build(ItemPairsRuleType, ItemPairsRuleType); err == nil {
+		t.Errorf("Build with the same rule twice should return an error")
+	}
+}
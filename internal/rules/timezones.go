@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+// The time zones of the receipts scored by a ruleset, for receipts that
+// don't give their own
+type timeZones struct {
+	// The zone of receipts from retailers without one, nil when not known
+	defaultZone *time.Location
+	// The zones of retailers by their lower cased name
+	retailers map[string]*time.Location
+}
+
+// The zone the receipt's purchase date and time are in: its own zone, then
+// its retailer's, then the default. Nil when none of them is known.
+func (z timeZones) locate(rec models.Receipt) (*time.Location, error) {
+	if rec.TimeZone != "" {
+		return models.LoadTimeZone(rec.TimeZone)
+	}
+	if location, ok := z.retailers[retailerKey(rec.Retailer)]; ok {
+		return location, nil
+	}
+	return z.defaultZone, nil
+}
+
+// Retailers are matched ignoring letter case and surrounding spaces
+func retailerKey(retailer string) string {
+	return strings.ToLower(strings.TrimSpace(retailer))
+}
+
+// Change the time zones of receipts that don't give their own. The default
+// zone can be empty, and retailers maps retailer names to their zones.
+func (rs *Ruleset) SetTimeZones(defaultZone string, retailers map[string]string) error {
+	var zones timeZones
+	if defaultZone != "" {
+		location, err := models.LoadTimeZone(defaultZone)
+		if err != nil {
+			return fmt.Errorf("timeZone: %w", err)
+		}
+		zones.defaultZone = location
+	}
+
+	zones.retailers = map[string]*time.Location{}
+	for retailer, zone := range retailers {
+		if strings.TrimSpace(retailer) == "" {
+			return errors.New("retailers can't have an empty name")
+		}
+		location, err := models.LoadTimeZone(zone)
+		if err != nil {
+			return fmt.Errorf("retailer %q: %w", retailer, err)
+		}
+		zones.retailers[retailerKey(retailer)] = location
+	}
+
+	rs.zones = zones
+	return nil
+}
+
+// A receipt's purchase date and time as a rule sees them
+type localPurchase struct {
+	// The purchase date as YYYY-MM-DD and time as HH:MM
+	date  string
+	clock string
+	// The zone they are in, nil when neither the receipt nor the rule gave one
+	location *time.Location
+	// The zone of the receipt, when it was moved into the rule's zone
+	from *time.Location
+}
+
+// Read the purchase date and time of the receipt in the rule's zone, or in
+// the receipt's own zone when the rule doesn't have one. A receipt in an
+// unknown zone is taken to be in the rule's zone already.
+func purchaseIn(rec models.Receipt, receiptZone *time.Location, ruleZone *time.Location) (localPurchase, error) {
+	local := localPurchase{date: rec.PurchaseDate, clock: rec.PurchaseTime, location: receiptZone}
+	if ruleZone == nil {
+		return local, nil
+	}
+	if receiptZone == nil || receiptZone.String() == ruleZone.String() {
+		local.location = ruleZone
+		return local, nil
+	}
+
+	purchased, err := rec.PurchasedAt(receiptZone)
+	if err != nil {
+		return localPurchase{}, err
+	}
+	purchased = purchased.In(ruleZone)
+	return localPurchase{
+		date:     purchased.Format("2006-01-02"),
+		clock:    purchased.Format("15:04"),
+		location: ruleZone,
+		from:     receiptZone,
+	}, nil
+}
+
+// Describe the purchase time for explanations, e.g. "15:40" in
+// America/Chicago is "16:40" in America/New_York
+func (p localPurchase) describeClock(rec models.Receipt) string {
+	switch {
+	case p.from != nil:
+		return fmt.Sprintf("%q in %s is %q in %s", rec.PurchaseTime, p.from, p.clock, p.location)
+	case p.location != nil:
+		return fmt.Sprintf("%q in %s", p.clock, p.location)
+	}
+	return fmt.Sprintf("%q", p.clock)
+}
+
+// Load the zone a rule evaluates receipts in, nil when it has none
+func ruleZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return models.LoadTimeZone(name)
+}
+
+// A window of time of day in 24 hour time, both ends are exclusive
+type ClockWindow struct {
+	After  string `yaml:"after"`
+	Before string `yaml:"before"`
+}
+
+// The window in minutes after midnight
+func (w ClockWindow) minutes() (int, int, error) {
+	after, err := parseClock(w.After)
+	if err != nil {
+		return 0, 0, fmt.Errorf("after %q: %w", w.After, err)
+	}
+	before, err := parseClock(w.Before)
+	if err != nil {
+		return 0, 0, fmt.Errorf("before %q: %w", w.Before, err)
+	}
+	if after >= before {
+		return 0, 0, errors.New("after must be earlier than before")
+	}
+	return after, before, nil
+}
+
+// Key windows by the name of the zone they load as, so that "+0530" and
+// "+05:30" are the same zone
+func zoneWindows(windows map[string]ClockWindow) (map[string]ClockWindow, error) {
+	byZone := make(map[string]ClockWindow, len(windows))
+	for zone, window := range windows {
+		location, err := models.LoadTimeZone(zone)
+		if err != nil {
+			return nil, fmt.Errorf("zones: %w", err)
+		}
+		if _, _, err := window.minutes(); err != nil {
+			return nil, fmt.Errorf("zones %s: %w", zone, err)
+		}
+		if _, ok := byZone[location.String()]; ok {
+			return nil, fmt.Errorf("zones: %s is given more than once", location)
+		}
+		byZone[location.String()] = window
+	}
+	return byZone, nil
+}
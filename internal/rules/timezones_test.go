@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+)
+
+func TestTimeZonesLocate(t *testing.T) {
+	ruleset := NewRuleset()
+	if err := ruleset.SetTimeZones("America/New_York", map[string]string{"Target": "America/Chicago"}); err != nil {
+		t.Fatalf("SetTimeZones got an error: %q", err.Error())
+	}
+
+	testTable := []struct {
+		retailer string
+		timeZone string
+		expected string
+	}{
+		{"Walgreens", "", "America/New_York"},
+		{" target ", "", "America/Chicago"},
+		{"Target", "-07:00", "-07:00"},
+	}
+
+	for _, test := range testTable {
+		location, err := ruleset.zones.locate(models.Receipt{Retailer: test.retailer, TimeZone: test.timeZone})
+		if err != nil {
+			t.Errorf("locate(%q, %q) got an error: %q", test.retailer, test.timeZone, err.Error())
+			continue
+		}
+		if location.String() != test.expected {
+			t.Errorf("locate(%q, %q) = got %s, wanted %s", test.retailer, test.timeZone, location, test.expected)
+		}
+	}
+
+	if location, _ := NewRuleset().zones.locate(targetReceipt); location != nil {
+		t.Errorf("locate without any zones = got %s, wanted none", location)
+	}
+	if err := ruleset.SetTimeZones("Mars/Olympus", nil); err == nil {
+		t.Errorf("SetTimeZones with an unknown zone = got no error, wanted one")
+	}
+}
+
+func TestPurchaseTimeRuleZones(t *testing.T) {
+	chicago, _ := models.LoadTimeZone("America/Chicago")
+	losAngeles, _ := models.LoadTimeZone("America/Los_Angeles")
+
+	testTable := []struct {
+		name     string
+		rule     PurchaseTimeRule
+		clock    string
+		location *time.Location
+		expected int
+	}{
+		{"no zones", PurchaseTimeRule{}, "14:30", nil, 10},
+		{"receipt zone", PurchaseTimeRule{}, "14:30", chicago, 10},
+		// 13:30 in Chicago is 14:30 in New York
+		{"rule zone", PurchaseTimeRule{TimeZone: "America/New_York"}, "13:30", chicago, 10},
+		{"rule zone outside", PurchaseTimeRule{TimeZone: "America/New_York"}, "15:30", chicago, 0},
+		// An unknown zone is taken to be the rule's
+		{"unknown receipt zone", PurchaseTimeRule{TimeZone: "America/New_York"}, "15:30", nil, 10},
+		{"zone window", PurchaseTimeRule{Zones: map[string]ClockWindow{"America/Los_Angeles": {After: "15:00", Before: "17:00"}}}, "16:30", losAngeles, 10},
+		{"zone window elsewhere", PurchaseTimeRule{Zones: map[string]ClockWindow{"America/Los_Angeles": {After: "15:00", Before: "17:00"}}}, "16:30", chicago, 0},
+	}
+
+	for _, test := range testTable {
+		rule := test.rule
+		rule.After, rule.Before, rule.Points = "14:00", "16:00", 10
+		if err := rule.Validate(); err != nil {
+			t.Fatalf("Validate(%s) got an error: %q", test.name, err.Error())
+		}
+
+		receipt := targetReceipt
+		receipt.PurchaseTime = test.clock
+		result, err := rule.EvaluateContext(receipt, EvalContext{Location: test.location})
+		if err != nil {
+			t.Errorf("EvaluateContext(%s) got an error: %q", test.name, err.Error())
+			continue
+		}
+		if result.Points != test.expected {
+			t.Errorf("EvaluateContext(%s) = got %d (%s), wanted %d", test.name, result.Points, result.Reason, test.expected)
+		}
+	}
+}
+
+func TestOddPurchaseDayRuleZone(t *testing.T) {
+	eastern, _ := models.LoadTimeZone("-05:00")
+
+	// 23:30 on the 1st at -05:00 is already the 2nd in UTC
+	receipt := targetReceipt
+	receipt.PurchaseDate, receipt.PurchaseTime = "2022-01-01", "23:30"
+
+	local := &OddPurchaseDayRule{Points: 6}
+	if result, _ := local.EvaluateContext(receipt, EvalContext{Location: eastern}); result.Points != 6 {
+		t.Errorf("OddPurchaseDayRule in the receipt's zone = got %d, wanted %d", result.Points, 6)
+	}
+
+	utc := &OddPurchaseDayRule{Points: 6, TimeZone: "UTC"}
+	result, err := utc.EvaluateContext(receipt, EvalContext{Location: eastern})
+	if err != nil {
+		t.Fatalf("EvaluateContext got an error: %q", err.Error())
+	}
+	if result.Points != 0 || result.Reason != "Purchase day is even (2022-01-02 in UTC)" {
+		t.Errorf("OddPurchaseDayRule in UTC = got %d %q, wanted %d", result.Points, result.Reason, 0)
+	}
+}
+
+func TestWindowedRuleZone(t *testing.T) {
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(`
+rules:
+  - type: bonus
+    name: local_new_year
+    validUntil: "2022-01-01"
+    params:
+      points: 100
+  - type: bonus
+    name: utc_new_year
+    validUntil: "2022-01-01"
+    timeZone: UTC
+    params:
+      points: 100
+`), "windows.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	// 23:30 on the 1st at -05:00 is already the 2nd in UTC
+	receipt := targetReceipt
+	receipt.PurchaseDate, receipt.PurchaseTime, receipt.TimeZone = "2022-01-01", "23:30", "-05:00"
+	score, err := ruleset.Calculate(receipt)
+	if err != nil {
+		t.Fatalf("Calculate got an error: %q", err.Error())
+	}
+	if score.Results[0].Points != 100 {
+		t.Errorf("window in the receipt's zone = got %d (%s), wanted %d", score.Results[0].Points, score.Results[0].Reason, 100)
+	}
+	if score.Results[1].Points != 0 || score.Results[1].Reason != "Purchased on 2022-01-01 (2022-01-02 in UTC), only valid until 2022-01-01" {
+		t.Errorf("window in UTC = got %d (%s), wanted %d", score.Results[1].Points, score.Results[1].Reason, 0)
+	}
+
+	_, err = DefaultRegistry.ParseRuleset([]byte("rules:\n  - type: bonus\n    timeZone: UTC\n"), "windows.yaml")
+	if err == nil {
+		t.Errorf("ParseRuleset with a timeZone and no window = got no error, wanted one")
+	}
+}
+
+func TestRulesetTimeZones(t *testing.T) {
+	ruleset, err := DefaultRegistry.ParseRuleset([]byte(`
+timeZone: America/New_York
+retailers:
+  - name: Target
+    timeZone: America/Chicago
+rules:
+  - type: purchase_time
+    params:
+      timeZone: America/New_York
+`), "zones.yaml")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+
+	testTable := []struct {
+		retailer string
+		timeZone string
+		expected int
+	}{
+		// 13:30 in Chicago is 14:30 in New York
+		{"Target", "", 10},
+		{"Walgreens", "", 0},
+		{"Walgreens", "-06:00", 10},
+	}
+
+	for _, test := range testTable {
+		receipt := targetReceipt
+		receipt.Retailer, receipt.TimeZone, receipt.PurchaseTime = test.retailer, test.timeZone, "13:30"
+		score, err := ruleset.Calculate(receipt)
+		if err != nil {
+			t.Errorf("Calculate(%s, %q) got an error: %q", test.retailer, test.timeZone, err.Error())
+			continue
+		}
+		if score.Total != test.expected {
+			t.Errorf("Calculate(%s, %q) = got %d, wanted %d", test.retailer, test.timeZone, score.Total, test.expected)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The version of the built in ruleset
+const DefaultRulesetVersion = "default"
+
+// The most versions kept at once. Past it the versions registered longest
+// ago are forgotten, apart from the built in rules.
+const maxRulesetVersions = 32
+
+// Every versioned ruleset that has been made active or registered, so that
+// receipts can be scored again under an older version
+var rulesetVersions = struct {
+	sync.RWMutex
+	byVersion map[string]*Ruleset
+	// The versions in the order they were registered, oldest first
+	order []string
+}{byVersion: map[string]*Ruleset{}}
+
+// Make the ruleset available by its version. Rulesets without a version are
+// ignored. A version keeps the rules it was first registered with, so that
+// scores stored under it can be trusted, a ruleset with the same version
+// and different rules is refused. Registering the same rules again adds
+// nothing.
+func RegisterRuleset(ruleset *Ruleset) error {
+	if ruleset.Version() == "" {
+		return nil
+	}
+
+	rulesetVersions.Lock()
+	defer rulesetVersions.Unlock()
+
+	registered, ok := rulesetVersions.byVersion[ruleset.Version()]
+	if !ok {
+		rulesetVersions.byVersion[ruleset.Version()] = ruleset
+		rulesetVersions.order = append(rulesetVersions.order, ruleset.Version())
+		forgetOldVersions()
+		return nil
+	}
+	if registered != ruleset && (registered.digest == "" || registered.digest != ruleset.digest) {
+		return fmt.Errorf("ruleset version %q is already in use by different rules, give the ruleset a new version", ruleset.Version())
+	}
+	return nil
+}
+
+// Forget the oldest versions until there are at most maxRulesetVersions.
+// Receipts scored under a forgotten version keep their points, they just
+// can't be rescored under it. Must be called with rulesetVersions locked.
+func forgetOldVersions() {
+	for i := 0; len(rulesetVersions.order) > maxRulesetVersions && i < len(rulesetVersions.order); {
+		version := rulesetVersions.order[i]
+		if version == DefaultRulesetVersion {
+			i++
+			continue
+		}
+		delete(rulesetVersions.byVersion, version)
+		rulesetVersions.order = append(rulesetVersions.order[:i], rulesetVersions.order[i+1:]...)
+	}
+}
+
+// Returns the ruleset with the given version, or false if it isn't known
+func RulesetByVersion(version string) (*Ruleset, bool) {
+	rulesetVersions.RLock()
+	defer rulesetVersions.RUnlock()
+
+	ruleset, ok := rulesetVersions.byVersion[version]
+	return ruleset, ok
+}
+
+// The versions of every known ruleset, sorted
+func RulesetVersions() []string {
+	rulesetVersions.RLock()
+	defer rulesetVersions.RUnlock()
+
+	versions := make([]string, 0, len(rulesetVersions.byVersion))
+	for version := range rulesetVersions.byVersion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// The version given to a ruleset document that doesn't name one, which
+// changes whenever what the document says does
+func contentVersion(digest string) string {
+	return "sha256:" + digest[:12]
+}
+
+// A hash of what a ruleset document says, so that reformatting it or
+// changing its comments doesn't change it. Falls back to the raw document
+// when it can't be read as plain data.
+func documentDigest(document *yaml.Node, data []byte) string {
+	var content any
+	if err := document.Decode(&content); err == nil {
+		if canonical, err := json.Marshal(content); err == nil {
+			data = canonical
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
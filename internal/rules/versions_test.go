@@ -0,0 +1,107 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRulesetVersions(t *testing.T) {
+	restoreActiveRuleset(t)
+
+	testTable := []struct {
+		document string
+		expected string
+	}{
+		{"version: \"2024-06\"\nrules:\n  - type: item_pairs\n", "2024-06"},
+		{"rules:\n  - type: item_pairs\n", "sha256:"},
+	}
+
+	for _, test := range testTable {
+		ruleset, err := DefaultRegistry.ParseRuleset([]byte(test.document), "rules.yaml")
+		if err != nil {
+			t.Fatalf("ParseRuleset got an error: %q", err.Error())
+		}
+		if !strings.HasPrefix(ruleset.Version(), test.expected) {
+			t.Errorf("ParseRuleset(%q) = got version %q, wanted %q", test.document, ruleset.Version(), test.expected)
+		}
+	}
+
+	// Replaced rulesets can still be found by their version
+	first, _ := DefaultRegistry.ParseRuleset([]byte("version: first\nrules: []\n"), "first.yaml")
+	second, _ := DefaultRegistry.ParseRuleset([]byte("version: second\nrules: []\n"), "second.yaml")
+	SetRuleset(first)
+	SetRuleset(second)
+
+	for _, version := range []string{DefaultRulesetVersion, "first", "second"} {
+		if _, ok := RulesetByVersion(version); !ok {
+			t.Errorf("RulesetByVersion(%q) = got nothing, wanted a ruleset", version)
+		}
+	}
+	if found, _ := RulesetByVersion("first"); found != first {
+		t.Errorf("RulesetByVersion(\"first\") = got a different ruleset")
+	}
+	if _, ok := RulesetByVersion("missing"); ok {
+		t.Errorf("RulesetByVersion(\"missing\") = got a ruleset, wanted nothing")
+	}
+}
+
+func TestRegisterRulesetTakenVersion(t *testing.T) {
+	restoreActiveRuleset(t)
+
+	original, _ := DefaultRegistry.ParseRuleset([]byte("version: taken\nrules:\n  - type: item_pairs\n"), "rules.yaml")
+	if err := SetRuleset(original); err != nil {
+		t.Fatalf("SetRuleset got an error: %q", err.Error())
+	}
+
+	// The same rules, only written differently, can be loaded again
+	reformatted, _ := DefaultRegistry.ParseRuleset([]byte("# the same rules\nversion: taken\nrules: [{type: item_pairs}]\n"), "rules.yaml")
+	if err := SetRuleset(reformatted); err != nil {
+		t.Errorf("SetRuleset of the same rules got an error: %q", err.Error())
+	}
+
+	// Different rules under the same version are refused
+	changed, _ := DefaultRegistry.ParseRuleset([]byte("version: taken\nrules:\n  - type: round_dollar_total\n"), "rules.yaml")
+	if err := SetRuleset(changed); err == nil {
+		t.Errorf("SetRuleset of different rules under a taken version = got no error, wanted one")
+	}
+	if found, _ := RulesetByVersion("taken"); found != original {
+		t.Errorf("RulesetByVersion(\"taken\") = got a different ruleset, wanted the original")
+	}
+
+	builtIn := NewRuleset()
+	builtIn.SetVersion(DefaultRulesetVersion)
+	if err := RegisterRuleset(builtIn); err == nil {
+		t.Errorf("RegisterRuleset of other rules as %q = got no error, wanted one", DefaultRulesetVersion)
+	}
+}
+
+func TestRulesetVersionsAreBounded(t *testing.T) {
+	restoreActiveRuleset(t)
+
+	// Reloading the same rules, even written differently, adds no version
+	before := len(RulesetVersions())
+	first, _ := DefaultRegistry.ParseRuleset([]byte("rules:\n  - type: item_pairs\n"), "rules.yaml")
+	again, _ := DefaultRegistry.ParseRuleset([]byte("# reloaded\nrules: [{type: item_pairs}]\n"), "rules.yaml")
+	SetRuleset(first)
+	SetRuleset(again)
+	if first.Version() != again.Version() || len(RulesetVersions()) > before+1 {
+		t.Errorf("SetRuleset of the same rules twice = got versions %q and %q and %d more versions, wanted one", first.Version(), again.Version(), len(RulesetVersions())-before)
+	}
+
+	// Past the limit the oldest versions are forgotten, but not the built in rules
+	for i := 0; i < maxRulesetVersions+5; i++ {
+		ruleset := NewRuleset()
+		ruleset.SetVersion(fmt.Sprintf("bounded-%d", i))
+		RegisterRuleset(ruleset)
+	}
+	if count := len(RulesetVersions()); count != maxRulesetVersions {
+		t.Errorf("RulesetVersions after many versions = got %d, wanted %d", count, maxRulesetVersions)
+	}
+	if _, ok := RulesetByVersion(DefaultRulesetVersion); !ok {
+		t.Errorf("RulesetByVersion(%q) after many versions = got nothing, wanted the built in rules", DefaultRulesetVersion)
+	}
+	if _, ok := RulesetByVersion("bounded-0"); ok {
+		t.Errorf("RulesetByVersion(\"bounded-0\") after many versions = got a ruleset, wanted it forgotten")
+	}
+}
@@ -0,0 +1,241 @@
+package scoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+)
+
+// The most retailers and receipts a backtest lists when no limit is given
+const DefaultBacktestTop = 10
+
+// The edges of the buckets changes in points are counted in. A bucket holds
+// the changes from its lower edge up to the next edge.
+var deltaBucketEdges = []int{-49, -9, 0, 1, 10, 50}
+
+// Backtest Report Info
+// @Description How the points of stored receipts change between two rulesets
+type BacktestReport struct {
+	// The version of the ruleset the receipts are compared against
+	Baseline string `json:"baseline" example:"default"`
+	// The version of the ruleset being tried out
+	Candidate string `json:"candidate" example:"2024-06"`
+	// How many receipts were scored under both rulesets
+	Receipts int `json:"receipts" example:"120"`
+	// How many receipts couldn't be scored under one of the rulesets, they
+	// aren't counted anywhere else
+	Unscoreable int `json:"unscoreable" example:"2"`
+	// How many receipts are worth a different number of points
+	Changed int `json:"changed" example:"37"`
+	// The points of every receipt under the baseline
+	BaselineTotal int `json:"baselineTotal" example:"8410"`
+	// The points of every receipt under the candidate
+	CandidateTotal int `json:"candidateTotal" example:"8992"`
+	// The candidate total less the baseline total
+	TotalDelta int `json:"totalDelta" example:"582"`
+	// How many receipts changed by how much
+	Distribution []DeltaBucket `json:"distribution"`
+	// The retailers whose receipts changed the most overall
+	TopRetailers []RetailerDelta `json:"topRetailers"`
+	// The receipts that changed the most
+	TopReceipts []ReceiptDelta `json:"topReceipts"`
+}
+
+// Delta Bucket Info
+// @Description How many receipts changed by an amount in a range
+type DeltaBucket struct {
+	// The range, e.g. "1 to 9"
+	Label string `json:"label" example:"1 to 9"`
+	// The smallest change in the range, missing if it has no lower bound
+	Min *int `json:"min,omitempty" example:"1"`
+	// The largest change in the range, missing if it has no upper bound
+	Max *int `json:"max,omitempty" example:"9"`
+	// How many receipts changed by an amount in the range
+	Receipts int `json:"receipts" example:"12"`
+}
+
+// Retailer Delta Info
+// @Description How the receipts of one retailer changed
+type RetailerDelta struct {
+	// The retailer name
+	Retailer string `json:"retailer" example:"Target"`
+	// How many of its receipts were scored
+	Receipts int `json:"receipts" example:"14"`
+	// How many of its receipts changed
+	Changed int `json:"changed" example:"6"`
+	// The change in the points of all of its receipts
+	Delta int `json:"delta" example:"-40"`
+}
+
+// Receipt Delta Info
+// @Description How the points of one receipt changed
+type ReceiptDelta struct {
+	// The receipt id
+	ID string `json:"id" example:"adb6b560-0eef-42bc-9d16-df48f30e89b2"`
+	// The retailer name
+	Retailer string `json:"retailer" example:"Target"`
+	// The points under the baseline
+	Before int `json:"before" example:"28"`
+	// The points under the candidate
+	After int `json:"after" example:"78"`
+	// After less before
+	Delta int `json:"delta" example:"50"`
+}
+
+// Score the receipts that match the query under both rulesets and report how
+// the points change. Nothing is stored. The sort, limit and cursor of the
+// query are ignored, every matching receipt is scored. top limits how many
+// retailers and receipts are listed.
+func Backtest(store models.ReceiptStore, query models.ReceiptQuery, baseline *rules.Ruleset, candidate *rules.Ruleset, top int) (BacktestReport, error) {
+	if top <= 0 {
+		top = DefaultBacktestTop
+	}
+
+	report := BacktestReport{
+		Baseline:     baseline.Version(),
+		Candidate:    candidate.Version(),
+		Distribution: newDeltaBuckets(),
+		TopRetailers: []RetailerDelta{},
+		TopReceipts:  []ReceiptDelta{},
+	}
+	// Retailers are grouped ignoring letter case, the same way they're filtered
+	retailers := map[string]*RetailerDelta{}
+	var changes []ReceiptDelta
+
+	query.Sort, query.Descending, query.Cursor = "", false, ""
+	query.Limit = models.DefaultPageLimit
+	for {
+		page, err := store.QueryReceipts(query)
+		if err != nil {
+			return BacktestReport{}, err
+		}
+
+		for _, receipt := range page.Receipts {
+			before, beforeOk := points(baseline, receipt)
+			after, afterOk := points(candidate, receipt)
+			if !beforeOk || !afterOk {
+				report.Unscoreable++
+				continue
+			}
+
+			delta := after - before
+			report.Receipts++
+			report.BaselineTotal += before
+			report.CandidateTotal += after
+			report.Distribution[deltaBucket(delta)].Receipts++
+
+			key := strings.ToLower(strings.TrimSpace(receipt.Retailer))
+			retailer, found := retailers[key]
+			if !found {
+				retailer = &RetailerDelta{Retailer: strings.TrimSpace(receipt.Retailer)}
+				retailers[key] = retailer
+			}
+			retailer.Receipts++
+			retailer.Delta += delta
+
+			if delta != 0 {
+				report.Changed++
+				retailer.Changed++
+				changes = append(changes, ReceiptDelta{
+					ID:       receipt.ID,
+					Retailer: receipt.Retailer,
+					Before:   before,
+					After:    after,
+					Delta:    delta,
+				})
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	report.TotalDelta = report.CandidateTotal - report.BaselineTotal
+
+	for _, retailer := range retailers {
+		if retailer.Changed > 0 {
+			report.TopRetailers = append(report.TopRetailers, *retailer)
+		}
+	}
+	sort.Slice(report.TopRetailers, func(i, j int) bool {
+		a, b := report.TopRetailers[i], report.TopRetailers[j]
+		if abs(a.Delta) != abs(b.Delta) {
+			return abs(a.Delta) > abs(b.Delta)
+		}
+		return a.Retailer < b.Retailer
+	})
+	if len(report.TopRetailers) > top {
+		report.TopRetailers = report.TopRetailers[:top]
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if abs(changes[i].Delta) != abs(changes[j].Delta) {
+			return abs(changes[i].Delta) > abs(changes[j].Delta)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	if len(changes) > top {
+		changes = changes[:top]
+	}
+	report.TopReceipts = append(report.TopReceipts, changes...)
+
+	return report, nil
+}
+
+// The points the receipt is worth under the ruleset, or false if it can't
+// be scored
+func points(ruleset *rules.Ruleset, receipt models.Receipt) (int, bool) {
+	score := ScoreWith(ruleset, receipt)
+	if score.Status != models.StatusScored {
+		return 0, false
+	}
+	return *score.Points, true
+}
+
+// One empty bucket for each range between the edges, and one below and
+// above them
+func newDeltaBuckets() []DeltaBucket {
+	buckets := make([]DeltaBucket, 0, len(deltaBucketEdges)+1)
+	for i := 0; i <= len(deltaBucketEdges); i++ {
+		var bucket DeltaBucket
+		if i > 0 {
+			low := deltaBucketEdges[i-1]
+			bucket.Min = &low
+		}
+		if i < len(deltaBucketEdges) {
+			high := deltaBucketEdges[i] - 1
+			bucket.Max = &high
+		}
+
+		switch {
+		case bucket.Min == nil:
+			bucket.Label = fmt.Sprintf("%d or less", *bucket.Max)
+		case bucket.Max == nil:
+			bucket.Label = fmt.Sprintf("%d or more", *bucket.Min)
+		case *bucket.Min == *bucket.Max:
+			bucket.Label = fmt.Sprintf("%d", *bucket.Min)
+		default:
+			bucket.Label = fmt.Sprintf("%d to %d", *bucket.Min, *bucket.Max)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// The position of the bucket a change in points is counted in
+func deltaBucket(delta int) int {
+	return sort.Search(len(deltaBucketEdges), func(i int) bool {
+		return delta < deltaBucketEdges[i]
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
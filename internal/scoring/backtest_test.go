@@ -0,0 +1,106 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+)
+
+// A ruleset that awards points for each character of the retailer name
+func newRetailerRuleset(t *testing.T, version string, pointsPerCharacter string) *rules.Ruleset {
+	document := "version: " + version + "\nrules:\n  - type: retailer_alphanumeric\n    params:\n      pointsPerCharacter: " + pointsPerCharacter + "\n"
+	ruleset, err := rules.DefaultRegistry.ParseRuleset([]byte(document), "test")
+	if err != nil {
+		t.Fatalf("ParseRuleset got an error: %q", err.Error())
+	}
+	return ruleset
+}
+
+func TestBacktest(t *testing.T) {
+	store := models.NewMemoryStore()
+	for _, retailer := range []string{"Target", "target", "Walmart", "A"} {
+		receipt := newTestReceipt()
+		receipt.Retailer = retailer
+		store.AddReceipt(receipt)
+	}
+
+	single := newRetailerRuleset(t, "single", "1")
+	double := newRetailerRuleset(t, "double", "2")
+
+	report, err := Backtest(store, models.ReceiptQuery{}, single, double, 2)
+	if err != nil {
+		t.Fatalf("Backtest got an error: %q", err.Error())
+	}
+
+	if report.Baseline != "single" || report.Candidate != "double" {
+		t.Errorf("Backtest = got versions %q and %q, wanted %q and %q", report.Baseline, report.Candidate, "single", "double")
+	}
+	if report.Receipts != 4 || report.Changed != 4 || report.BaselineTotal != 20 || report.CandidateTotal != 40 || report.TotalDelta != 20 {
+		t.Errorf("Backtest = got %d receipts, %d changed, totals %d to %d (%d), wanted 4, 4, 20 to 40 (20)",
+			report.Receipts, report.Changed, report.BaselineTotal, report.CandidateTotal, report.TotalDelta)
+	}
+
+	for _, bucket := range report.Distribution {
+		expected := 0
+		if bucket.Label == "1 to 9" {
+			expected = 4
+		}
+		if bucket.Receipts != expected {
+			t.Errorf("Backtest bucket %q = got %d receipts, wanted %d", bucket.Label, bucket.Receipts, expected)
+		}
+	}
+
+	// Retailers are grouped ignoring letter case
+	if len(report.TopRetailers) != 2 || report.TopRetailers[0].Retailer != "Target" || report.TopRetailers[0].Receipts != 2 ||
+		report.TopRetailers[0].Delta != 12 || report.TopRetailers[1].Retailer != "Walmart" {
+		t.Errorf("Backtest = got top retailers %+v, wanted Target with 2 receipts worth 12 more, then Walmart", report.TopRetailers)
+	}
+	if len(report.TopReceipts) != 2 || report.TopReceipts[0].Retailer != "Walmart" || report.TopReceipts[0].Before != 7 || report.TopReceipts[0].After != 14 {
+		t.Errorf("Backtest = got top receipts %+v, wanted the Walmart receipt going from 7 to 14 first", report.TopReceipts)
+	}
+
+	// Filtered, and against itself
+	report, err = Backtest(store, models.ReceiptQuery{Retailer: "TARGET"}, single, single, 0)
+	if err != nil {
+		t.Fatalf("Backtest got an error: %q", err.Error())
+	}
+	if report.Receipts != 2 || report.Changed != 0 || report.TotalDelta != 0 || len(report.TopReceipts) != 0 {
+		t.Errorf("Backtest of Target against itself = got %+v, wanted 2 unchanged receipts", report)
+	}
+
+	// Receipts the candidate can't score are counted on their own
+	failing := rules.NewRuleset(&failingRule{})
+	report, _ = Backtest(store, models.ReceiptQuery{}, single, failing, 0)
+	if report.Receipts != 0 || report.Unscoreable != 4 {
+		t.Errorf("Backtest against a failing ruleset = got %d receipts and %d unscoreable, wanted 0 and 4", report.Receipts, report.Unscoreable)
+	}
+}
+
+func TestDeltaBucket(t *testing.T) {
+	buckets := newDeltaBuckets()
+
+	testTable := []struct {
+		delta    int
+		expected string
+	}{
+		{-80, "-50 or less"},
+		{-50, "-50 or less"},
+		{-49, "-49 to -10"},
+		{-10, "-49 to -10"},
+		{-9, "-9 to -1"},
+		{-1, "-9 to -1"},
+		{0, "0"},
+		{1, "1 to 9"},
+		{9, "1 to 9"},
+		{10, "10 to 49"},
+		{49, "10 to 49"},
+		{50, "50 or more"},
+	}
+
+	for _, test := range testTable {
+		if got := buckets[deltaBucket(test.delta)].Label; got != test.expected {
+			t.Errorf("deltaBucket(%d) = got %q, wanted %q", test.delta, got, test.expected)
+		}
+	}
+}
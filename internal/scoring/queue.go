@@ -0,0 +1,283 @@
+package scoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+)
+
+// The defaults used when a queue is created with a size or worker count of 0
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 1000
+)
+
+// Where a receipt is in the queue
+type jobState int
+
+const (
+	// Waiting for a worker
+	jobQueued jobState = iota + 1
+	// Being scored by a worker
+	jobRunning
+	// Being scored, but changed since the worker started, so it has to be
+	// scored again once the worker is done
+	jobStale
+)
+
+// Scores receipts in the background with a fixed number of workers. Receipts
+// that don't fit in the queue stay pending and are picked up by the next
+// sweep.
+type Queue struct {
+	store   models.ReceiptStore
+	workers int
+	jobs    chan string
+
+	// The state of every receipt that is queued or being scored, so a
+	// receipt is never queued twice
+	mu     sync.Mutex
+	states map[string]jobState
+}
+
+// Create a queue that scores the receipts of store, holding at most size
+// receipts at once
+func NewQueue(store models.ReceiptStore, workers int, size int) *Queue {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	return &Queue{
+		store:   store,
+		workers: workers,
+		jobs:    make(chan string, size),
+		states:  map[string]jobState{},
+	}
+}
+
+// Queue the receipt with the given id to be scored. Returns false if the
+// queue is full, the receipt stays pending until the next sweep.
+func (q *Queue) Enqueue(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch q.states[id] {
+	case jobQueued, jobStale:
+		// The worker will read the latest version of the receipt
+		return true
+	case jobRunning:
+		q.states[id] = jobStale
+		return true
+	}
+
+	select {
+	case q.jobs <- id:
+		q.states[id] = jobQueued
+		return true
+	default:
+		return false
+	}
+}
+
+// Start the workers and sweep the store for pending receipts now and then
+// every interval. Blocks until the context is cancelled.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	defer wg.Wait()
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	q.sweepAndLog()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			q.sweepAndLog()
+		}
+	}
+}
+
+// Queue every pending receipt in the store, returns how many were queued
+func (q *Queue) Sweep() (int, error) {
+	query := models.ReceiptQuery{Status: models.StatusPending, Limit: models.DefaultPageLimit}
+	queued := 0
+	for {
+		page, err := q.store.QueryReceipts(query)
+		if err != nil {
+			return queued, err
+		}
+
+		for _, receipt := range page.Receipts {
+			if !q.Enqueue(receipt.ID) {
+				// Full, the rest wait for the next sweep
+				return queued, nil
+			}
+			queued++
+		}
+
+		if page.NextCursor == "" {
+			return queued, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// Sweep the store and log the outcome
+func (q *Queue) sweepAndLog() {
+	queued, err := q.Sweep()
+	if err != nil {
+		log.Printf("Could not queue pending receipts: %v", err)
+	} else if queued > 0 {
+		log.Printf("Queued %d pending receipts for scoring", queued)
+	}
+}
+
+// Score queued receipts until the context is cancelled
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.jobs:
+			q.process(id)
+		}
+	}
+}
+
+// Score the receipt, again if it changed while it was being scored
+func (q *Queue) process(id string) {
+	for {
+		q.mu.Lock()
+		q.states[id] = jobRunning
+		q.mu.Unlock()
+
+		if err := ScoreReceipt(q.store, id); err != nil {
+			log.Printf("Could not score receipt %s: %v", id, err)
+		}
+
+		q.mu.Lock()
+		if q.states[id] != jobStale {
+			delete(q.states, id)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Score the pending receipt with the given id under the active ruleset and
+// store the outcome. Receipts that were deleted or scored in the meantime are
+// skipped, a receipt keeps its score until it is changed or rescored. A
+// receipt that changes while it is being scored is scored again.
+func ScoreReceipt(store models.ReceiptStore, id string) error {
+	for {
+		receipt, err := store.GetReceipt(id)
+		if errors.Is(err, models.ErrReceiptNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if receipt.Status != models.StatusPending {
+			return nil
+		}
+
+		score := Score(*receipt)
+		score.IfPending = true
+		err = store.SaveScore(id, score)
+		if errors.Is(err, models.ErrStaleScore) {
+			continue
+		}
+		if errors.Is(err, models.ErrReceiptNotFound) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Score the receipt with the given id again under the ruleset, whatever its
+// status, and store the outcome. Returns the receipt as it was before and
+// after.
+func RescoreReceipt(store models.ReceiptStore, id string, ruleset *rules.Ruleset) (*models.Receipt, *models.Receipt, error) {
+	for {
+		before, err := store.GetReceipt(id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = store.SaveScore(id, ScoreWith(ruleset, *before))
+		if errors.Is(err, models.ErrStaleScore) {
+			// Changed while it was being scored, score the new contents
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		after, err := store.GetReceipt(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return before, after, nil
+	}
+}
+
+// Work out the points the receipt is worth under the active ruleset
+func Score(receipt models.Receipt) models.ReceiptScore {
+	return ScoreWith(rules.ActiveRuleset(), receipt)
+}
+
+// Work out the points the receipt is worth under the ruleset, keeping the
+// breakdown and the version of the ruleset with them
+func ScoreWith(ruleset *rules.Ruleset, receipt models.Receipt) models.ReceiptScore {
+	score := scoreWith(ruleset, receipt)
+	score.ContentVersion = receipt.ContentVersion
+	return score
+}
+
+// Work out the score of the receipt, without saying which contents it is for
+func scoreWith(ruleset *rules.Ruleset, receipt models.Receipt) models.ReceiptScore {
+	if models.ExcludedFromScoring(receipt) {
+		return models.ReceiptScore{
+			Status:         models.StatusFailed,
+			RulesetVersion: ruleset.Version(),
+			Error:          "The receipt total doesn't match the sum of its item prices",
+		}
+	}
+
+	score, err := ruleset.Calculate(receipt)
+	if err != nil {
+		return models.ReceiptScore{Status: models.StatusFailed, RulesetVersion: ruleset.Version(), Error: err.Error()}
+	}
+
+	breakdown, err := json.Marshal(score.Results)
+	if err != nil {
+		return models.ReceiptScore{Status: models.StatusFailed, RulesetVersion: ruleset.Version(), Error: err.Error()}
+	}
+	return models.ReceiptScore{
+		Status:         models.StatusScored,
+		Points:         &score.Total,
+		Breakdown:      breakdown,
+		RulesetVersion: ruleset.Version(),
+	}
+}
@@ -0,0 +1,188 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+)
+
+// A rule that can't score anything
+type failingRule struct{}
+
+func (r *failingRule) Name() string {
+	return "failing"
+}
+
+func (r *failingRule) Evaluate(rec models.Receipt) (rules.RuleResult, error) {
+	return rules.RuleResult{}, errors.New("the rule failed")
+}
+
+func newTestReceipt() models.Receipt {
+	return models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Total:        models.MustParseMoney("18.74"),
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: models.MustParseMoney("6.49")},
+			{ShortDescription: "Emils Cheese Pizza", Price: models.MustParseMoney("12.25")},
+		},
+	}
+}
+
+func TestScoreReceipt(t *testing.T) {
+	store := models.NewMemoryStore()
+	id, _ := store.AddReceipt(newTestReceipt())
+
+	expected, _ := rules.ActiveRuleset().Calculate(newTestReceipt())
+	if err := ScoreReceipt(store, id); err != nil {
+		t.Fatalf("ScoreReceipt got an error: %q", err.Error())
+	}
+	receipt, _ := store.GetReceipt(id)
+	if receipt.Status != models.StatusScored || receipt.Points == nil || *receipt.Points != expected.Total {
+		t.Errorf("ScoreReceipt = got status %q and points %v, wanted %q and %d", receipt.Status, receipt.Points, models.StatusScored, expected.Total)
+	}
+
+	if receipt.RulesetVersion != rules.DefaultRulesetVersion || len(receipt.Breakdown) == 0 {
+		t.Errorf("ScoreReceipt = got version %q and breakdown %s, wanted %q and the rule results", receipt.RulesetVersion, receipt.Breakdown, rules.DefaultRulesetVersion)
+	}
+
+	// Scored receipts keep their score when the rules change
+	previous := rules.ActiveRuleset()
+	failing := rules.NewRuleset(&failingRule{})
+	failing.SetVersion("failing")
+	rules.SetRuleset(failing)
+	defer rules.SetRuleset(previous)
+
+	ScoreReceipt(store, id)
+	receipt, _ = store.GetReceipt(id)
+	if receipt.Status != models.StatusScored || *receipt.Points != expected.Total {
+		t.Errorf("ScoreReceipt of a scored receipt = got %+v, wanted it unchanged", receipt)
+	}
+
+	// Unless they are rescored, a ruleset that can't score the receipt marks
+	// it as failed
+	before, after, err := RescoreReceipt(store, id, failing)
+	if err != nil {
+		t.Fatalf("RescoreReceipt got an error: %q", err.Error())
+	}
+	if before.Status != models.StatusScored || after.Status != models.StatusFailed || after.ScoreError != "the rule failed" ||
+		after.Points != nil || after.RulesetVersion != "failing" {
+		t.Errorf("RescoreReceipt with a failing rule = got %+v, wanted a failed receipt", after)
+	}
+
+	// Deleted receipts are skipped
+	store.DeleteReceipt(id)
+	if err := ScoreReceipt(store, id); err != nil {
+		t.Errorf("ScoreReceipt of a deleted receipt got an error: %q", err.Error())
+	}
+}
+
+// A store that runs a change to a receipt just before the first score of it
+// is saved, as if the change landed while it was being scored
+type changingStore struct {
+	*models.MemoryStore
+	change func()
+}
+
+func (s *changingStore) SaveScore(id string, score models.ReceiptScore) error {
+	if change := s.change; change != nil {
+		s.change = nil
+		change()
+	}
+	return s.MemoryStore.SaveScore(id, score)
+}
+
+func TestScoreReceiptChangedWhileScoring(t *testing.T) {
+	store := &changingStore{MemoryStore: models.NewMemoryStore()}
+	id, _ := store.AddReceipt(newTestReceipt())
+
+	// The receipt is updated after it was read, its new contents are scored
+	updated := newTestReceipt()
+	updated.Retailer = "M&M Corner Market"
+	store.change = func() { store.UpdateReceipt(id, updated) }
+
+	expected, _ := rules.ActiveRuleset().Calculate(updated)
+	if err := ScoreReceipt(store, id); err != nil {
+		t.Fatalf("ScoreReceipt got an error: %q", err.Error())
+	}
+	receipt, _ := store.GetReceipt(id)
+	if receipt.Status != models.StatusScored || receipt.Points == nil || *receipt.Points != expected.Total {
+		t.Errorf("ScoreReceipt of an updated receipt = got status %q and points %v, wanted %q and %d", receipt.Status, receipt.Points, models.StatusScored, expected.Total)
+	}
+
+	// The receipt is rescored after it was read, the rescore is kept
+	failing := rules.NewRuleset(&failingRule{})
+	failing.SetVersion("failing")
+	store.UpdateReceipt(id, updated)
+	store.change = func() { RescoreReceipt(store.MemoryStore, id, failing) }
+
+	if err := ScoreReceipt(store, id); err != nil {
+		t.Fatalf("ScoreReceipt got an error: %q", err.Error())
+	}
+	receipt, _ = store.GetReceipt(id)
+	if receipt.Status != models.StatusFailed || receipt.RulesetVersion != "failing" {
+		t.Errorf("ScoreReceipt of a rescored receipt = got status %q and version %q, wanted %q and %q", receipt.Status, receipt.RulesetVersion, models.StatusFailed, "failing")
+	}
+}
+
+func TestQueueScoresPendingReceipts(t *testing.T) {
+	store := models.NewMemoryStore()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		receipt := newTestReceipt()
+		receipt.PurchaseTime = time.Date(2022, 1, 1, 13, i, 0, 0, time.UTC).Format("15:04")
+		id, _ := store.AddReceipt(receipt)
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := NewQueue(store, 2, 10)
+	go queue.Run(ctx, 0)
+
+	// The receipts that were already stored are found by the first sweep,
+	// new ones are queued as they arrive
+	id, _ := store.AddReceipt(newTestReceipt())
+	queue.Enqueue(id)
+	ids = append(ids, id)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range ids {
+		for {
+			receipt, _ := store.GetReceipt(id)
+			if receipt.Status == models.StatusScored {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("receipt %s = got status %q, wanted %q", id, receipt.Status, models.StatusScored)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestQueueEnqueue(t *testing.T) {
+	queue := NewQueue(models.NewMemoryStore(), 1, 1)
+
+	testTable := []struct {
+		id       string
+		expected bool
+	}{
+		{"first", true},
+		// Already queued
+		{"first", true},
+		// The queue is full
+		{"second", false},
+	}
+
+	for _, test := range testTable {
+		if got := queue.Enqueue(test.id); got != test.expected {
+			t.Errorf("Enqueue(%q) = got %t, wanted %t", test.id, got, test.expected)
+		}
+	}
+}
@@ -1,7 +1,19 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/jelaniharris/FetchReceiptProcessor/internal/api"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/config"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/models"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/rules"
+	"github.com/jelaniharris/FetchReceiptProcessor/internal/scoring"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jelaniharris/FetchReceiptProcessor/docs"
@@ -21,6 +33,58 @@ import (
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := models.NewReconciliationPolicy(cfg.ReconciliationPolicy, cfg.ReconciliationTolerance)
+	if err != nil {
+		log.Fatal(err)
+	}
+	models.SetReconciliationPolicy(policy)
+
+	// Compare rulesets against the stored receipts instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		if err := runBacktest(cfg, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatalf("Could not open the %s receipt store: %v", cfg.StoreBackend, err)
+	}
+	handler := api.NewHandler(store)
+	if err := handler.SetDuplicatePolicy(cfg.DuplicatePolicy); err != nil {
+		log.Fatal(err)
+	}
+
+	// Forget idempotency keys once they expire
+	handler.IdempotencyWindow = cfg.IdempotencyKeyTTL
+	handler.BatchLimit = cfg.BatchMaxSize
+	handler.AdminToken = cfg.AdminToken
+	go handler.PurgeIdempotencyKeys(context.Background(), time.Hour)
+
+	if cfg.RulesetFile != "" {
+		reloader := rules.NewReloader(cfg.RulesetFile)
+		if err := reloader.Reload(); err != nil {
+			log.Fatalf("Could not load the ruleset:\n%v", err)
+		}
+		log.Printf("Loaded %d rules from %s, version %s", len(rules.ActiveRuleset().Rules()), cfg.RulesetFile, rules.ActiveRuleset().Version())
+
+		// Pick up changes to the file, or reload on demand with SIGHUP
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		go reloader.Watch(context.Background(), cfg.RulesetWatchInterval, hangups)
+	}
+
+	// Score receipts in the background, once the rules are loaded. Receipts
+	// left pending by a restart or a full queue are picked up every minute.
+	handler.Queue = scoring.NewQueue(store, cfg.ScoringWorkers, cfg.ScoringQueueSize)
+	go handler.Queue.Run(context.Background(), time.Minute)
+
 	router := gin.Default()
 
 	// Add swagger support
@@ -29,15 +93,65 @@ func main() {
 	receiptsGroup := router.Group("/receipts")
 	{
 		// Get a listing of all receipts
-		receiptsGroup.GET("", api.GetReceipts)
+		receiptsGroup.GET("", handler.GetReceipts)
 		// Get a single receipt by an id
-		receiptsGroup.GET(":id", api.GetReceipt)
+		receiptsGroup.GET(":id", handler.GetReceipt)
+		// Replace a receipt
+		receiptsGroup.PUT(":id", handler.UpdateReceipt)
+		// Change some fields of a receipt
+		receiptsGroup.PATCH(":id", handler.PatchReceipt)
+		// Soft delete a receipt
+		receiptsGroup.DELETE(":id", handler.DeleteReceipt)
 		// Return the point value of a receipt
-		receiptsGroup.GET(":id/points", api.GetReceiptPoints)
+		receiptsGroup.GET(":id/points", handler.GetReceiptPoints)
+		// Whether the receipt has been scored yet
+		receiptsGroup.GET(":id/status", handler.GetReceiptStatus)
+		// List the changes made to a receipt
+		receiptsGroup.GET(":id/history", handler.GetReceiptHistory)
+		// Get a single change made to a receipt
+		receiptsGroup.GET(":id/history/:revision", handler.GetReceiptRevision)
+		// Explain how the point value of a receipt was reached
+		receiptsGroup.GET(":id/points/breakdown", handler.GetReceiptPointsBreakdown)
 		// Creates a receipt
-		receiptsGroup.POST("process", api.CreateReceipt)
+		receiptsGroup.POST("process", handler.CreateReceipt)
+		// Creates many receipts at once
+		receiptsGroup.POST("process/batch", handler.CreateReceiptBatch)
+	}
+
+	pointsGroup := router.Group("/points")
+	{
+		// Score a receipt without storing it
+		pointsGroup.POST("simulate", handler.SimulatePoints)
+	}
+
+	if cfg.AdminToken != "" {
+		adminGroup := router.Group("/admin", api.RequireAdminToken(cfg.AdminToken))
+		{
+			// Permanently remove a receipt
+			adminGroup.DELETE("receipts/:id", handler.PurgeReceipt)
+			// Score receipts again under a chosen ruleset version
+			adminGroup.POST("receipts/rescore", handler.RescoreReceipts)
+			// List the ruleset versions receipts can be scored with
+			adminGroup.GET("rulesets", handler.GetRulesetVersions)
+			// Compare the points of stored receipts under two rulesets
+			adminGroup.POST("rulesets/backtest", handler.BacktestRulesets)
+		}
+	} else {
+		log.Print("ADMIN_TOKEN is not set, the /admin endpoints are turned off")
 	}
 
 	// Start up the server at 8080
 	router.Run()
 }
+
+// Pick the receipt store backend from the configuration
+func openStore(cfg config.Config) (models.ReceiptStore, error) {
+	switch cfg.StoreBackend {
+	case config.StoreMemory:
+		return models.NewMemoryStore(), nil
+	case config.StoreSQLite:
+		return models.NewSQLiteStore(cfg.SQLitePath)
+	}
+
+	return nil, fmt.Errorf("unknown store backend %q", cfg.StoreBackend)
+}